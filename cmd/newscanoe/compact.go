@@ -0,0 +1,67 @@
+package newscanoe
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/giulianopz/newscanoe/internal/cache"
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+// CompactCache drops the cached items exceeding the retention limits, rewrites the cache file and reports what was freed
+func CompactCache() error {
+
+	conf, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	c, err := cache.Open(conf.Get(config.CACHE_BACKEND))
+	if err != nil {
+		return err
+	}
+	if !util.Exists(c.FilePath()) {
+		fmt.Println("no cache to compact")
+		return nil
+	}
+
+	before, err := os.Stat(c.FilePath())
+	if err != nil {
+		return err
+	}
+
+	if err := c.Load(); err != nil {
+		return err
+	}
+
+	dropped := c.Compact(conf)
+	if err := c.Encode(); err != nil {
+		return err
+	}
+	if err := c.Shrink(); err != nil {
+		return err
+	}
+
+	after, err := os.Stat(c.FilePath())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("dropped %d item(s), freed %s\n", dropped, util.HumanSize(max(before.Size()-after.Size(), 0)))
+	return nil
+}
+
+// loadConfig reads the config file
+func loadConfig() (*config.Config, error) {
+
+	configPath, err := util.GetConfigFilePath()
+	if err != nil {
+		return nil, err
+	}
+	conf := config.NewConfig()
+	if err := conf.Decode(configPath); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
@@ -7,14 +7,12 @@ import (
 	"io"
 	"os"
 	"os/exec"
-	"regexp"
 	"strings"
 
+	"github.com/giulianopz/newscanoe/internal/config"
 	"github.com/giulianopz/newscanoe/internal/util"
 )
 
-var linePattern = regexp.MustCompile(`http[^"]+((\s#"[^"]+")+)`)
-
 const errMsg = "# the following line does not respect the pattern"
 
 func EditConfigFile() error {
@@ -62,7 +60,7 @@ func EditConfigFile() error {
 		s := bufio.NewScanner(bytes.NewReader(bs))
 		for s.Scan() {
 			line := s.Text()
-			if !linePattern.MatchString(line) && !strings.HasPrefix(line, "#") {
+			if err := config.CheckLine(line); err != nil {
 				fileIsValid = false
 				if _, err := buf.WriteString(fmt.Sprintf("%s: %s\n", errMsg, line)); err != nil {
 					return err
@@ -0,0 +1,95 @@
+package newscanoe
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/cache"
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/export"
+	"github.com/giulianopz/newscanoe/internal/util"
+	"golang.org/x/exp/slices"
+)
+
+/*
+ExportFeed writes the cached articles of all feeds, or of the ones annotated with a tag, as a single feed from the most recent,
+e.g. for the blogroll of a team, and optionally as a static web page too
+*/
+func ExportFeed(args []string) error {
+
+	fs := flag.NewFlagSet("export-feed", flag.ContinueOnError)
+	format := fs.String("format", export.RSS, "format of the feed: "+export.Formats())
+	tag := fs.String("tag", "", "export only the feeds annotated with the given text")
+	since := fs.String("since", "", "export only the articles published within the given time, e.g. 7d")
+	title := fs.String("title", "newscanoe", "title of the feed")
+	link := fs.String("link", "", "url of the web page the feed belongs to")
+	var output string
+	fs.StringVar(&output, "o", "", "file to write the feed to, instead of the standard output")
+	fs.StringVar(&output, "output", "", "file to write the feed to, instead of the standard output")
+	htmlPath := fs.String("html", "", "file to write a web page listing the articles to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if !slices.Contains([]string{export.RSS, export.ATOM, export.JSON}, *format) {
+		return fmt.Errorf("unknown feed format: %q, want one of %s", *format, export.Formats())
+	}
+
+	var from time.Time
+	if *since != "" {
+		d, err := util.ParseDuration(*since)
+		if err != nil {
+			return fmt.Errorf("invalid time for --since: %w", err)
+		}
+		from = time.Now().Add(-d)
+	}
+
+	conf, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	c, err := cache.Open(conf.Get(config.CACHE_BACKEND))
+	if err != nil {
+		return err
+	}
+
+	entries, err := export.Entries(c, conf, from, *tag)
+	if err != nil {
+		return err
+	}
+
+	meta := export.Meta{Title: *title, Link: *link, Updated: time.Now()}
+
+	if err := writeTo(output, func(w io.Writer) error {
+		return export.Write(w, *format, meta, entries)
+	}); err != nil {
+		return err
+	}
+	if *htmlPath != "" {
+		return writeTo(*htmlPath, func(w io.Writer) error {
+			return export.WriteHTML(w, meta, entries)
+		})
+	}
+	return nil
+}
+
+// writeTo writes to the file with the given path, or to the standard output if empty
+func writeTo(path string, write func(io.Writer) error) error {
+
+	if path == "" {
+		return write(os.Stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	return errors.Join(write(f), f.Close())
+}
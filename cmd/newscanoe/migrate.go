@@ -0,0 +1,19 @@
+package newscanoe
+
+import (
+	"fmt"
+
+	"github.com/giulianopz/newscanoe/internal/cache"
+)
+
+// MigrateCache copies the cache from its gob file into the database used by the bolt backend
+func MigrateCache() error {
+
+	count, err := cache.MigrateGob()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("migrated %d item(s): add `set cache-backend %s` to the config file to use them\n", count, cache.BOLT_BACKEND)
+	return nil
+}
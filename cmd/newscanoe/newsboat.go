@@ -0,0 +1,112 @@
+package newscanoe
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/giulianopz/newscanoe/internal/cache"
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+/*
+ImportNewsboat adds the feeds of a urls file of newsboat to the config file and the items of its cache database
+to the cache: by default, they are looked for where newsboat keeps them, i.e. in ~/.newsboat or else in the XDG directories
+*/
+func ImportNewsboat(args []string) error {
+
+	if len(args) > 2 {
+		return errors.New("too many arguments: newscanoe import-newsboat [URLS [CACHE.DB]]")
+	}
+
+	urlsPath, dbPath := newsboatPaths()
+	if len(args) > 0 {
+		urlsPath, dbPath = args[0], ""
+	}
+	if len(args) > 1 {
+		dbPath = args[1]
+	}
+
+	// only the files not given explicitly can be missing
+	for _, path := range args {
+		if !util.Exists(path) {
+			return fmt.Errorf("no such file: %s", path)
+		}
+	}
+	if !util.Exists(urlsPath) && !util.Exists(dbPath) {
+		return fmt.Errorf("no newsboat files found: %s, %s", urlsPath, dbPath)
+	}
+
+	conf, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if util.Exists(urlsPath) {
+		imported, unsupported, err := conf.ImportNewsboatUrls(urlsPath)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("imported %d feed(s) from %s\n", imported, urlsPath)
+		for _, line := range unsupported {
+			fmt.Printf("\tskipped query feed, not supported: %s\n", line)
+		}
+	}
+
+	if util.Exists(dbPath) {
+
+		c, err := cache.Open(conf.Get(config.CACHE_BACKEND))
+		if err != nil {
+			return err
+		}
+		if err := c.Load(); err != nil {
+			return err
+		}
+
+		titles, added, err := c.ImportNewsboat(dbPath)
+		if err != nil {
+			return err
+		}
+
+		// feeds not named in the urls file are named after their url until then
+		for _, f := range conf.Feeds {
+			if title := titles[f.Url]; f.Name == f.Url && title != "" {
+				f.Name = title
+			}
+		}
+
+		c.Merge(conf)
+		if err := c.Encode(); err != nil {
+			return err
+		}
+		fmt.Printf("imported %d item(s) from %s\n", added, dbPath)
+	}
+
+	return conf.Encode()
+}
+
+// newsboatPaths returns the default paths of the urls file and of the cache database of newsboat
+func newsboatPaths() (string, string) {
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", ""
+	}
+
+	dotDir := filepath.Join(home, ".newsboat")
+	if util.Exists(dotDir) {
+		return filepath.Join(dotDir, "urls"), filepath.Join(dotDir, "cache.db")
+	}
+
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		configDir = filepath.Join(home, ".config")
+	}
+	dataDir := os.Getenv("XDG_DATA_HOME")
+	if dataDir == "" {
+		dataDir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(configDir, "newsboat", "urls"), filepath.Join(dataDir, "newsboat", "cache.db")
+}
@@ -1,16 +1,34 @@
 package newscanoe
 
 import (
-	"os"
+	"fmt"
 
-	"github.com/giulianopz/newscanoe/internal/util"
+	"github.com/giulianopz/newscanoe/internal/cache"
+	"github.com/giulianopz/newscanoe/internal/config"
 )
 
+// RemoveCacheFile removes the cache file, unless some items are starred: then they are the only ones kept
 func RemoveCacheFile() error {
 
-	cachePath, err := util.GetCacheFilePath()
+	conf, err := loadConfig()
 	if err != nil {
 		return err
 	}
-	return os.Remove(cachePath)
+
+	c, err := cache.Open(conf.Get(config.CACHE_BACKEND))
+	if err != nil {
+		return err
+	}
+	if err := c.Load(); err != nil {
+		return err
+	}
+
+	if kept := c.KeepStarred(); kept != 0 {
+		fmt.Printf("kept %d starred item(s)\n", kept)
+		if err := c.Encode(); err != nil {
+			return err
+		}
+		return c.Shrink()
+	}
+	return c.Remove()
 }
@@ -1,7 +1,6 @@
 package newscanoe
 
 import (
-	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -9,14 +8,13 @@ import (
 
 	"github.com/giulianopz/newscanoe/internal/display"
 	"github.com/giulianopz/newscanoe/internal/termios"
-	"github.com/giulianopz/newscanoe/internal/xterm"
 )
 
 var (
 	sigC = make(chan os.Signal, 1)
 
 	signals = []os.Signal{
-		syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGWINCH,
+		syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGWINCH, syscall.SIGTSTP, syscall.SIGCONT,
 	}
 )
 
@@ -24,10 +22,14 @@ func Run(debugMode bool) {
 
 	signal.Notify(sigC, signals...)
 
-	origTermios := termios.EnableRawMode(os.Stdin.Fd())
-	defer termios.DisableRawMode(os.Stdin.Fd(), origTermios)
-
 	d := display.New(debugMode)
+
+	if err := d.LoadConfig(); err != nil {
+		log.Panicln(err)
+	}
+
+	d.EnableRawMode()
+	defer d.DisableRawMode()
 	defer d.Clear()
 
 	w, h, err := termios.GetWindowSize(int(os.Stdin.Fd()))
@@ -36,11 +38,21 @@ func Run(debugMode bool) {
 	}
 	d.SetWindowSize(w, h)
 
-	if err := d.LoadConfig(); err != nil {
+	if err := d.LoadCache(); err != nil {
 		log.Panicln(err)
 	}
 
-	if err := d.LoadCache(); err != nil {
+	d.EnableNotifications()
+
+	if err := d.EnableHooks(); err != nil {
+		log.Panicln(err)
+	}
+
+	if err := d.EnableSync(); err != nil {
+		log.Panicln(err)
+	}
+
+	if err := d.LoadDownloads(); err != nil {
 		log.Panicln(err)
 	}
 
@@ -61,16 +73,22 @@ func Run(debugMode bool) {
 						d.RefreshScreen()
 					}
 				}
+			case syscall.SIGTSTP:
+				d.Suspend()
+			case syscall.SIGCONT:
+				d.Resume()
 			default:
 				d.QuitC <- true
 			}
 		}
 	}()
 
-	fmt.Fprintf(os.Stdout, xterm.DISABLE_MOUSE_TRACKING)
-	fmt.Fprintf(os.Stdout, xterm.ENABLE_BRACKETED_PASTE)
+	d.StartAutoReload()
 
 	go d.ListenToInput()
 
 	<-d.QuitC
+
+	d.SyncBeforeQuit()
+	d.FlushCache()
 }
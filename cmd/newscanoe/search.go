@@ -0,0 +1,48 @@
+package newscanoe
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/giulianopz/newscanoe/internal/cache"
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+// width of the passages of text printed for every search result
+const snippetWidth = 120
+
+// Search prints the cached articles of all feeds matching a query, from the best match
+func Search(query string) error {
+
+	if query == "" {
+		return errors.New("nothing to search: newscanoe search QUERY")
+	}
+
+	conf, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	c, err := cache.Open(conf.Get(config.CACHE_BACKEND))
+	if err != nil {
+		return err
+	}
+	if err := c.Load(); err != nil {
+		return err
+	}
+
+	results := c.Search(query, 0, snippetWidth)
+	if len(results) == 0 {
+		return fmt.Errorf("no article matching: %s", query)
+	}
+
+	for _, r := range results {
+		fmt.Println(util.RenderArticleRow(r.Item.PubDate, fmt.Sprintf("%s > %s", r.Feed.Name, r.Item.Title)))
+		fmt.Printf("\t%s\n", r.Item.Url)
+		if r.Snippet != "" {
+			fmt.Printf("\t%s\n", r.Snippet)
+		}
+	}
+	return nil
+}
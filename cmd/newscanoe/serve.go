@@ -0,0 +1,87 @@
+package newscanoe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/cache"
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/greader"
+)
+
+// how long the requests being served are waited for when stopping the server
+const shutdownTimeout = 5 * time.Second
+
+/*
+Serve serves the cache over the Google Reader API at the given address, or else at the one set in the config file,
+until interrupted: the credentials of clients are set in the config file too
+*/
+func Serve(args []string) error {
+
+	if len(args) > 1 {
+		return errors.New("too many arguments: newscanoe serve [ADDRESS]")
+	}
+
+	conf, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	user, password, err := conf.Credentials(config.SERVE_USER, config.SERVE_PASSWORD)
+	if err != nil {
+		return err
+	}
+	if user == "" || password == "" {
+		return fmt.Errorf("no credentials for clients: set %s and %s in the config file", config.SERVE_USER, config.SERVE_PASSWORD)
+	}
+
+	address := conf.Get(config.SERVE_ADDRESS)
+	if len(args) == 1 {
+		address = args[0]
+	}
+
+	c, err := cache.Open(conf.Get(config.CACHE_BACKEND))
+	if err != nil {
+		return err
+	}
+	if err := c.Load(); err != nil {
+		return err
+	}
+	c.Merge(conf)
+	c.SetRetention(conf.Retention)
+	c.OnSaveError(func(err error) {
+		fmt.Fprintln(os.Stderr, err)
+	})
+	defer c.Flush()
+
+	srv := &http.Server{
+		Addr:              address,
+		Handler:           greader.NewServer(c, user, password),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- srv.ListenAndServe()
+	}()
+	fmt.Printf("serving the Google Reader API at http://%s\n", address)
+
+	select {
+	case err := <-errC:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,55 @@
+package newscanoe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/giulianopz/newscanoe/internal/cache"
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/greader"
+)
+
+// Sync syncs the cache with the server set by the sync settings, reporting what changed on either side
+func Sync() error {
+
+	conf, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	c, err := cache.Open(conf.Get(config.CACHE_BACKEND))
+	if err != nil {
+		return err
+	}
+	if err := c.Load(); err != nil {
+		return err
+	}
+	c.Merge(conf)
+	c.SetRetention(conf.Retention)
+
+	var saveErr error
+	c.OnSaveError(func(err error) {
+		saveErr = err
+	})
+
+	s, err := greader.NewConfiguredSyncer(conf, c)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return fmt.Errorf("no server to sync with: set %s and %s in the config file", config.SYNC_BACKEND, config.SYNC_URL)
+	}
+
+	res, err := s.Sync(context.Background())
+	c.Flush()
+	if err != nil {
+		return errors.Join(err, saveErr)
+	}
+	if saveErr != nil {
+		return saveErr
+	}
+
+	fmt.Printf("added %d feed(s) and %d item(s), changed %d item(s) here and %d on the server\n", res.Feeds, res.Items, res.Pulled, res.Pushed)
+	return nil
+}
@@ -5,7 +5,9 @@ go 1.22.3
 require (
 	github.com/giulianopz/go-readability v0.1.1
 	github.com/mmcdole/gofeed v1.3.0
+	go.etcd.io/bbolt v1.3.11
 	golang.org/x/exp v0.0.0-20240808152545-0cdaa3abc0fa
+	golang.org/x/net v0.28.0
 	golang.org/x/sync v0.8.0
 	golang.org/x/sys v0.24.0
 )
@@ -17,6 +19,5 @@ require (
 	github.com/mmcdole/goxpp v1.1.1 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
-	golang.org/x/net v0.28.0 // indirect
 	golang.org/x/text v0.17.0 // indirect
 )
@@ -17,6 +17,7 @@ const (
 	ALL_ATTRIBUTES_OFF = 0
 	BOLD               = 1
 	FAINT              = 2
+	ITALIC             = 3
 	REVERSE_COLOR      = 7
 	SET_FG_COLOR       = 38
 	SET_BG_COLOR       = 48
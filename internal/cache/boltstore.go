@@ -0,0 +1,639 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/util"
+	bolt "go.etcd.io/bbolt"
+)
+
+// version of the layout of the database, to be bumped like schemaVersion
+const dbVersion = 1
+
+// buckets of the database
+var (
+	// the version of the layout
+	metaBucket = []byte("meta")
+	// the feeds but their items, by url
+	feedsBucket = []byte("feeds")
+	// a bucket for every feed, holding its items by title
+	itemsBucket = []byte("items")
+	// the keys of every item by feed and publication date, from the most recent
+	byDateBucket = []byte("by-date")
+	// the keys of the unread items by feed and publication date, from the most recent
+	unreadBucket = []byte("unread")
+	// a bucket for every feed, holding the generation of the last write of each of its items by title
+	generationsBucket = []byte("generations")
+)
+
+// keys of the meta bucket
+var (
+	versionKey = []byte("version")
+	// the number of writes of the database so far
+	generationKey = []byte("generation")
+)
+
+// how long to wait for the database to be released by another process not locking the directory
+const openTimeout = time.Second
+
+/*
+boltStore keeps the cache in an embedded database, where only the items changed since the last write are written,
+indexed by feed, unread state and publication date
+*/
+type boltStore struct {
+	// path of the database file, the default one if empty
+	filePath string
+	// open while locked
+	db *bolt.DB
+	// the generation of the database as last loaded or written by this instance
+	generation uint64
+}
+
+// feedRecord is a feed as stored in the database, without its items
+type feedRecord struct {
+	Name        string
+	Url         string
+	UnreadCount int
+	TTL         time.Duration
+	LastRefresh time.Time
+	// the generation of the last write of the feed or of its items
+	Generation uint64 `json:",omitempty"`
+}
+
+func (s *boltStore) path() string {
+	if s.filePath == "" {
+		filePath, err := util.GetDatabaseFilePath()
+		if err != nil {
+			return ""
+		}
+		s.filePath = filePath
+	}
+	return s.filePath
+}
+
+func (s *boltStore) lock() (func(), error) {
+
+	unlock, err := util.LockDir(filepath.Dir(s.path()))
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(s.path(), 0644, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		unlock()
+		if errors.Is(err, bolt.ErrTimeout) {
+			return nil, &util.LockedError{Dir: filepath.Dir(s.path())}
+		}
+		return nil, err
+	}
+	s.db = db
+
+	return func() {
+		s.db.Close()
+		s.db = nil
+		unlock()
+	}, nil
+}
+
+// checkVersion returns an error if the database was written by a newer version of the app
+func checkVersion(tx *bolt.Tx) error {
+	b := tx.Bucket(metaBucket)
+	if b == nil {
+		return nil
+	}
+	version, err := strconv.Atoi(string(b.Get(versionKey)))
+	if err != nil {
+		return fmt.Errorf("invalid database version: %w", err)
+	}
+	if version > dbVersion {
+		return fmt.Errorf("%w (database version %d, supported up to %d)", errNewerSchema, version, dbVersion)
+	}
+	return nil
+}
+
+// generationOf returns the number of writes of the database so far
+func generationOf(tx *bolt.Tx) uint64 {
+	b := tx.Bucket(metaBucket)
+	if b == nil {
+		return 0
+	}
+	return decodeGeneration(b.Get(generationKey))
+}
+
+// decodeGeneration returns the generation encoded in a value, zero if it is not one
+func decodeGeneration(v []byte) uint64 {
+	if len(v) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(v)
+}
+
+func (s *boltStore) load() ([]*feed.Feed, error) {
+
+	feeds := make([]*feed.Feed, 0)
+	var generation uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+
+		if err := checkVersion(tx); err != nil {
+			return err
+		}
+		generation = generationOf(tx)
+
+		fb, ib := tx.Bucket(feedsBucket), tx.Bucket(itemsBucket)
+		if fb == nil || ib == nil {
+			return nil
+		}
+
+		return fb.ForEach(func(url, v []byte) error {
+
+			f, err := decodeFeed(v)
+			if err != nil {
+				return err
+			}
+
+			if b := ib.Bucket(url); b != nil {
+				if err := b.ForEach(func(_, v []byte) error {
+					i := &feed.Item{}
+					if err := json.Unmarshal(v, i); err != nil {
+						return err
+					}
+					f.Items = append(f.Items, i)
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+
+			feeds = append(feeds, f)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.generation = generation
+	return feeds, nil
+}
+
+/*
+changes returns the feeds written by other instances since the database was last loaded or written by this one,
+with just the items they wrote: every write bumps the generation of the database and records it in what it writes
+*/
+func (s *boltStore) changes() ([]*feed.Feed, error) {
+
+	var feeds []*feed.Feed
+	var generation uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+
+		if err := checkVersion(tx); err != nil {
+			return err
+		}
+		if generation = generationOf(tx); generation == s.generation {
+			return nil
+		}
+
+		feeds = make([]*feed.Feed, 0)
+		fb, ib, gb := tx.Bucket(feedsBucket), tx.Bucket(itemsBucket), tx.Bucket(generationsBucket)
+		if fb == nil || ib == nil || gb == nil {
+			return nil
+		}
+
+		return fb.ForEach(func(url, v []byte) error {
+
+			var r feedRecord
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			if r.Generation <= s.generation {
+				return nil
+			}
+			f := r.feed()
+
+			items, gens := ib.Bucket(url), gb.Bucket(url)
+			if items != nil && gens != nil {
+				if err := gens.ForEach(func(k, v []byte) error {
+					if decodeGeneration(v) <= s.generation {
+						return nil
+					}
+					data := items.Get(k)
+					if data == nil {
+						return nil
+					}
+					i := &feed.Item{}
+					if err := json.Unmarshal(data, i); err != nil {
+						return err
+					}
+					f.Items = append(f.Items, i)
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+
+			feeds = append(feeds, f)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.generation = generation
+	return feeds, nil
+}
+
+// itemWrite is the put, or the deletion if data is nil, of an item in the database
+type itemWrite struct {
+	feedUrl string
+	title   string
+	// the item encoded, nil to delete it
+	data    []byte
+	pubDate time.Time
+	unread  bool
+}
+
+func (s *boltStore) write(feeds []*feed.Feed, synced map[string]map[string]itemState) (func() error, error) {
+
+	records := make(map[string]*feedRecord, len(feeds))
+	// the feeds with items to be written, whose records are written anyway
+	touched := make(map[string]bool)
+	writes := make([]itemWrite, 0)
+
+	for _, f := range feeds {
+
+		r := &feedRecord{
+			Name:        f.Name,
+			Url:         f.Url,
+			TTL:         f.TTL,
+			LastRefresh: f.LastRefresh,
+		}
+		records[f.Url] = r
+
+		syncedItems := synced[f.Url]
+		for _, i := range f.Items {
+			if i.Unread {
+				r.UnreadCount++
+			}
+			data, err := json.Marshal(i)
+			if err != nil {
+				return nil, err
+			}
+			if state, found := syncedItems[i.Title]; found && state.Sum == sumOf(data) {
+				continue
+			}
+			writes = append(writes, itemWrite{feedUrl: f.Url, title: i.Title, data: data, pubDate: i.PubDate, unread: i.Unread})
+			touched[f.Url] = true
+		}
+
+		for title := range syncedItems {
+			if !f.HasItem(title) {
+				writes = append(writes, itemWrite{feedUrl: f.Url, title: title})
+				touched[f.Url] = true
+			}
+		}
+	}
+
+	dropped := make([]string, 0)
+	for url := range synced {
+		if _, found := records[url]; !found {
+			dropped = append(dropped, url)
+		}
+	}
+
+	return func() error {
+		var generation uint64
+		err := s.db.Update(func(tx *bolt.Tx) error {
+
+			if err := checkVersion(tx); err != nil {
+				return err
+			}
+
+			generation = generationOf(tx) + 1
+			encodedGeneration := binary.BigEndian.AppendUint64(nil, generation)
+
+			meta, err := tx.CreateBucketIfNotExists(metaBucket)
+			if err != nil {
+				return err
+			}
+			if err := meta.Put(versionKey, []byte(strconv.Itoa(dbVersion))); err != nil {
+				return err
+			}
+			if err := meta.Put(generationKey, encodedGeneration); err != nil {
+				return err
+			}
+
+			buckets := make([]*bolt.Bucket, 0, 5)
+			for _, name := range [][]byte{feedsBucket, itemsBucket, byDateBucket, unreadBucket, generationsBucket} {
+				b, err := tx.CreateBucketIfNotExists(name)
+				if err != nil {
+					return err
+				}
+				buckets = append(buckets, b)
+			}
+			fb, ib, byDate, unread, gb := buckets[0], buckets[1], buckets[2], buckets[3], buckets[4]
+
+			for _, url := range dropped {
+				if err := fb.Delete([]byte(url)); err != nil {
+					return err
+				}
+				for _, b := range []*bolt.Bucket{ib, gb} {
+					if b.Bucket([]byte(url)) != nil {
+						if err := b.DeleteBucket([]byte(url)); err != nil {
+							return err
+						}
+					}
+				}
+				for _, index := range []*bolt.Bucket{byDate, unread} {
+					if err := deletePrefix(index, feedPrefix(url)); err != nil {
+						return err
+					}
+				}
+			}
+
+			for url, r := range records {
+
+				data, err := json.Marshal(r)
+				if err != nil {
+					return err
+				}
+
+				// a record left as it is keeps its generation, not to be taken as a change by other instances
+				if old := fb.Get([]byte(url)); old != nil && !touched[url] {
+					var oldRecord feedRecord
+					if err := json.Unmarshal(old, &oldRecord); err == nil {
+						oldRecord.Generation = 0
+						if oldData, err := json.Marshal(&oldRecord); err == nil && bytes.Equal(oldData, data) {
+							continue
+						}
+					}
+				}
+
+				r.Generation = generation
+				if data, err = json.Marshal(r); err != nil {
+					return err
+				}
+				if err := fb.Put([]byte(url), data); err != nil {
+					return err
+				}
+			}
+
+			for _, w := range writes {
+
+				b, err := ib.CreateBucketIfNotExists([]byte(w.feedUrl))
+				if err != nil {
+					return err
+				}
+				gens, err := gb.CreateBucketIfNotExists([]byte(w.feedUrl))
+				if err != nil {
+					return err
+				}
+				key := itemKey(w.title)
+
+				// the entries of the item in the indexes are replaced as a whole
+				if old := b.Get(key); old != nil {
+					var i feed.Item
+					if err := json.Unmarshal(old, &i); err != nil {
+						return err
+					}
+					if err := byDate.Delete(indexKey(w.feedUrl, i.PubDate, w.title)); err != nil {
+						return err
+					}
+					if err := unread.Delete(indexKey(w.feedUrl, i.PubDate, w.title)); err != nil {
+						return err
+					}
+				}
+
+				if w.data == nil {
+					if err := b.Delete(key); err != nil {
+						return err
+					}
+					if err := gens.Delete(key); err != nil {
+						return err
+					}
+					continue
+				}
+
+				if err := b.Put(key, w.data); err != nil {
+					return err
+				}
+				if err := gens.Put(key, encodedGeneration); err != nil {
+					return err
+				}
+				if err := byDate.Put(indexKey(w.feedUrl, w.pubDate, w.title), nil); err != nil {
+					return err
+				}
+				if w.unread {
+					if err := unread.Put(indexKey(w.feedUrl, w.pubDate, w.title), nil); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		s.generation = generation
+		return nil
+	}, nil
+}
+
+// items walks the index matching the query, which is limited to the keys of a single feed if given
+func (s *boltStore) items(q Query) ([]*Entry, error) {
+
+	entries := make([]*Entry, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+
+		if err := checkVersion(tx); err != nil {
+			return err
+		}
+
+		index := tx.Bucket(byDateBucket)
+		if q.Unread {
+			index = tx.Bucket(unreadBucket)
+		}
+		fb, ib := tx.Bucket(feedsBucket), tx.Bucket(itemsBucket)
+		if index == nil || fb == nil || ib == nil {
+			return nil
+		}
+
+		var prefix []byte
+		if q.FeedUrl != "" {
+			prefix = feedPrefix(q.FeedUrl)
+		}
+
+		feeds := make(map[string]*feed.Feed)
+		c := index.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+
+			url, pubDate, title, err := parseIndexKey(k)
+			if err != nil {
+				return err
+			}
+			if !q.Since.IsZero() && pubDate.Before(q.Since) {
+				if q.FeedUrl != "" {
+					// the keys of a feed are sorted from the most recent
+					break
+				}
+				// the older items of the feed are skipped, landing on the last key before the next feed
+				if k, _ = c.Seek(nextFeedPrefix(url)); k == nil {
+					break
+				}
+				c.Prev()
+				continue
+			}
+
+			f, found := feeds[url]
+			if !found {
+				if f, err = decodeFeed(fb.Get([]byte(url))); err != nil {
+					return err
+				}
+				feeds[url] = f
+			}
+
+			b := ib.Bucket([]byte(url))
+			if b == nil {
+				continue
+			}
+			v := b.Get(itemKey(title))
+			if v == nil {
+				continue
+			}
+			i := &feed.Item{}
+			if err := json.Unmarshal(v, i); err != nil {
+				return err
+			}
+			entries = append(entries, &Entry{Feed: f, Item: i})
+
+			if q.FeedUrl != "" && q.Limit > 0 && len(entries) == q.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return q.sortAndLimit(entries), nil
+}
+
+// remove removes the database along with the gob files it may have been migrated from, not to migrate them again
+func (s *boltStore) remove() error {
+
+	paths := []string{s.path()}
+	if gobPath, err := util.GetCacheFilePath(); err == nil {
+		paths = append(paths, gobPath, util.BackupPath(gobPath), gobPath+migratedSuffix)
+	}
+
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// shrink copies the database into a new file, since the pages freed by deletions are reused but never released
+func (s *boltStore) shrink() error {
+
+	tmp := s.path() + ".tmp"
+	os.Remove(tmp)
+
+	dst, err := bolt.Open(tmp, 0644, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return err
+	}
+	if err := bolt.Compact(dst, s.db, 1<<20); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	if err := s.db.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path()); err != nil {
+		return err
+	}
+
+	db, err := bolt.Open(s.path(), 0644, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return err
+	}
+	s.db = db
+	return nil
+}
+
+func decodeFeed(data []byte) (*feed.Feed, error) {
+	var r feedRecord
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return r.feed(), nil
+}
+
+// feed returns the feed of a record, without items
+func (r *feedRecord) feed() *feed.Feed {
+	f := feed.NewFeed(r.Name).WithUrl(r.Url)
+	f.UnreadCount = r.UnreadCount
+	f.TTL = r.TTL
+	f.LastRefresh = r.LastRefresh
+	return f
+}
+
+// itemKey returns the key of an item in the bucket of its feed, never empty as required even if the title is
+func itemKey(title string) []byte {
+	return append([]byte{'i'}, title...)
+}
+
+// feedPrefix returns the prefix of the index keys of the items of a feed
+func feedPrefix(url string) []byte {
+	return append([]byte(url), 0)
+}
+
+// nextFeedPrefix returns the lowest key following the index keys of the items of a feed
+func nextFeedPrefix(url string) []byte {
+	return append([]byte(url), 1)
+}
+
+/*
+indexKey returns the key of an item in an index: the url of its feed, a zero byte,
+its publication date ordered from the most recent, and its title
+*/
+func indexKey(url string, pubDate time.Time, title string) []byte {
+	key := feedPrefix(url)
+	// flipping the sign bit orders negative dates before positive ones, inverting all bits orders them backwards
+	key = binary.BigEndian.AppendUint64(key, ^(uint64(pubDate.Unix()) ^ 1<<63))
+	return append(key, title...)
+}
+
+func parseIndexKey(key []byte) (string, time.Time, string, error) {
+	sep := bytes.IndexByte(key, 0)
+	if sep < 0 || len(key) < sep+9 {
+		return "", time.Time{}, "", fmt.Errorf("invalid index key: %q", key)
+	}
+	unix := int64(^binary.BigEndian.Uint64(key[sep+1:sep+9]) ^ 1<<63)
+	return string(key[:sep]), time.Unix(unix, 0), string(key[sep+9:]), nil
+}
+
+// deletePrefix deletes the keys of a bucket starting with the given prefix
+func deletePrefix(b *bolt.Bucket, prefix []byte) error {
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Seek(prefix) {
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
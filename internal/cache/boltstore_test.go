@@ -0,0 +1,284 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+func titles(entries []*Entry) []string {
+	ts := make([]string, 0, len(entries))
+	for _, e := range entries {
+		ts = append(ts, e.Item.Title)
+	}
+	return ts
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBoltStore(t *testing.T) {
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	day := func(d int) time.Time {
+		return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	c, err := Open(BOLT_BACKEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.AddFeed(&feed.Feed{Name: "Example", Url: "https://example.com/rss", Items: []*feed.Item{
+		feed.NewItem("First", "https://example.com/1", day(1)),
+		feed.NewItem("Third", "https://example.com/3", day(3)),
+		feed.NewItem("", "https://example.com/untitled", util.NoPubDate),
+	}}, "https://example.com/rss")
+	c.AddFeed(&feed.Feed{Name: "Other", Url: "https://other.com/rss", Items: []*feed.Item{
+		feed.NewItem("Second", "https://other.com/2", day(2)),
+	}}, "https://other.com/rss")
+	if err := c.Encode(); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the changes are written
+	c.GetFeeds()[0].GetItem("Third").Unread = false
+	c.GetFeeds()[0].GetItem("First").Content = "<p>backfilled</p>"
+	c.GetFeeds()[1].Items = nil
+	if err := c.Encode(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Open(BOLT_BACKEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	feeds := loaded.GetFeeds()
+	if len(feeds) != 2 || len(feeds[0].Items) != 3 || len(feeds[1].Items) != 0 {
+		t.Fatalf("unexpected feeds: %+v", feeds)
+	}
+	if i := feeds[0].GetItem("Third"); i == nil || i.Unread {
+		t.Errorf("read state not written: %+v", i)
+	}
+	if i := feeds[0].GetItem("First"); i == nil || i.Content != "<p>backfilled</p>" {
+		t.Errorf("content not written: %+v", i)
+	}
+	if i := feeds[0].GetItem(""); i == nil || i.PubDate != util.NoPubDate {
+		t.Errorf("untitled item not written: %+v", i)
+	}
+
+	c.AddFeed(&feed.Feed{Name: "Other", Url: "https://other.com/rss", Items: []*feed.Item{
+		feed.NewItem("Second", "https://other.com/2", day(2)),
+	}}, "https://other.com/rss")
+	if err := c.Encode(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		q    Query
+		want []string
+	}{
+		{"all", Query{}, []string{"Third", "Second", "First", ""}},
+		{"by feed", Query{FeedUrl: "https://example.com/rss"}, []string{"Third", "First", ""}},
+		{"unread", Query{Unread: true}, []string{"Second", "First", ""}},
+		{"unread by feed", Query{FeedUrl: "https://example.com/rss", Unread: true}, []string{"First", ""}},
+		{"since", Query{Since: day(2)}, []string{"Third", "Second"}},
+		{"since by feed", Query{FeedUrl: "https://example.com/rss", Since: day(2)}, []string{"Third"}},
+		{"limit", Query{Limit: 2}, []string{"Third", "Second"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// looked up in the indexes of the database
+			unloaded, err := Open(BOLT_BACKEND)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries, err := unloaded.Items(tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := titles(entries); !equal(got, tt.want) {
+				t.Errorf("want %q from the database, got %q", tt.want, got)
+			}
+
+			// looked up in memory
+			entries, err = c.Items(tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := titles(entries); !equal(got, tt.want) {
+				t.Errorf("want %q from memory, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMigrateGob(t *testing.T) {
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	starred := feed.NewItem("Starred", "https://example.com/starred", time.Now())
+	starred.Starred = true
+	c := NewCache()
+	c.AddFeed(&feed.Feed{Name: "Example", Url: "https://example.com/rss", Items: []*feed.Item{
+		feed.NewItem("First", "https://example.com/1", time.Now()),
+		starred,
+	}}, "https://example.com/rss")
+	if err := c.Encode(); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := MigrateGob()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("want 2 items migrated, got %d", count)
+	}
+	if _, err := MigrateGob(); err == nil {
+		t.Errorf("want an error migrating again")
+	}
+
+	db, err := Open(BOLT_BACKEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if s := db.Starred(); len(s) != 1 || s[0].Feed.Name != "Example" || s[0].Item.Title != "Starred" {
+		t.Errorf("unexpected starred items: %+v", s)
+	}
+}
+
+func TestRemoveMigratedCache(t *testing.T) {
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	c := NewCache()
+	c.AddFeed(&feed.Feed{Name: "Example", Url: "https://example.com/rss", Items: []*feed.Item{
+		feed.NewItem("First", "https://example.com/1", time.Now()),
+	}}, "https://example.com/rss")
+	if err := c.Encode(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MigrateGob(); err != nil {
+		t.Fatal(err)
+	}
+	if util.Exists(c.FilePath()) || !util.Exists(c.FilePath()+migratedSuffix) {
+		t.Errorf("want the gob file renamed once migrated")
+	}
+
+	db, err := Open(BOLT_BACKEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{db.FilePath(), c.FilePath(), c.FilePath() + migratedSuffix} {
+		if util.Exists(p) {
+			t.Errorf("want %s removed", p)
+		}
+	}
+
+	// on restart, nothing is left to be migrated
+	if _, err := MigrateGob(); err == nil {
+		t.Errorf("want no cache file to migrate")
+	}
+	if err := db.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if feeds := db.GetFeeds(); len(feeds) != 0 {
+		t.Errorf("want an empty cache, got %d feeds", len(feeds))
+	}
+}
+
+func TestBoltMergeChangesOfOtherInstances(t *testing.T) {
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	c, err := Open(BOLT_BACKEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.AddFeed(&feed.Feed{Name: "Example", Url: "https://example.com/rss", Items: []*feed.Item{
+		feed.NewItem("First", "https://example.com/1", time.Now()),
+		feed.NewItem("Second", "https://example.com/2", time.Now()),
+	}}, "https://example.com/rss")
+	if err := c.Encode(); err != nil {
+		t.Fatal(err)
+	}
+
+	one, err := Open(BOLT_BACKEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := Open(BOLT_BACKEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := one.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed := time.Now().Truncate(time.Second)
+	other.GetFeeds()[0].GetItem("Second").Starred = true
+	other.AddFeed(&feed.Feed{Name: "Example", Url: "https://example.com/rss", LastRefresh: refreshed, Items: []*feed.Item{
+		feed.NewItem("Third", "https://example.com/3", time.Now()),
+	}}, "https://example.com/rss")
+	if err := other.Encode(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the changes of the other instance are brought in, rather than overwritten
+	one.GetFeeds()[0].GetItem("First").Unread = false
+	if err := one.Encode(); err != nil {
+		t.Fatal(err)
+	}
+	if f := one.GetFeeds()[0]; len(f.Items) != 3 || !f.GetItem("Second").Starred {
+		t.Errorf("changes of the other instance not merged: %+v", f.Items)
+	}
+
+	merged, err := Open(BOLT_BACKEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := merged.Load(); err != nil {
+		t.Fatal(err)
+	}
+	f := merged.GetFeeds()[0]
+	if len(f.Items) != 3 {
+		t.Fatalf("want 3 items, got %d", len(f.Items))
+	}
+	if f.GetItem("First").Unread {
+		t.Errorf("read state lost")
+	}
+	if !f.GetItem("Second").Starred {
+		t.Errorf("starred state of the other instance lost")
+	}
+	if !f.LastRefresh.Equal(refreshed) || f.UnreadCount != 2 {
+		t.Errorf("feed record overwritten: last refresh %v, %d unread", f.LastRefresh, f.UnreadCount)
+	}
+}
@@ -1,26 +1,91 @@
 package cache
 
 import (
-	"encoding/gob"
+	"errors"
 	"log"
-	"os"
 	"sync"
+	"time"
 
 	"github.com/giulianopz/newscanoe/internal/config"
 	"github.com/giulianopz/newscanoe/internal/feed"
-	"github.com/giulianopz/newscanoe/internal/util"
+	"github.com/giulianopz/newscanoe/internal/search"
 	"golang.org/x/exp/slices"
 )
 
 type Cache struct {
 	mu    sync.Mutex
 	feeds []*feed.Feed
+	// called with the items of a cached feed which were not there before a refresh
+	onNewItems []func(f *feed.Feed, items []*feed.Item)
+	// limits of the items kept for the feed with the given url
+	retention func(url string) feed.Retention
+
+	// serializes the writes of the cache file, so that they land in the order their snapshots were taken
+	writeMu sync.Mutex
+	// a save is requested and not yet started: further requests are coalesced into it
+	pending chan struct{}
+	// saves requested and not yet completed
+	saves       sync.WaitGroup
+	startWriter sync.Once
+	// called when a save requested by Save fails
+	onSaveError func(error)
+
+	// where the cache is persisted
+	store store
+	// whether the feeds were loaded from the store, rather than just added
+	loaded bool
+	// the state of the items as last read or written, to tell the changes of this instance from the ones of others
+	synced map[string]map[string]itemState
+
+	// full-text index of the items, nil until the first search or after the items were replaced as a whole
+	index *search.Index
 }
 
+// NewCache returns an empty cache, persisted in the default gob file
 func NewCache() *Cache {
 	return &Cache{
-		feeds: make([]*feed.Feed, 0),
+		store:      &gobStore{},
+		feeds:      make([]*feed.Feed, 0),
+		onNewItems: make([]func(*feed.Feed, []*feed.Item), 0),
+		retention:  func(string) feed.Retention { return feed.Retention{} },
+		pending:    make(chan struct{}, 1),
+		onSaveError: func(err error) {
+			log.Default().Println(err.Error())
+		},
+		synced: make(map[string]map[string]itemState),
+	}
+}
+
+// Open returns an empty cache, persisted by the given backend at its default path
+func Open(backend string) (*Cache, error) {
+	s, err := newStore(backend)
+	if err != nil {
+		return nil, err
 	}
+	c := NewCache()
+	c.store = s
+	return c, nil
+}
+
+// SetRetention sets the function returning the limits of the items kept for a feed, enforced by AddFeed
+func (c *Cache) SetRetention(f func(url string) feed.Retention) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retention = f
+}
+
+// OnSaveError sets the function called when a save requested by Save fails
+func (c *Cache) OnSaveError(f func(error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onSaveError = f
+}
+
+// OnNewItems adds a function to be called by AddFeed with the new items found in a feed already cached
+func (c *Cache) OnNewItems(f func(f *feed.Feed, items []*feed.Item)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onNewItems = append(c.onNewItems, f)
 }
 
 func (c *Cache) GetFeeds() []*feed.Feed {
@@ -30,52 +95,190 @@ func (c *Cache) GetFeeds() []*feed.Feed {
 	return c.feeds
 }
 
+/*
+Encode writes the changes to the cache since it was last loaded or written: concurrent calls are serialized,
+so that the last snapshot taken is the last one written, and the store is locked against other instances,
+whose changes written meanwhile are merged rather than overwritten
+*/
 func (c *Cache) Encode() error {
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
 
-	filePath, err := util.GetCacheFilePath()
+	unlock, err := c.store.lock()
 	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+	c.mu.Lock()
+	if feeds, err := c.store.changes(); errors.Is(err, errNewerSchema) {
+		c.mu.Unlock()
+		return err
+	} else if err != nil {
+		log.Default().Printf("cannot merge cache written by another instance: %v\n", err)
+	} else if feeds != nil {
+		c.merge(feeds)
+		c.index = nil
+	}
+	write, err := c.store.write(c.feeds, c.synced)
+	synced := c.snapshot()
+	c.mu.Unlock()
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	e := gob.NewEncoder(file)
-	if err := e.Encode(c.feeds); err != nil {
+	if err := write(); err != nil {
 		return err
 	}
 
+	c.mu.Lock()
+	c.synced = synced
+	c.mu.Unlock()
 	return nil
 }
 
+// Save writes the cache file in background: saves requested while another one is waiting to start are coalesced into it
+func (c *Cache) Save() {
+
+	c.startWriter.Do(func() {
+		go func() {
+			for range c.pending {
+				if err := c.Encode(); err != nil {
+					c.mu.Lock()
+					onSaveError := c.onSaveError
+					c.mu.Unlock()
+					onSaveError(err)
+				}
+				c.saves.Done()
+			}
+		}()
+	})
+
+	c.saves.Add(1)
+	select {
+	case c.pending <- struct{}{}:
+	default:
+		// the pending save has not taken its snapshot yet, so it will include the changes of this one
+		c.saves.Done()
+	}
+}
+
+// Flush waits for the saves requested so far to be written
+func (c *Cache) Flush() {
+	c.saves.Wait()
+}
+
+// Decode reads the cache from the given gob file, which is where it is written from now on
 func (c *Cache) Decode(filePath string) error {
+	c.mu.Lock()
+	c.store = &gobStore{filePath: filePath}
+	c.mu.Unlock()
+	return c.Load()
+}
+
+// Load reads the cache from its store, replacing what was read before
+func (c *Cache) Load() error {
+
+	unlock, err := c.store.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	feeds, err := c.store.load()
+	if err != nil {
+		return err
+	}
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	file, err := os.Open(filePath)
+	c.feeds = feeds
+	c.synced = c.snapshot()
+	c.loaded = true
+	c.index = nil
+	return nil
+}
+
+/*
+Refresh brings in the changes written to the store by other instances since the cache was last read or written,
+keeping the changes of this instance not yet written, e.g. to serve the items read or starred meanwhile in the TUI
+*/
+func (c *Cache) Refresh() error {
+
+	unlock, err := c.store.lock()
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	defer unlock()
 
-	var feeds []*feed.Feed
-	d := gob.NewDecoder(file)
-	if err := d.Decode(&feeds); err != nil {
+	feeds, err := c.store.load()
+	if err != nil {
 		return err
 	}
 
-	c.feeds = feeds
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
+	c.merge(feeds)
+	c.synced = snapshotOf(feeds)
+	c.loaded = true
+	c.index = nil
 	return nil
 }
 
+/*
+Items returns the cached items matching a query, from the most recent:
+they are looked up in the store, which for a database means its indexes, unless the cache was loaded
+*/
+func (c *Cache) Items(q Query) ([]*Entry, error) {
+
+	c.mu.Lock()
+	if c.loaded {
+		defer c.mu.Unlock()
+		return query(c.feeds, q), nil
+	}
+	c.mu.Unlock()
+
+	unlock, err := c.store.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return c.store.items(q)
+}
+
+// Remove removes every file of the store of the cache
+func (c *Cache) Remove() error {
+
+	unlock, err := c.store.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return c.store.remove()
+}
+
+// Shrink gives back to the file system the space left unused by the items dropped since the last write
+func (c *Cache) Shrink() error {
+
+	unlock, err := c.store.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return c.store.shrink()
+}
+
+// FilePath returns the path of the main file of the store of the cache
+func (c *Cache) FilePath() string {
+	return c.store.path()
+}
+
 func (c *Cache) AddFeed(parsedFeed *feed.Feed, url string) *feed.Feed {
 
 	c.mu.Lock()
@@ -83,21 +286,151 @@ func (c *Cache) AddFeed(parsedFeed *feed.Feed, url string) *feed.Feed {
 
 	for _, cachedFeed := range c.feeds {
 		if cachedFeed.Url == url {
+			previous := cachedFeed.Items
+			newItems := make([]*feed.Item, 0)
 			for _, parsedItem := range parsedFeed.Items {
-				if !cachedFeed.HasItem(parsedItem.Title) {
+				if cachedItem := cachedFeed.GetItem(parsedItem.Title); cachedItem == nil {
 					cachedFeed.Items = append(cachedFeed.Items, parsedItem)
+					newItems = append(newItems, parsedItem)
+				} else {
+					// items cached before the feed content and the enclosures were stored
+					if cachedItem.Content == "" {
+						cachedItem.Content = parsedItem.Content
+					}
+					if len(cachedItem.Enclosures) == 0 {
+						cachedItem.Enclosures = parsedItem.Enclosures
+					}
 				}
 			}
+			if dropped := cachedFeed.Prune(c.retention(url), time.Now()); dropped != 0 {
+				log.Default().Printf("dropped %d items of feed with url: %s\n", dropped, url)
+				// items still provided by the feed but beyond the retention limits are not new
+				newItems = slices.DeleteFunc(newItems, func(i *feed.Item) bool {
+					return !slices.Contains(cachedFeed.Items, i)
+				})
+			}
+
+			c.reindex(cachedFeed, previous, newItems)
+
+			// a feed fetched for the first time has no new items, but just items
+			if len(newItems) != 0 && !cachedFeed.LastRefresh.IsZero() {
+				for _, f := range c.onNewItems {
+					f(cachedFeed, newItems)
+				}
+			}
+
+			cachedFeed.TTL = parsedFeed.TTL
+			cachedFeed.LastRefresh = parsedFeed.LastRefresh
 			log.Default().Printf("refreshed cached feed with url: %s\n", url)
 			return cachedFeed
 		}
 	}
 
+	parsedFeed.Prune(c.retention(url), time.Now())
+	c.reindex(parsedFeed, nil, parsedFeed.Items)
 	c.feeds = append(c.feeds, parsedFeed)
 	log.Default().Printf("cached a new feed with url: %s\n", url)
 	return parsedFeed
 }
 
+// Starred returns the starred items of all feeds, from the most recent
+func (c *Cache) Starred() []*Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	starred := make([]*Entry, 0)
+	for _, f := range c.feeds {
+		for _, i := range f.Items {
+			if i.Starred {
+				starred = append(starred, &Entry{Feed: f, Item: i})
+			}
+		}
+	}
+
+	slices.SortStableFunc(starred, func(a, b *Entry) int {
+		return b.Item.PubDate.Compare(a.Item.PubDate)
+	})
+	return starred
+}
+
+// KeepStarred drops every item but the starred ones, returning how many were kept
+func (c *Cache) KeepStarred() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var kept int
+	feeds := make([]*feed.Feed, 0)
+	for _, f := range c.feeds {
+		items := make([]*feed.Item, 0)
+		for _, i := range f.Items {
+			if i.Starred {
+				items = append(items, i)
+			}
+		}
+		if len(items) != 0 {
+			f.Items = items
+			f.LastRefresh = time.Time{}
+			feeds = append(feeds, f)
+			kept += len(items)
+		}
+	}
+	c.feeds = feeds
+	c.index = nil
+	return kept
+}
+
+/*
+Compact enforces the retention limits of every feed, dropping also the feeds which are no longer configured
+but for their starred items, and returns how many items were dropped
+*/
+func (c *Cache) Compact(conf *config.Config) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var dropped int
+	now := time.Now()
+
+	feeds := make([]*feed.Feed, 0, len(c.feeds))
+	for _, f := range c.feeds {
+
+		configured := slices.ContainsFunc(conf.Feeds, func(cf *feed.Feed) bool {
+			return cf.Url == f.Url
+		})
+
+		if !configured {
+			before := len(f.Items)
+			f.Items = slices.DeleteFunc(f.Items, func(i *feed.Item) bool {
+				return !i.Starred
+			})
+			dropped += before - len(f.Items)
+			if len(f.Items) == 0 {
+				continue
+			}
+		} else {
+			dropped += f.Prune(conf.Retention(f.Url), now)
+		}
+		feeds = append(feeds, f)
+	}
+
+	c.feeds = feeds
+	c.index = nil
+	return dropped
+}
+
+// LastRefresh returns the last time any feed was fetched
+func (c *Cache) LastRefresh() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var last time.Time
+	for _, f := range c.feeds {
+		if f.LastRefresh.After(last) {
+			last = f.LastRefresh
+		}
+	}
+	return last
+}
+
 func (cache *Cache) Merge(conf *config.Config) {
 
 	for _, configuredFeed := range conf.Feeds {
@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/html"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+func TestKeepStarred(t *testing.T) {
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	old := feed.NewItem("Old news", "https://example.com/old", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	old.Starred = true
+	old.Article = &html.Article{
+		Title:  "Old news",
+		Blocks: []*html.Block{{Kind: html.PARAGRAPH, Text: "kept[1]"}},
+		Links:  []string{"https://example.com/source"},
+	}
+	recent := feed.NewItem("Recent news", "https://example.com/recent", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	recent.Starred = true
+	dropped := feed.NewItem("Dropped", "https://example.com/dropped", time.Now())
+
+	c := NewCache()
+	c.AddFeed(&feed.Feed{Name: "Example", Url: "https://example.com/rss", Items: []*feed.Item{old, recent, dropped}}, "https://example.com/rss")
+	c.AddFeed(&feed.Feed{Name: "Other", Url: "https://other.com/rss", Items: []*feed.Item{feed.NewItem("Other", "https://other.com/1", time.Now())}}, "https://other.com/rss")
+
+	if kept := c.KeepStarred(); kept != 2 {
+		t.Fatalf("want 2 starred items kept, got %d", kept)
+	}
+	if err := c.Encode(); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := util.GetCacheFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := NewCache()
+	if err := decoded.Decode(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if feeds := decoded.GetFeeds(); len(feeds) != 1 || len(feeds[0].Items) != 2 {
+		t.Fatalf("unexpected feeds: %+v", feeds)
+	}
+
+	starred := decoded.Starred()
+	if len(starred) != 2 || starred[0].Item.Title != "Recent news" || starred[1].Item.Title != "Old news" {
+		t.Fatalf("unexpected starred items: %+v", starred)
+	}
+	if a := starred[1].Item.Article; a == nil || a.String() != old.Article.String() {
+		t.Errorf("article text not kept: %+v", a)
+	}
+}
+
+func TestCompact(t *testing.T) {
+
+	configPath := filepath.Join(t.TempDir(), "config")
+	err := os.WriteFile(configPath, []byte("set max-items 2\nhttps://example.com/rss #\"Example\"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := config.NewConfig()
+	if err := conf.Decode(configPath); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	items := make([]*feed.Item, 0)
+	for i := 0; i < 4; i++ {
+		items = append(items, feed.NewItem("News", "https://example.com/"+string(rune('a'+i)), now.Add(-time.Duration(i)*time.Hour)))
+	}
+	items[3].Starred = true
+
+	removed := feed.NewItem("Removed", "https://removed.com/1", now)
+	starred := feed.NewItem("Starred", "https://removed.com/2", now)
+	starred.Starred = true
+
+	c := NewCache()
+	c.AddFeed(&feed.Feed{Name: "Example", Url: "https://example.com/rss", Items: items}, "https://example.com/rss")
+	c.AddFeed(&feed.Feed{Name: "Removed", Url: "https://removed.com/rss", Items: []*feed.Item{removed, starred}}, "https://removed.com/rss")
+
+	// the two most recent items and the starred one are kept, as well as the starred item of the feed no longer configured
+	if dropped := c.Compact(conf); dropped != 2 {
+		t.Fatalf("want 2 items dropped, got %d", dropped)
+	}
+
+	feeds := c.GetFeeds()
+	if len(feeds) != 2 || len(feeds[0].Items) != 3 || len(feeds[1].Items) != 1 || !feeds[1].Items[0].Starred {
+		t.Fatalf("unexpected feeds: %+v", feeds)
+	}
+}
+
+func TestRecoverFromBackup(t *testing.T) {
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	c := NewCache()
+	c.AddFeed(&feed.Feed{Name: "Example", Url: "https://example.com/rss", Items: []*feed.Item{feed.NewItem("First", "https://example.com/1", time.Now())}}, "https://example.com/rss")
+	if err := c.Encode(); err != nil {
+		t.Fatal(err)
+	}
+	c.AddFeed(&feed.Feed{Name: "Other", Url: "https://other.com/rss", Items: []*feed.Item{feed.NewItem("Other", "https://other.com/1", time.Now())}}, "https://other.com/rss")
+	if err := c.Encode(); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := util.GetCacheFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// as if a write was interrupted half-way
+	if err := os.Truncate(path, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := NewCache()
+	if err := decoded.Decode(path); err != nil {
+		t.Fatal(err)
+	}
+	if feeds := decoded.GetFeeds(); len(feeds) != 1 || feeds[0].Name != "Example" {
+		t.Fatalf("want the feeds of the backup, got %+v", feeds)
+	}
+	if !util.Exists(path + ".corrupted") {
+		t.Errorf("corrupted file not moved aside")
+	}
+}
+
+func TestSave(t *testing.T) {
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	c := NewCache()
+	for i := 0; i < 50; i++ {
+		c.AddFeed(&feed.Feed{Name: "Example", Url: "https://example.com/rss", Items: []*feed.Item{feed.NewItem(fmt.Sprint(i), fmt.Sprintf("https://example.com/%d", i), time.Now())}}, "https://example.com/rss")
+		c.Save()
+	}
+	c.Flush()
+
+	path, err := util.GetCacheFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := NewCache()
+	if err := decoded.Decode(path); err != nil {
+		t.Fatal(err)
+	}
+	if feeds := decoded.GetFeeds(); len(feeds) != 1 || len(feeds[0].Items) != 50 {
+		t.Fatalf("last save not written: %+v", feeds)
+	}
+}
+
+func TestMergeChangesOfOtherInstances(t *testing.T) {
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	items := []*feed.Item{
+		feed.NewItem("First", "https://example.com/1", time.Now()),
+		feed.NewItem("Second", "https://example.com/2", time.Now()),
+	}
+	c := NewCache()
+	c.AddFeed(&feed.Feed{Name: "Example", Url: "https://example.com/rss", Items: items}, "https://example.com/rss")
+	if err := c.Encode(); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := util.GetCacheFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	one, other := NewCache(), NewCache()
+	if err := one.Decode(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Decode(path); err != nil {
+		t.Fatal(err)
+	}
+
+	one.GetFeeds()[0].GetItem("First").Unread = false
+	if err := one.Encode(); err != nil {
+		t.Fatal(err)
+	}
+
+	other.GetFeeds()[0].GetItem("Second").Starred = true
+	other.AddFeed(&feed.Feed{Name: "Example", Url: "https://example.com/rss", Items: []*feed.Item{feed.NewItem("Third", "https://example.com/3", time.Now())}}, "https://example.com/rss")
+	if err := other.Encode(); err != nil {
+		t.Fatal(err)
+	}
+
+	merged := NewCache()
+	if err := merged.Decode(path); err != nil {
+		t.Fatal(err)
+	}
+	f := merged.GetFeeds()[0]
+	if len(f.Items) != 3 {
+		t.Fatalf("want 3 items, got %d", len(f.Items))
+	}
+	if f.GetItem("First").Unread {
+		t.Errorf("read state of the other instance lost")
+	}
+	if !f.GetItem("Second").Starred || !f.GetItem("Second").Unread {
+		t.Errorf("starred item not kept: %+v", f.GetItem("Second"))
+	}
+}
+
+func TestSearchUpdatedIncrementally(t *testing.T) {
+
+	c := NewCache()
+	c.SetRetention(func(string) feed.Retention { return feed.Retention{MaxItems: 2} })
+	c.AddFeed(&feed.Feed{Name: "Example", Url: "https://example.com/rss", Items: []*feed.Item{
+		feed.NewItem("Linked lists", "https://example.com/1", time.Now().Add(-2*time.Hour)),
+		feed.NewItem("Binary trees", "https://example.com/2", time.Now().Add(-time.Hour)),
+	}}, "https://example.com/rss")
+
+	if r := c.Search("lists", 0, 80); len(r) != 1 || r[0].Feed.Name != "Example" {
+		t.Fatalf("unexpected results: %+v", r)
+	}
+
+	// the oldest item is dropped by the retention limits
+	c.AddFeed(&feed.Feed{Name: "Example", Url: "https://example.com/rss", Items: []*feed.Item{
+		feed.NewItem("Skip lists", "https://example.com/3", time.Now()),
+	}}, "https://example.com/rss")
+
+	if r := c.Search("lists", 0, 80); len(r) != 1 || r[0].Item.Title != "Skip lists" {
+		t.Errorf("index not updated: %+v", r)
+	}
+}
@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+// gobStore keeps the cache in a single gob file, rewritten atomically at every write keeping its previous version as a backup
+type gobStore struct {
+	// path of the cache file, the default one if empty
+	filePath string
+	// the cache file as last read or written by this instance, to detect the writes of other instances
+	seen os.FileInfo
+}
+
+func (s *gobStore) path() string {
+	if s.filePath == "" {
+		filePath, err := util.GetCacheFilePath()
+		if err != nil {
+			log.Default().Println(err)
+		}
+		s.filePath = filePath
+	}
+	return s.filePath
+}
+
+func (s *gobStore) lock() (func(), error) {
+	return util.LockDir(filepath.Dir(s.path()))
+}
+
+/*
+load reads the cache file, if any: if it is corrupted, it is moved aside with the suffix .corrupted
+and the cache is read from the backup kept by the last write
+*/
+func (s *gobStore) load() ([]*feed.Feed, error) {
+
+	filePath := s.path()
+
+	info, err := os.Stat(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return make([]*feed.Feed, 0), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	feeds, err := decodeFile(filePath)
+	if err == nil {
+		s.seen = info
+		return feeds, nil
+	}
+	if errors.Is(err, errNewerSchema) {
+		return nil, err
+	}
+
+	backupPath := util.BackupPath(filePath)
+	if !util.Exists(backupPath) {
+		return nil, err
+	}
+
+	feeds, backupErr := decodeFile(backupPath)
+	if backupErr != nil {
+		return nil, fmt.Errorf("cannot decode cache file (%w) nor its backup (%v)", err, backupErr)
+	}
+	log.Default().Printf("cannot decode cache file, recovered from backup: %v\n", err)
+
+	// otherwise, the next write would replace the backup with it
+	if err := os.Rename(filePath, filePath+".corrupted"); err != nil {
+		return nil, err
+	}
+
+	s.seen = nil
+	return feeds, nil
+}
+
+func (s *gobStore) changes() ([]*feed.Feed, error) {
+	info, err := os.Stat(s.path())
+	if err != nil || s.unchanged(info) {
+		return nil, nil
+	}
+	return decodeFile(s.path())
+}
+
+// unchanged reports whether the cache file is the one last read or written by this instance
+func (s *gobStore) unchanged(info os.FileInfo) bool {
+	return s.seen != nil &&
+		os.SameFile(s.seen, info) &&
+		s.seen.ModTime().Equal(info.ModTime()) &&
+		s.seen.Size() == info.Size()
+}
+
+func (s *gobStore) write(feeds []*feed.Feed, _ map[string]map[string]itemState) (func() error, error) {
+
+	data, err := encodeFeeds(feeds)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		filePath := s.path()
+		if err := util.BackupFile(filePath); err != nil {
+			log.Default().Println(err)
+		}
+		if err := util.WriteFileAtomic(filePath, data, 0644); err != nil {
+			return err
+		}
+
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return err
+		}
+		s.seen = info
+		return nil
+	}, nil
+}
+
+func (s *gobStore) items(q Query) ([]*Entry, error) {
+	feeds, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return query(feeds, q), nil
+}
+
+func (s *gobStore) remove() error {
+	for _, p := range []string{util.BackupPath(s.path()), s.path()} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// shrink does nothing, since the cache file is rewritten from scratch at every write
+func (s *gobStore) shrink() error {
+	return nil
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"encoding/json"
+	"hash/fnv"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+)
+
+// the state of an item which can be changed by the user, along with a hash of the whole item
+type itemState struct {
+	Unread  bool
+	Starred bool
+	// changes along with any field of the item, e.g. its content backfilled by a reload
+	Sum uint64
+}
+
+func stateOf(i *feed.Item) itemState {
+	s := itemState{Unread: i.Unread, Starred: i.Starred}
+	if data, err := json.Marshal(i); err == nil {
+		s.Sum = sumOf(data)
+	}
+	return s
+}
+
+// sumOf returns the hash of an encoded item
+func sumOf(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
+
+// snapshot returns the state of the cached items, by feed url and item title
+func (c *Cache) snapshot() map[string]map[string]itemState {
+	return snapshotOf(c.feeds)
+}
+
+func snapshotOf(feeds []*feed.Feed) map[string]map[string]itemState {
+	states := make(map[string]map[string]itemState, len(feeds))
+	for _, f := range feeds {
+		items := make(map[string]itemState, len(f.Items))
+		for _, i := range f.Items {
+			items[i.Title] = stateOf(i)
+		}
+		states[f.Url] = items
+	}
+	return states
+}
+
+/*
+merge brings in the changes written to the cache file by another instance since it was last read or written by this one:
+feeds and items fetched by the other instance are added, unless they were removed by this one,
+and the other instance's read and starred state is taken for the items left untouched by this one;
+items changed by both are read if read by either, and starred if starred by either
+*/
+func (c *Cache) merge(onDisk []*feed.Feed) {
+
+	for _, diskFeed := range onDisk {
+
+		syncedItems, known := c.synced[diskFeed.Url]
+
+		var cachedFeed *feed.Feed
+		for _, f := range c.feeds {
+			if f.Url == diskFeed.Url {
+				cachedFeed = f
+				break
+			}
+		}
+		if cachedFeed == nil {
+			if !known {
+				c.feeds = append(c.feeds, diskFeed)
+			}
+			continue
+		}
+
+		if diskFeed.LastRefresh.After(cachedFeed.LastRefresh) {
+			cachedFeed.LastRefresh = diskFeed.LastRefresh
+			cachedFeed.TTL = diskFeed.TTL
+		}
+
+		for _, diskItem := range diskFeed.Items {
+
+			synced, wasSynced := syncedItems[diskItem.Title]
+
+			cachedItem := cachedFeed.GetItem(diskItem.Title)
+			if cachedItem == nil {
+				if !wasSynced {
+					cachedFeed.Items = append(cachedFeed.Items, diskItem)
+				}
+				continue
+			}
+
+			// a change of any other field, e.g. by a reload, is no change of the state
+			if wasSynced && cachedItem.Unread == synced.Unread && cachedItem.Starred == synced.Starred {
+				cachedItem.Unread = diskItem.Unread
+				cachedItem.Starred = diskItem.Starred
+			} else {
+				cachedItem.Unread = cachedItem.Unread && diskItem.Unread
+				cachedItem.Starred = cachedItem.Starred || diskItem.Starred
+			}
+
+			if cachedItem.Starred && cachedItem.Article == nil {
+				cachedItem.Article = diskItem.Article
+			} else if !cachedItem.Starred {
+				cachedItem.Article = nil
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"errors"
+	"os"
+
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+// suffix of the gob file once copied into a database, so that it is not copied again
+const migratedSuffix = ".migrated"
+
+// MigrateGob moves the cache from the default gob file into a new database, returning how many items were copied
+func MigrateGob() (int, error) {
+
+	src := NewCache()
+	if !util.Exists(src.FilePath()) {
+		return 0, errors.New("no cache file to migrate: " + src.FilePath())
+	}
+
+	dst, err := Open(BOLT_BACKEND)
+	if err != nil {
+		return 0, err
+	}
+	if util.Exists(dst.FilePath()) {
+		return 0, errors.New("database already exists: " + dst.FilePath())
+	}
+
+	if err := src.Load(); err != nil {
+		return 0, err
+	}
+
+	var count int
+	for _, f := range src.GetFeeds() {
+		count += len(f.Items)
+	}
+
+	dst.mu.Lock()
+	dst.feeds = src.feeds
+	dst.loaded = true
+	dst.mu.Unlock()
+
+	if err := dst.Encode(); err != nil {
+		return 0, err
+	}
+	// otherwise, it would be copied again on the next start if the database is removed
+	return count, os.Rename(src.FilePath(), src.FilePath()+migratedSuffix)
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"strings"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/sqlite"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+// tables of the cache database of newsboat
+const (
+	newsboatFeeds = "rss_feed"
+	newsboatItems = "rss_item"
+)
+
+/*
+ImportNewsboat adds the feeds and items found in the cache database of newsboat (cache.db), skipping the items it deleted:
+its unread items are unread and its flagged items are starred. Items already cached are kept, read if read in either and
+starred if starred in either. It returns the titles of the imported feeds by url and the number of items added.
+see: https://newsboat.org/releases/2.36/docs/newsboat.html#_flagging_articles
+*/
+func (c *Cache) ImportNewsboat(dbPath string) (map[string]string, int, error) {
+
+	db, err := sqlite.Open(dbPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer db.Close()
+
+	titles := make(map[string]string)
+	urls := make([]string, 0)
+	if err := db.Scan(newsboatFeeds, func(r sqlite.Row) error {
+		url := text(r["rssurl"])
+		titles[url] = strings.TrimSpace(text(r["title"]))
+		urls = append(urls, url)
+		return nil
+	}); err != nil {
+		return nil, 0, err
+	}
+
+	items := make(map[string][]*feed.Item)
+	if err := db.Scan(newsboatItems, func(r sqlite.Row) error {
+
+		if integer(r["deleted"]) != 0 {
+			return nil
+		}
+
+		pubDate := util.NoPubDate
+		if secs := integer(r["pubDate"]); secs > 0 {
+			pubDate = time.Unix(secs, 0).UTC()
+		}
+
+		i := feed.NewItem(text(r["title"]), text(r["url"]), pubDate)
+		i.Content = text(r["content"])
+		i.Unread = integer(r["unread"]) != 0
+		i.Starred = text(r["flags"]) != ""
+		if url := text(r["enclosure_url"]); url != "" {
+			i.Enclosures = append(i.Enclosures, &feed.Enclosure{Url: url, Type: text(r["enclosure_type"])})
+		}
+
+		feedUrl := text(r["feedurl"])
+		items[feedUrl] = append(items[feedUrl], i)
+		return nil
+	}); err != nil {
+		return nil, 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	added := 0
+	for _, url := range urls {
+
+		cached := c.getFeed(url)
+		if cached == nil {
+			cached = feed.NewFeed(titles[url]).WithUrl(url)
+			c.feeds = append(c.feeds, cached)
+		}
+
+		for _, i := range items[url] {
+			if existing := cached.GetItem(i.Title); existing != nil {
+				existing.Unread = existing.Unread && i.Unread
+				existing.Starred = existing.Starred || i.Starred
+				continue
+			}
+			cached.Items = append(cached.Items, i)
+			added++
+		}
+		cached.CountUnread()
+	}
+
+	c.index = nil
+	return titles, added, nil
+}
+
+// getFeed returns the cached feed with the given url, if any
+func (c *Cache) getFeed(url string) *feed.Feed {
+	for _, f := range c.feeds {
+		if f.Url == url {
+			return f
+		}
+	}
+	return nil
+}
+
+// text returns a value read from a database as a string, empty if null
+func text(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return ""
+}
+
+// integer returns a value read from a database as an integer, zero if null
+func integer(v any) int64 {
+	switch v := v.(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
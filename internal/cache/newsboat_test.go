@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+// testdata/newsboat-cache.db has the schema written by newsboat, including the columns added by its later versions
+func TestImportNewsboat(t *testing.T) {
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	c := NewCache()
+	already := feed.NewItem("Security updates", "https://lwn.net/Articles/2/", util.NoPubDate)
+	already.Unread = true
+	c.AddFeed(&feed.Feed{Name: "LWN", Url: "https://lwn.net/headlines/rss", Items: []*feed.Item{already}}, "https://lwn.net/headlines/rss")
+
+	feedTitles, added, err := c.ImportNewsboat("testdata/newsboat-cache.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 2 {
+		t.Errorf("got %d items added, want 2", added)
+	}
+	if feedTitles["https://example.com/podcast.xml"] != "Example Podcast" {
+		t.Errorf("unexpected titles: %v", feedTitles)
+	}
+
+	feeds := c.GetFeeds()
+	if len(feeds) != 2 {
+		t.Fatalf("got %d feeds, want 2", len(feeds))
+	}
+
+	lwn := feeds[0]
+	if lwn.Name != "LWN" || len(lwn.Items) != 2 || lwn.HasItem("Deleted article") {
+		t.Fatalf("unexpected feed: %+v", lwn)
+	}
+	if already.Unread || !already.Starred {
+		t.Errorf("read and flagged in newsboat, got unread=%v starred=%v", already.Unread, already.Starred)
+	}
+	kernel := lwn.GetItem("Kernel release status")
+	if !kernel.Unread || kernel.Starred || len(kernel.Content) < 5000 || kernel.PubDate.Unix() != 1723507200 {
+		t.Errorf("unexpected item: %s %v %v", kernel.Title, kernel.Unread, kernel.PubDate)
+	}
+	if lwn.UnreadCount != 1 {
+		t.Errorf("got %d unread items, want 1", lwn.UnreadCount)
+	}
+
+	podcast := feeds[1]
+	episode := podcast.GetItem("Episode 1")
+	if podcast.Name != "Example Podcast" || episode == nil || len(episode.Enclosures) != 1 || episode.Enclosures[0].Type != "audio/mpeg" {
+		t.Errorf("unexpected feed: %+v", podcast)
+	}
+
+	if results := c.Search("kernel", 0, 40); len(results) != 1 {
+		t.Errorf("got %d search results, want 1", len(results))
+	}
+}
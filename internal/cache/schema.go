@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/app"
+	"github.com/giulianopz/newscanoe/internal/feed"
+)
+
+/*
+schemaVersion is the version of the content of the cache file written by this version of the app:
+gob matches struct fields by name, so fields can be added to feed.Feed and feed.Item without a new version,
+unless the data written by older versions needs to be filled in or fixed: then bump the version,
+append to migrations a function bringing the previous version up to the new one
+and add to testdata a fixture written by the previous version
+*/
+const schemaVersion = 2
+
+// cacheFile is the content of the cache file
+type cacheFile struct {
+	Version int
+	// when the file was written
+	Saved time.Time
+	Feeds []*feed.Feed
+}
+
+// migrations[i] brings the content of the cache file from version i+1 to version i+2
+var migrations = []func(*cacheFile){
+	// version 1 was the bare list of feeds, written before feeds kept the time of their last refresh:
+	// the feeds are assumed to be fetched when the file was written, so that the new items found by the next refresh are notified
+	func(cf *cacheFile) {
+		for _, f := range cf.Feeds {
+			if f.LastRefresh.IsZero() {
+				f.LastRefresh = cf.Saved
+			}
+			f.CountUnread()
+		}
+	},
+}
+
+// encodeFeeds returns the content of the cache file holding the given feeds
+func encodeFeeds(feeds []*feed.Feed) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&cacheFile{
+		Version: schemaVersion,
+		Saved:   time.Now(),
+		Feeds:   feeds,
+	})
+	return buf.Bytes(), err
+}
+
+// decodeFile returns the feeds in a cache file, migrating them from the version it was written with to the current one
+func decodeFile(filePath string) ([]*feed.Feed, error) {
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var cf cacheFile
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&cf); err != nil {
+		// written before the version was stored
+		var feeds []*feed.Feed
+		if legacyErr := gob.NewDecoder(bytes.NewReader(data)).Decode(&feeds); legacyErr != nil {
+			return nil, err
+		}
+
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return nil, err
+		}
+		cf = cacheFile{Version: 1, Saved: info.ModTime(), Feeds: feeds}
+	}
+
+	if err := migrate(&cf); err != nil {
+		return nil, err
+	}
+	return cf.Feeds, nil
+}
+
+// errNewerSchema is returned for cache files written by a newer version of the app, which must not be overwritten
+var errNewerSchema = errors.New("cache file written by a newer version of " + app.Name)
+
+// migrate brings the content of a cache file up to the current version
+func migrate(cf *cacheFile) error {
+
+	if cf.Version < 1 {
+		return fmt.Errorf("invalid cache file version: %d", cf.Version)
+	}
+	if cf.Version > schemaVersion {
+		return fmt.Errorf("%w (version %d, supported up to %d)", errNewerSchema, cf.Version, schemaVersion)
+	}
+
+	for ; cf.Version < schemaVersion; cf.Version++ {
+		migrations[cf.Version-1](cf)
+	}
+	return nil
+}
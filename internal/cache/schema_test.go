@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+)
+
+func TestMigrations(t *testing.T) {
+	if len(migrations) != schemaVersion-1 {
+		t.Fatalf("want %d migrations for schema version %d, got %d", schemaVersion-1, schemaVersion, len(migrations))
+	}
+}
+
+// every fixture was written by the version of the app which introduced its schema version
+func TestDecodeFixtures(t *testing.T) {
+
+	tests := []struct {
+		file  string
+		check func(t *testing.T, f *feed.Feed)
+	}{
+		{
+			file: "feeds-v1.gob",
+			check: func(t *testing.T, f *feed.Feed) {
+				info, err := os.Stat(filepath.Join("testdata", "feeds-v1.gob"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !f.LastRefresh.Equal(info.ModTime()) {
+					t.Errorf("want last refresh set to the time the file was written, got %v", f.LastRefresh)
+				}
+			},
+		},
+		{
+			file: "feeds-v2.gob",
+			check: func(t *testing.T, f *feed.Feed) {
+				if f.TTL != time.Hour || !f.LastRefresh.Equal(time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)) {
+					t.Errorf("unexpected refresh state: ttl %v, last refresh %v", f.TTL, f.LastRefresh)
+				}
+				unread, read := f.GetItem("Unread article"), f.GetItem("Read article")
+				if len(unread.Enclosures) != 1 || unread.Enclosures[0].Type != "audio/mpeg" {
+					t.Errorf("unexpected enclosures: %+v", unread.Enclosures)
+				}
+				if !read.Starred || read.Content != "<p>Content</p>" || read.Article == nil || read.Article.Blocks[0].Text != "Saved text" {
+					t.Errorf("unexpected starred item: %+v", read)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+
+			feeds, err := decodeFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(feeds) != 1 || feeds[0].Name != "Example" || feeds[0].Url != "https://example.com/rss" {
+				t.Fatalf("unexpected feeds: %+v", feeds)
+			}
+
+			f := feeds[0]
+			if len(f.Items) != 2 || f.UnreadCount != 1 {
+				t.Fatalf("unexpected items: %+v", f.Items)
+			}
+			if i := f.GetItem("Unread article"); i == nil || !i.Unread || !i.PubDate.Equal(time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC)) {
+				t.Errorf("unexpected unread item: %+v", i)
+			}
+			if i := f.GetItem("Read article"); i == nil || i.Unread || i.Url != "https://example.com/read" {
+				t.Errorf("unexpected read item: %+v", i)
+			}
+			tt.check(t, f)
+		})
+	}
+}
+
+func TestRefuseNewerSchema(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "feeds.gob")
+
+	// as if written by a newer version
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&cacheFile{Version: schemaVersion + 1, Feeds: []*feed.Feed{{Name: "Example"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewCache().Decode(path); !errors.Is(err, errNewerSchema) {
+		t.Fatalf("want newer schema error, got %v", err)
+	}
+	if after, err := os.ReadFile(path); err != nil || !bytes.Equal(after, buf.Bytes()) {
+		t.Errorf("cache file moved or changed: %v", err)
+	}
+}
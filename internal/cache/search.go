@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/search"
+	"golang.org/x/exp/slices"
+)
+
+// SearchResult is a cached item matching a search, together with its feed and a passage of its text around the match
+type SearchResult struct {
+	Feed    *feed.Feed
+	Item    *feed.Item
+	Snippet string
+}
+
+/*
+Search returns the cached items containing every word of a query, or words beginning with them, from the best match:
+the index is built at the first search, and then updated as items are added or dropped
+*/
+func (c *Cache) Search(query string, limit, snippetWidth int) []*SearchResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.index == nil {
+		c.index = search.NewIndex()
+		for _, f := range c.feeds {
+			for _, i := range f.Items {
+				c.index.Add(f.Url, i)
+			}
+		}
+	}
+
+	results := make([]*SearchResult, 0)
+	for _, m := range c.index.Search(query) {
+		if limit > 0 && len(results) == limit {
+			break
+		}
+		for _, f := range c.feeds {
+			if f.Url != m.FeedUrl {
+				continue
+			}
+			if i := f.GetItem(m.Title); i != nil {
+				results = append(results, &SearchResult{
+					Feed:    f,
+					Item:    i,
+					Snippet: search.Snippet(search.Text(i), query, snippetWidth),
+				})
+			}
+			break
+		}
+	}
+	return results
+}
+
+// Reindex updates the search index after the text of a cached item has changed, e.g. when starring it
+func (c *Cache) Reindex(feedUrl string, i *feed.Item) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.index != nil {
+		c.index.Add(feedUrl, i)
+	}
+}
+
+// reindex updates the search index, if built, after the given items were added to a feed whose items were the previous ones
+func (c *Cache) reindex(f *feed.Feed, previous []*feed.Item, added []*feed.Item) {
+	if c.index == nil {
+		return
+	}
+	for _, i := range previous {
+		if !slices.Contains(f.Items, i) {
+			c.index.Remove(f.Url, i.Title)
+		}
+	}
+	for _, i := range added {
+		c.index.Add(f.Url, i)
+	}
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"golang.org/x/exp/slices"
+)
+
+// backends storing the cache
+const (
+	// a single gob file rewritten at every save, fine for small setups
+	GOB_BACKEND = "gob"
+	// an embedded database updated item by item, and queried by feed, unread state and date
+	BOLT_BACKEND = "bolt"
+)
+
+/*
+store is where the cache is persisted: every method but lock is called by the cache
+while holding the lock taken by lock, which excludes the other instances of the app
+*/
+type store interface {
+	lock() (unlock func(), err error)
+	// load returns the cached feeds
+	load() ([]*feed.Feed, error)
+	// changes returns the cached feeds, if written by another instance since they were last loaded or written by this one, or just the ones it changed
+	changes() ([]*feed.Feed, error)
+	/*
+		write prepares the writing of the cached feeds, given the state of their items as last loaded or written:
+		it is called holding the mutex of the cache, while the returned function, which writes them, is called after releasing it
+	*/
+	write(feeds []*feed.Feed, synced map[string]map[string]itemState) (func() error, error)
+	// items returns the cached items matching a query, from the most recent
+	items(q Query) ([]*Entry, error)
+	// remove removes every file of the store
+	remove() error
+	// path returns the path of the main file of the store
+	path() string
+	// shrink gives back to the file system the space left unused by the items dropped
+	shrink() error
+}
+
+// Query selects cached items
+type Query struct {
+	// the url of the feed of the items, any feed if empty
+	FeedUrl string
+	// only the unread items
+	Unread bool
+	// only the items published since then, if not zero
+	Since time.Time
+	// the max number of items, no limit if zero
+	Limit int
+}
+
+// Entry is a cached item together with its feed
+type Entry struct {
+	Feed *feed.Feed
+	Item *feed.Item
+}
+
+func (q Query) matchesFeed(f *feed.Feed) bool {
+	return q.FeedUrl == "" || f.Url == q.FeedUrl
+}
+
+func (q Query) matchesItem(i *feed.Item) bool {
+	return (!q.Unread || i.Unread) && (q.Since.IsZero() || !i.PubDate.Before(q.Since))
+}
+
+// sortAndLimit orders entries from the most recent and applies the limit of the query
+func (q Query) sortAndLimit(entries []*Entry) []*Entry {
+	slices.SortStableFunc(entries, func(a, b *Entry) int {
+		return b.Item.PubDate.Compare(a.Item.PubDate)
+	})
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+	}
+	return entries
+}
+
+// query returns the items of the given feeds matching a query
+func query(feeds []*feed.Feed, q Query) []*Entry {
+	entries := make([]*Entry, 0)
+	for _, f := range feeds {
+		if !q.matchesFeed(f) {
+			continue
+		}
+		for _, i := range f.Items {
+			if q.matchesItem(i) {
+				entries = append(entries, &Entry{Feed: f, Item: i})
+			}
+		}
+	}
+	return q.sortAndLimit(entries)
+}
+
+// newStore returns the store of the given backend, at its default path
+func newStore(backend string) (store, error) {
+	switch backend {
+	case "", GOB_BACKEND:
+		return &gobStore{}, nil
+	case BOLT_BACKEND:
+		return &boltStore{}, nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend: %q", backend)
+	}
+}
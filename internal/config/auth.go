@@ -0,0 +1,157 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+// keys of the authentication settings, only honored when set for a single feed
+const (
+	AUTH_USER     = "auth-user"
+	AUTH_PASSWORD = "auth-password"
+	AUTH_TOKEN    = "auth-token"
+	COOKIE_FILE   = "cookie-file"
+	// prefix of the keys setting custom headers, e.g. #"header-X-Api-Key=env:API_KEY"
+	HEADER_PREFIX = "header-"
+)
+
+// prefixes of the values resolved at runtime instead of being stored in plaintext
+const (
+	// the value of an environment variable, e.g. env:FEED_TOKEN
+	envPrefix = "env:"
+	// the first line printed by a command, e.g. cmd:pass show feeds/example
+	cmdPrefix = "cmd:"
+)
+
+// secrets already resolved, so that commands like pass are run only once
+var (
+	secretsMu sync.Mutex
+	secrets   = make(map[string]string)
+)
+
+// Auth returns the credentials to be sent to fetch the feed with the given url, nil if there are none
+func (c *Config) Auth(url string) (*feed.Auth, error) {
+
+	// the settings of a feed are replaced as a whole when merging, never changed, so they can be read once taken
+	c.mu.Lock()
+	settings := c.feedSettings[url]
+	c.mu.Unlock()
+
+	if len(settings) == 0 {
+		return nil, nil
+	}
+
+	auth := &feed.Auth{}
+	var set bool
+
+	for key, value := range settings {
+
+		var err error
+		switch {
+		case key == AUTH_USER:
+			auth.User, err = resolveSecret(value)
+		case key == AUTH_PASSWORD:
+			auth.Password, err = resolveSecret(value)
+		case key == AUTH_TOKEN:
+			auth.Token, err = resolveSecret(value)
+		case key == COOKIE_FILE:
+			auth.Cookies, err = feed.LoadCookies(util.ExpandHome(value))
+		case strings.HasPrefix(key, HEADER_PREFIX) && len(key) > len(HEADER_PREFIX):
+			var v string
+			v, err = resolveSecret(value)
+			if auth.Header == nil {
+				auth.Header = make(http.Header)
+			}
+			auth.Header.Set(strings.TrimPrefix(key, HEADER_PREFIX), v)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve %s of feed %s: %w", key, url, err)
+		}
+		set = true
+	}
+
+	if !set {
+		return nil, nil
+	}
+	return auth, nil
+}
+
+/*
+ResolveSecrets resolves the credentials of every feed, so that the commands giving them run once and before the app
+takes over the terminal, since they may prompt for a passphrase: the ones failing are logged and run again when needed
+*/
+func (c *Config) ResolveSecrets() {
+
+	c.mu.Lock()
+	urls := make([]string, 0, len(c.feedSettings))
+	for url := range c.feedSettings {
+		urls = append(urls, url)
+	}
+	c.mu.Unlock()
+
+	for _, url := range urls {
+		if _, err := c.Auth(url); err != nil {
+			log.Default().Println(err)
+		}
+	}
+}
+
+// Credentials returns the user and the password set by the given settings, e.g. to log in to the sync server, resolved as the credentials of feeds
+func (c *Config) Credentials(userKey, passwordKey string) (string, string, error) {
+	user, err := resolveSecret(c.Get(userKey))
+	if err != nil {
+		return "", "", fmt.Errorf("cannot resolve %s: %w", userKey, err)
+	}
+	password, err := resolveSecret(c.Get(passwordKey))
+	if err != nil {
+		return "", "", fmt.Errorf("cannot resolve %s: %w", passwordKey, err)
+	}
+	return user, password, nil
+}
+
+// resolveSecret returns the value of a setting, looking it up in the environment or running a command if so prefixed
+func resolveSecret(value string) (string, error) {
+
+	switch {
+	case strings.HasPrefix(value, envPrefix):
+		name := strings.TrimPrefix(value, envPrefix)
+		v, found := os.LookupEnv(name)
+		if !found {
+			return "", fmt.Errorf("environment variable not set: %s", name)
+		}
+		return v, nil
+	case strings.HasPrefix(value, cmdPrefix):
+		secretsMu.Lock()
+		defer secretsMu.Unlock()
+
+		if v, found := secrets[value]; found {
+			return v, nil
+		}
+
+		var stderr bytes.Buffer
+		cmd := exec.Command("sh", "-c", strings.TrimPrefix(value, cmdPrefix))
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", util.CmdError(err), strings.TrimSpace(stderr.String()))
+		}
+
+		v, _, _ := strings.Cut(string(out), "\n")
+		v = strings.TrimRight(v, "\r")
+		secrets[value] = v
+		return v, nil
+	default:
+		return value, nil
+	}
+}
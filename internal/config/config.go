@@ -2,80 +2,396 @@ package config
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
+	"unicode"
 
 	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/hooks"
 	"github.com/giulianopz/newscanoe/internal/util"
+	"golang.org/x/exp/slices"
 )
 
+/*
+Config holds the content of the config file, made of:
+  - feed lines, i.e. a url followed by a name: https://example.com/rss #"Example"
+    and optionally by settings overriding the global ones for this feed only: #"proxy=socks5://localhost:1080"
+    where the url can also be a local file (file://), a command (exec:) or a command filtering a url (filter:),
+    quoted if containing spaces: "exec:~/bin/feed.sh --all" #"Generated"
+  - setting lines, i.e. a key followed by a value: set browser "firefox %u"
+  - macro lines, i.e. a key bound to a command: macro y bg "echo %u | wl-copy"
+  - hook lines, i.e. a command or an endpoint receiving new items, optionally filtered: hook post "https://example.com/hook" "feed=LWN"
+  - comment lines, starting with a pound sign (#)
+*/
 type Config struct {
 	mu    sync.Mutex
 	Feeds []*feed.Feed
+
+	Settings map[string]string
+	Macros   []*Macro
+	Hooks    []*hooks.Hook
+
+	// settings overriding the global ones for a single feed, by url
+	feedSettings map[string]map[string]string
+	// annotations following the name of a feed, by url
+	annotations map[string][]string
+
+	// every line, preserved as it is when the file is written back unless it is the line of a feed which changed
+	lines []*fileLine
+
+	// the config file as last read or written, to detect the changes of other instances or editors
+	seen os.FileInfo
+	// urls of the feeds in the config file as last read or written
+	known map[string]bool
 }
 
-func (c *Config) Encode() error {
+// fileLine is a line of the config file
+type fileLine struct {
+	text string
+	// the url of the feed, if a feed line
+	feedUrl string
+	// the feed line as it would be written back when read, to tell whether the feed changed since
+	rendered string
+}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// Macro binds a key to a command to be run for the current url
+type Macro struct {
+	Key byte
+	Cmd util.Command
+}
+
+const (
+	setDirective   = "set"
+	macroDirective = "macro"
+	hookDirective  = "hook"
+)
+
+var (
+	feedLinePattern = regexp.MustCompile(`^("(?:[^"\\]|\\.)*"|\S+)((\s+#"(?:[^"\\]|\\.)*")+)\s*$`)
+	quotedPattern   = regexp.MustCompile(`#("(?:[^"\\]|\\.)*")`)
+	argPattern      = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|\S+`)
+)
+
+func NewConfig() *Config {
+	return &Config{
+		Feeds:        make([]*feed.Feed, 0),
+		Settings:     make(map[string]string),
+		Macros:       make([]*Macro, 0),
+		Hooks:        make([]*hooks.Hook, 0),
+		feedSettings: make(map[string]map[string]string),
+		annotations:  make(map[string][]string),
+		known:        make(map[string]bool),
+	}
+}
+
+func (c *Config) Encode() error {
 
 	filePath, err := util.GetConfigFilePath()
 	if err != nil {
 		return err
 	}
 
-	f, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+	unlock, err := util.LockDir(filepath.Dir(filePath))
 	if err != nil {
-		slog.Error("cannot open config file", "err", err)
 		return err
 	}
-	defer f.Close()
+	defer unlock()
 
-	for _, feed := range c.Feeds {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-		_, err := fmt.Fprintf(f, "%s #%q\n", feed.Url, feed.Name)
-		if err != nil {
-			slog.Error("cannot write to config file", "err", err)
+	if info, err := os.Stat(filePath); err == nil && !c.unchanged(info) {
+		if err := c.merge(filePath); err != nil {
+			slog.Error("cannot merge config file changed meanwhile", "err", err)
 			return err
 		}
 	}
 
-	if err := f.Sync(); err != nil {
-		slog.Error("cannot sync config file", "err", err)
+	feeds := make(map[string]*feed.Feed, len(c.Feeds))
+	for _, f := range c.Feeds {
+		feeds[f.Url] = f
+	}
+
+	// feeds are written where they were found, so that comments stay next to them, and the new ones at the end
+	var f bytes.Buffer
+	written := make(map[string]bool, len(c.Feeds))
+	for _, l := range c.lines {
+		if l.feedUrl == "" {
+			fmt.Fprintln(&f, l.text)
+			continue
+		}
+		cf, found := feeds[l.feedUrl]
+		if !found || written[l.feedUrl] {
+			// removed meanwhile or duplicated
+			continue
+		}
+		written[l.feedUrl] = true
+		if line := c.feedLine(cf); line != l.rendered {
+			fmt.Fprintln(&f, line)
+		} else {
+			fmt.Fprintln(&f, l.text)
+		}
+	}
+	for _, cf := range c.Feeds {
+		if !written[cf.Url] {
+			fmt.Fprintln(&f, c.feedLine(cf))
+		}
+	}
+
+	// never leave a truncated config file behind
+	if err := util.WriteFileAtomic(filePath, f.Bytes(), 0644); err != nil {
+		slog.Error("cannot write to config file", "err", err)
 		return err
 	}
 
-	return nil
+	return c.sync(filePath)
 }
 
 func (c *Config) Decode(filePath string) error {
 
+	unlock, err := util.LockDir(filepath.Dir(filePath))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if err := c.read(filePath); err != nil {
+		return err
+	}
+	return c.sync(filePath)
+}
+
+// read parses the config file, replacing what was read before
+func (c *Config) read(filePath string) error {
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return err
 	}
+	defer file.Close()
 
 	c.Feeds = make([]*feed.Feed, 0)
+	c.Settings = make(map[string]string)
+	c.Macros = make([]*Macro, 0)
+	c.Hooks = make([]*hooks.Hook, 0)
+	c.feedSettings = make(map[string]map[string]string)
+	c.annotations = make(map[string][]string)
+	c.lines = make([]*fileLine, 0)
 
 	s := bufio.NewScanner(file)
 	for s.Scan() {
+		if err := c.parseLine(s.Text()); err != nil {
+			return err
+		}
+	}
+
+	return s.Err()
+}
+
+// sync records the config file as just read or written
+func (c *Config) sync(filePath string) error {
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	c.seen = info
+
+	c.known = make(map[string]bool, len(c.Feeds))
+	for _, f := range c.Feeds {
+		c.known[f.Url] = true
+	}
+	return nil
+}
+
+// unchanged reports whether the config file is the one last read or written
+func (c *Config) unchanged(info os.FileInfo) bool {
+	return c.seen != nil &&
+		os.SameFile(c.seen, info) &&
+		c.seen.ModTime().Equal(info.ModTime()) &&
+		c.seen.Size() == info.Size()
+}
+
+/*
+merge reads again the config file changed by another instance or by an editor since it was last read or written,
+keeping only the feeds added meanwhile by this instance on top of it
+*/
+func (c *Config) merge(filePath string) error {
+
+	onDisk := NewConfig()
+	if err := onDisk.read(filePath); err != nil {
+		return err
+	}
+
+	for _, f := range c.Feeds {
+		if c.known[f.Url] {
+			continue
+		}
+		if err := onDisk.AddFeed(f, f.Url); err != nil {
+			continue
+		}
+		if a, ok := c.annotations[f.Url]; ok {
+			onDisk.annotations[f.Url] = a
+		}
+		if fs, ok := c.feedSettings[f.Url]; ok {
+			onDisk.feedSettings[f.Url] = fs
+		}
+	}
+
+	c.Feeds = onDisk.Feeds
+	c.Settings = onDisk.Settings
+	c.Macros = onDisk.Macros
+	c.Hooks = onDisk.Hooks
+	c.feedSettings = onDisk.feedSettings
+	c.annotations = onDisk.annotations
+	c.lines = onDisk.lines
+	return nil
+}
+
+// CheckLine returns an error if the given line is not valid in the config file
+func CheckLine(line string) error {
+	return NewConfig().parseLine(line)
+}
+
+func (c *Config) parseLine(line string) error {
 
-		f := &feed.Feed{}
-		_, err := fmt.Sscanf(s.Text(), "%s #%q", &f.Url, &f.Name)
+	trimmed := strings.TrimSpace(line)
+
+	switch fields := strings.Fields(trimmed); {
+	case trimmed == "", strings.HasPrefix(trimmed, "#"):
+		c.lines = append(c.lines, &fileLine{text: line})
+
+	case fields[0] == setDirective:
+		if len(fields) < 3 {
+			return fmt.Errorf("missing value in line: %q", line)
+		}
+		value, err := unquote(afterFields(trimmed, 2))
+		if err != nil {
+			return err
+		}
+		c.Settings[fields[1]] = value
+		c.lines = append(c.lines, &fileLine{text: line})
+
+	case fields[0] == macroDirective:
+		if len(fields) < 4 || len(fields[1]) != 1 {
+			return fmt.Errorf("macro must bind a single key to a mode and a command: %q", line)
+		}
+		if fields[2] != util.Foreground && fields[2] != util.Background {
+			return fmt.Errorf("macro mode must be either %q or %q: %q", util.Foreground, util.Background, line)
+		}
+		template, err := unquote(afterFields(trimmed, 3))
+		if err != nil {
+			return err
+		}
+		c.Macros = append(c.Macros, &Macro{
+			Key: fields[1][0],
+			Cmd: util.Command{Template: template, Mode: fields[2]},
+		})
+		c.lines = append(c.lines, &fileLine{text: line})
+
+	case fields[0] == hookDirective:
+		if len(fields) < 3 {
+			return fmt.Errorf("hook must bind a kind to a command or a url: %q", line)
+		}
+		args := make([]string, 0)
+		for _, a := range argPattern.FindAllString(afterFields(trimmed, 2), -1) {
+			arg, err := unquote(a)
+			if err != nil {
+				return err
+			}
+			args = append(args, arg)
+		}
+		h, err := hooks.New(fields[1], args[0], args[1:]...)
+		if err != nil {
+			return err
+		}
+		c.Hooks = append(c.Hooks, h)
+		c.lines = append(c.lines, &fileLine{text: line})
+
+	default:
+		matches := feedLinePattern.FindStringSubmatch(trimmed)
+		if matches == nil {
+			return fmt.Errorf("line does not respect the pattern: %q", line)
+		}
+		url, err := unquote(matches[1])
 		if err != nil {
 			return err
 		}
+		groups := quotedPattern.FindAllStringSubmatch(matches[2], -1)
+		name, err := strconv.Unquote(groups[0][1])
+		if err != nil {
+			return err
+		}
+
+		for _, g := range groups[1:] {
+			a, err := strconv.Unquote(g[1])
+			if err != nil {
+				return err
+			}
+			c.annotations[url] = append(c.annotations[url], a)
+
+			if key, value, found := strings.Cut(a, "="); found {
+				if c.feedSettings[url] == nil {
+					c.feedSettings[url] = make(map[string]string)
+				}
+				c.feedSettings[url][strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+
+		f := feed.NewFeed(name).WithUrl(url)
 		c.Feeds = append(c.Feeds, f)
+		c.lines = append(c.lines, &fileLine{text: line, feedUrl: url, rendered: c.feedLine(f)})
 	}
-
 	return nil
 }
 
+// feedLine returns the line of a feed as written in the config file, i.e. its url followed by its name and its annotations
+func (c *Config) feedLine(f *feed.Feed) string {
+	line := fmt.Sprintf("%s #%q", quoteSource(f.Url), f.Name)
+	for _, a := range c.annotations[f.Url] {
+		line += fmt.Sprintf(" #%q", a)
+	}
+	return line
+}
+
+// quoteSource quotes the source of a feed if it contains spaces, like the command of exec: and filter: sources
+func quoteSource(url string) string {
+	if strings.ContainsAny(url, " \t\"") {
+		return strconv.Quote(url)
+	}
+	return url
+}
+
+// afterFields returns what follows the first n fields of a line
+func afterFields(line string, n int) string {
+	for ; n > 0; n-- {
+		line = strings.TrimSpace(line)
+		idx := strings.IndexFunc(line, unicode.IsSpace)
+		if idx == -1 {
+			return ""
+		}
+		line = line[idx:]
+	}
+	return strings.TrimSpace(line)
+}
+
+func unquote(value string) (string, error) {
+	if strings.HasPrefix(value, `"`) {
+		return strconv.Unquote(value)
+	}
+	return value, nil
+}
+
 func (c *Config) AddFeed(parsedFeed *feed.Feed, url string) error {
 	for _, f := range c.Feeds {
 		if f.Url == url {
@@ -85,3 +401,68 @@ func (c *Config) AddFeed(parsedFeed *feed.Feed, url string) error {
 	c.Feeds = append(c.Feeds, parsedFeed)
 	return nil
 }
+
+// Get returns the value set for a key, if any, or its default value
+func (c *Config) Get(key string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.get(key)
+}
+
+// FeedGet returns the value set for a key by the given feed, if any, or else as Get does
+func (c *Config) FeedGet(url, key string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if v, found := c.feedSettings[url][key]; found {
+		return v
+	}
+	return c.get(key)
+}
+
+// get is Get for callers already holding the lock, since the settings are replaced when merging the changes of other instances
+func (c *Config) get(key string) string {
+	if v, found := c.Settings[key]; found {
+		return v
+	}
+	return defaults[key]
+}
+
+// HasAnnotation reports whether the line of the feed with the given url is annotated with the given text, e.g. #"notify"
+func (c *Config) HasAnnotation(url, annotation string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return slices.Contains(c.annotations[url], annotation)
+}
+
+// IsSet reports whether a key was explicitly set in the config file
+func (c *Config) IsSet(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, found := c.Settings[key]
+	return found
+}
+
+// Command returns the command configured by the given setting, together with its mode (<key>-mode)
+func (c *Config) Command(key string) util.Command {
+	return util.Command{
+		Template: c.Get(key),
+		Mode:     c.Get(key + "-mode"),
+	}
+}
+
+// Macro returns the macro bound to the given key, if any
+func (c *Config) Macro(key byte) (*Macro, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, m := range c.Macros {
+		if m.Key == key {
+			return m, true
+		}
+	}
+	return nil, false
+}
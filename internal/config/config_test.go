@@ -0,0 +1,230 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+func TestEncodeKeepsLineOrder(t *testing.T) {
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	filePath, err := util.GetConfigFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := `# news
+https://lwn.net/headlines/rss   #"LWN"   #"tech"
+set browser "firefox %u"
+
+# blogs, to read on weekends
+https://example.com/blog.xml #"Blog"
+macro y bg "echo %u"
+`
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewConfig()
+	if err := c.Decode(filePath); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Feeds[1].Name = "A Blog"
+	if err := c.AddFeed(feed.NewFeed("New").WithUrl("https://example.com/new.xml"), "https://example.com/new.xml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Encode(); err != nil {
+		t.Fatal(err)
+	}
+
+	bs, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# news
+https://lwn.net/headlines/rss   #"LWN"   #"tech"
+set browser "firefox %u"
+
+# blogs, to read on weekends
+https://example.com/blog.xml #"A Blog"
+macro y bg "echo %u"
+https://example.com/new.xml #"New"
+`
+	if got := string(bs); got != want {
+		t.Errorf("got config file:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestParseLine(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		line    string
+		wantErr bool
+		check   func(t *testing.T, c *Config)
+	}{
+		{name: "empty", line: "  "},
+		{name: "comment", line: `# https://example.com #"Not a feed"`, check: func(t *testing.T, c *Config) {
+			if len(c.Feeds) != 0 {
+				t.Errorf("comment parsed as feed: %v", c.Feeds)
+			}
+		}},
+		{name: "feed", line: `https://lwn.net/headlines/rss #"LWN"`, check: func(t *testing.T, c *Config) {
+			if len(c.Feeds) != 1 || c.Feeds[0].Url != "https://lwn.net/headlines/rss" || c.Feeds[0].Name != "LWN" {
+				t.Errorf("unexpected feeds: %v", c.Feeds)
+			}
+		}},
+		{name: "quoted source", line: `"exec:~/bin/feed.sh --all" #"Generated \"Feed\""`, check: func(t *testing.T, c *Config) {
+			if len(c.Feeds) != 1 || c.Feeds[0].Url != "exec:~/bin/feed.sh --all" || c.Feeds[0].Name != `Generated "Feed"` {
+				t.Errorf("unexpected feeds: %v", c.Feeds)
+			}
+		}},
+		{name: "feed settings", line: `https://example.com/rss #"Example" #"tech" #"proxy = socks5://localhost:1080"`, check: func(t *testing.T, c *Config) {
+			if len(c.annotations["https://example.com/rss"]) != 2 {
+				t.Errorf("missing annotations: %v", c.annotations)
+			}
+			if got := c.FeedGet("https://example.com/rss", "proxy"); got != "socks5://localhost:1080" {
+				t.Errorf("got proxy %q", got)
+			}
+		}},
+		{name: "feed without name", line: "https://example.com/rss", wantErr: true},
+		{name: "setting", line: `set browser "firefox --new-tab %u"`, check: func(t *testing.T, c *Config) {
+			if got := c.Get(BROWSER); got != "firefox --new-tab %u" {
+				t.Errorf("got browser %q", got)
+			}
+		}},
+		{name: "unquoted setting", line: "set pager less", check: func(t *testing.T, c *Config) {
+			if got := c.Get(PAGER); got != "less" {
+				t.Errorf("got pager %q", got)
+			}
+		}},
+		{name: "setting without value", line: "set browser", wantErr: true},
+		{name: "macro", line: `macro y bg "echo %u | wl-copy"`, check: func(t *testing.T, c *Config) {
+			m, found := c.Macro('y')
+			if !found || m.Cmd.Template != "echo %u | wl-copy" || m.Cmd.IsForeground() {
+				t.Errorf("unexpected macro: %+v", m)
+			}
+		}},
+		{name: "macro with long key", line: `macro yy bg "echo %u"`, wantErr: true},
+		{name: "macro with unknown mode", line: `macro y sometimes "echo %u"`, wantErr: true},
+		{name: "hook", line: `hook post "https://example.com/hook" "feed=LWN" "match=^Kernel"`, check: func(t *testing.T, c *Config) {
+			if len(c.Hooks) != 1 || c.Hooks[0].Target != "https://example.com/hook" || c.Hooks[0].Feed != "LWN" || c.Hooks[0].Match == nil {
+				t.Errorf("unexpected hooks: %+v", c.Hooks)
+			}
+		}},
+		{name: "hook with unknown kind", line: `hook get "https://example.com/hook"`, wantErr: true},
+		{name: "hook with invalid filter", line: `hook exec "cat" "match=("`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConfig()
+			err := c.parseLine(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, c)
+			}
+		})
+	}
+}
+
+func TestReadWhileMerging(t *testing.T) {
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	filePath, err := util.GetConfigFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, []byte(`https://example.com/rss #"Example" #"proxy=socks5://localhost:1080"`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewConfig()
+	if err := c.Decode(filePath); err != nil {
+		t.Fatal(err)
+	}
+
+	// changed by another instance, to be merged when encoding
+	if err := os.WriteFile(filePath, []byte(`set browser "lynx %u"`+"\n"+`https://example.com/rss #"Example" #"proxy=socks5://localhost:1081"`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			c.Get(BROWSER)
+			c.FeedGet("https://example.com/rss", PROXY)
+			c.HasAnnotation("https://example.com/rss", "tech")
+		}
+	}()
+	if err := c.Encode(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	if got := c.Get(BROWSER); got != "lynx %u" {
+		t.Errorf("got browser %q, want the one set by the other instance", got)
+	}
+	if got := c.FeedGet("https://example.com/rss", PROXY); got != "socks5://localhost:1081" {
+		t.Errorf("got proxy %q, want the one set by the other instance", got)
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+
+	runs := filepath.Join(t.TempDir(), "runs")
+	c := NewConfig()
+	if err := c.parseLine(`https://example.com/rss #"Example" #"auth-token=cmd:echo run >> ` + runs + `; echo t0k3n"`); err != nil {
+		t.Fatal(err)
+	}
+
+	c.ResolveSecrets()
+	auth, err := c.Auth("https://example.com/rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth == nil || auth.Token != "t0k3n" {
+		t.Fatalf("unexpected credentials: %+v", auth)
+	}
+
+	bs, err := os.ReadFile(runs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(bs), "run"); n != 1 {
+		t.Errorf("got the command run %d times, want once", n)
+	}
+}
+
+func TestPositiveSettings(t *testing.T) {
+
+	c := NewConfig()
+	c.Settings[ARTICLE_TIMEOUT] = "0s"
+	c.Settings[ARTICLE_MAX_SIZE] = "-1"
+	c.Settings[MAX_ITEMS] = "0"
+
+	if got := c.GetDuration(ARTICLE_TIMEOUT); got != 30*time.Second {
+		t.Errorf("got article timeout %v, want the default one", got)
+	}
+	if got := c.GetInt(ARTICLE_MAX_SIZE); got != 10<<20 {
+		t.Errorf("got article max size %d, want the default one", got)
+	}
+	// zero disables the limit
+	if got := c.GetInt(MAX_ITEMS); got != 0 {
+		t.Errorf("got max items %d, want 0", got)
+	}
+}
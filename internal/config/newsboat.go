@@ -0,0 +1,88 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+)
+
+// prefix of the query feeds of newsboat, made of the items of other feeds matching a filter
+const newsboatQuerySource = "query:"
+
+/*
+ImportNewsboatUrls adds the feeds listed in a urls file of newsboat, made of a url per line followed by its tags:
+a tag starting with a tilde (~) names the feed, a tag starting with an exclamation mark (!) hides it in newsboat and is dropped,
+while the other ones become annotations of the feed line. exec: and filter: urls are the same sources here.
+It returns how many feeds were added, skipping the ones already present, and the lines which cannot be imported, i.e. query feeds.
+see: https://newsboat.org/releases/2.36/docs/newsboat.html#_the_urls_file
+*/
+func (c *Config) ImportNewsboatUrls(path string) (int, []string, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	imported := 0
+	unsupported := make([]string, 0)
+
+	s := bufio.NewScanner(file)
+	for n := 1; s.Scan(); n++ {
+
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		args := make([]string, 0)
+		for _, a := range argPattern.FindAllString(line, -1) {
+			if strings.HasPrefix(a, "#") {
+				break
+			}
+			arg, err := unquote(a)
+			if err != nil {
+				return imported, unsupported, fmt.Errorf("%s:%d: %w", path, n, err)
+			}
+			args = append(args, arg)
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		url := args[0]
+		if strings.HasPrefix(url, newsboatQuerySource) {
+			unsupported = append(unsupported, line)
+			continue
+		}
+
+		// named after its url until its title is known
+		name := url
+		annotations := make([]string, 0)
+		for _, tag := range args[1:] {
+			switch {
+			case strings.HasPrefix(tag, "~"):
+				name = tag[1:]
+			case strings.HasPrefix(tag, "!"):
+			default:
+				annotations = append(annotations, tag)
+			}
+		}
+
+		if err := c.AddFeed(feed.NewFeed(name).WithUrl(url), url); err != nil {
+			continue
+		}
+		if len(annotations) > 0 {
+			c.annotations[url] = annotations
+		}
+		imported++
+	}
+
+	return imported, unsupported, s.Err()
+}
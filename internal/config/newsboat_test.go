@@ -0,0 +1,55 @@
+package config
+
+import (
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestImportNewsboatUrls(t *testing.T) {
+
+	c := NewConfig()
+	imported, unsupported, err := c.ImportNewsboatUrls("testdata/newsboat-urls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != 4 {
+		t.Errorf("got %d feeds imported, want 4", imported)
+	}
+	if len(unsupported) != 1 || unsupported[0] != `"query:Unread Articles:unread = \"yes\""` {
+		t.Errorf("unexpected unsupported lines: %q", unsupported)
+	}
+
+	want := []struct{ url, name string }{
+		{"https://lwn.net/headlines/rss", "LWN"},
+		{"https://example.com/podcast.xml", "https://example.com/podcast.xml"},
+		{"exec:~/bin/feed.sh --all", "Generated Feed"},
+		{"filter:~/bin/to-rss.sh:https://example.com/news", "filter:~/bin/to-rss.sh:https://example.com/news"},
+	}
+	if len(c.Feeds) != len(want) {
+		t.Fatalf("got %d feeds, want %d", len(c.Feeds), len(want))
+	}
+	for i, w := range want {
+		if c.Feeds[i].Url != w.url || c.Feeds[i].Name != w.name {
+			t.Errorf("got feed %q %q, want %q %q", c.Feeds[i].Url, c.Feeds[i].Name, w.url, w.name)
+		}
+	}
+
+	if !c.HasAnnotation("https://lwn.net/headlines/rss", "tech") || c.HasAnnotation("https://lwn.net/headlines/rss", "duplicate") {
+		t.Errorf("unexpected annotations: %v", c.annotations["https://lwn.net/headlines/rss"])
+	}
+	if got := c.annotations["https://example.com/podcast.xml"]; !slices.Equal(got, []string{"podcasts"}) {
+		t.Errorf("got annotations %v, want [podcasts]", got)
+	}
+
+	// imported lines are valid config lines once written
+	for _, f := range c.Feeds {
+		line := quoteSource(f.Url) + " #\"" + f.Name + "\""
+		for _, a := range c.annotations[f.Url] {
+			line += " #\"" + a + "\""
+		}
+		if err := CheckLine(line); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/app"
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/httpclient"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+// keys of the supported settings
+const (
+	BROWSER           = "browser"
+	BROWSER_MODE      = "browser-mode"
+	TEXT_BROWSER      = "text-browser"
+	TEXT_BROWSER_MODE = "text-browser-mode"
+	PAGER             = "pager"
+	PAGER_MODE        = "pager-mode"
+	CLIPBOARD         = "clipboard"
+	ARTICLE_TIMEOUT   = "article-timeout"
+	ARTICLE_MAX_SIZE  = "article-max-size"
+	USER_AGENT        = "user-agent"
+	PROXY             = "proxy"
+	CA_FILE           = "ca-file"
+	CLIENT_CERT       = "client-cert"
+	CLIENT_KEY        = "client-key"
+	TLS_INSECURE      = "tls-insecure"
+	DOWNLOAD_DIR      = "download-dir"
+	PLAYER            = "player"
+	PLAYER_MODE       = "player-mode"
+	RELOAD_INTERVAL   = "reload-interval"
+	NOTIFY            = "notify"
+	NOTIFY_FORMAT     = "notify-format"
+	NOTIFY_ITEM       = "notify-item-format"
+	HOOK_RETRIES      = "hook-retries"
+	MAX_ITEMS         = "max-items"
+	MAX_AGE           = "max-age"
+	KEEP_UNREAD       = "keep-unread"
+	KEEP_STARRED      = "keep-starred"
+	CACHE_BACKEND     = "cache-backend"
+	SYNC_BACKEND      = "sync-backend"
+	SYNC_URL          = "sync-url"
+	SYNC_USER         = "sync-user"
+	SYNC_PASSWORD     = "sync-password"
+	SYNC_INTERVAL     = "sync-interval"
+	SERVE_ADDRESS     = "serve-address"
+	SERVE_USER        = "serve-user"
+	SERVE_PASSWORD    = "serve-password"
+)
+
+// values of the sync-backend setting, syncing is disabled by default
+const (
+	// a server implementing the Google Reader API, e.g. FreshRSS or Miniflux
+	SYNC_GREADER = "greader"
+)
+
+// annotation of the feeds whose new items are notified one by one, e.g. https://example.com/rss #"Example" #"notify"
+const NOTIFY_FLAG = "notify"
+
+// values of the clipboard setting, any other value being a command fed with the copied text
+const (
+	// use local clipboard tools when running locally, OSC 52 otherwise
+	CLIPBOARD_AUTO = "auto"
+	// always ask the terminal emulator to set the clipboard
+	CLIPBOARD_OSC52 = "osc52"
+)
+
+var defaults = map[string]string{
+	BROWSER:           "xdg-open %u",
+	BROWSER_MODE:      util.Background,
+	TEXT_BROWSER:      "lynx %u",
+	TEXT_BROWSER_MODE: util.Foreground,
+	PAGER:             defaultPager(),
+	PAGER_MODE:        util.Foreground,
+	CLIPBOARD:         CLIPBOARD_AUTO,
+	ARTICLE_TIMEOUT:   "30s",
+	ARTICLE_MAX_SIZE:  strconv.Itoa(10 << 20),
+	USER_AGENT:        app.Name + "/" + strings.TrimPrefix(app.Version, "v"),
+	DOWNLOAD_DIR:      "~/Downloads/" + app.Name,
+	PLAYER:            "mpv %u",
+	PLAYER_MODE:       util.Foreground,
+	RELOAD_INTERVAL:   "0s",
+	NOTIFY_FORMAT:     "%n new item(s) in %f",
+	NOTIFY_ITEM:       "%f: %t",
+	HOOK_RETRIES:      "3",
+	MAX_ITEMS:         "0",
+	MAX_AGE:           "0s",
+	KEEP_UNREAD:       "false",
+	KEEP_STARRED:      "true",
+	// see the backends of the cache package
+	CACHE_BACKEND: "gob",
+	SYNC_INTERVAL: "0s",
+	SERVE_ADDRESS: "localhost:7070",
+}
+
+// settings which must be greater than zero, their default value being used otherwise
+var positive = map[string]bool{
+	ARTICLE_TIMEOUT:  true,
+	ARTICLE_MAX_SIZE: true,
+}
+
+// errNotPositive is returned for a setting which must be greater than zero
+var errNotPositive = errors.New("not greater than zero")
+
+func defaultPager() string {
+	if pager, found := os.LookupEnv("PAGER"); found && pager != "" {
+		return pager
+	}
+	return "less"
+}
+
+// GetDuration returns the duration set for a key (e.g. 30s, 5m, 1h, 7d), or its default value if not valid
+func (c *Config) GetDuration(key string) time.Duration {
+	d, err := util.ParseDuration(c.Get(key))
+	if err == nil && d <= 0 && positive[key] {
+		err = errNotPositive
+	}
+	if err != nil {
+		slog.Error("not a valid duration", "key", key, "err", err)
+		d, _ = util.ParseDuration(defaults[key])
+	}
+	return d
+}
+
+// FeedGetDuration returns the duration set for a key by the given feed, if any, or else as GetDuration does
+func (c *Config) FeedGetDuration(url, key string) time.Duration {
+	d, err := util.ParseDuration(c.FeedGet(url, key))
+	if err == nil && d <= 0 && positive[key] {
+		err = errNotPositive
+	}
+	if err != nil {
+		slog.Error("not a valid duration", "url", url, "key", key, "err", err)
+		return c.GetDuration(key)
+	}
+	return d
+}
+
+// FeedGetInt returns the integer set for a key by the given feed, if any, or else as GetInt does
+func (c *Config) FeedGetInt(url, key string) int64 {
+	n, err := strconv.ParseInt(c.FeedGet(url, key), 10, 64)
+	if err == nil && n <= 0 && positive[key] {
+		err = errNotPositive
+	}
+	if err != nil {
+		slog.Error("not a valid integer", "url", url, "key", key, "err", err)
+		return c.GetInt(key)
+	}
+	return n
+}
+
+// GetInt returns the integer set for a key, or its default value if not valid
+func (c *Config) GetInt(key string) int64 {
+	n, err := strconv.ParseInt(c.Get(key), 10, 64)
+	if err == nil && n <= 0 && positive[key] {
+		err = errNotPositive
+	}
+	if err != nil {
+		slog.Error("not a valid integer", "key", key, "err", err)
+		n, _ = strconv.ParseInt(defaults[key], 10, 64)
+	}
+	return n
+}
+
+// GetBool reports whether the value set for a key is true
+func (c *Config) GetBool(key string) bool {
+	return parseBool(c.Get(key))
+}
+
+func parseBool(v string) bool {
+	b, _ := strconv.ParseBool(v)
+	return b || v == "yes"
+}
+
+// Retention returns the limits of the items kept in the cache for the feed with the given url
+func (c *Config) Retention(url string) feed.Retention {
+	return feed.Retention{
+		MaxItems:    int(c.FeedGetInt(url, MAX_ITEMS)),
+		MaxAge:      c.FeedGetDuration(url, MAX_AGE),
+		KeepUnread:  parseBool(c.FeedGet(url, KEEP_UNREAD)),
+		KeepStarred: parseBool(c.FeedGet(url, KEEP_STARRED)),
+	}
+}
+
+// HTTPSettings returns the settings of the HTTP client used to fetch the feed with the given url and its articles
+func (c *Config) HTTPSettings(url string) httpclient.Settings {
+	return httpclient.Settings{
+		Proxy:     c.FeedGet(url, PROXY),
+		UserAgent: c.FeedGet(url, USER_AGENT),
+		CAFile:    util.ExpandHome(c.FeedGet(url, CA_FILE)),
+		CertFile:  util.ExpandHome(c.FeedGet(url, CLIENT_CERT)),
+		KeyFile:   util.ExpandHome(c.FeedGet(url, CLIENT_KEY)),
+		Insecure:  parseBool(c.FeedGet(url, TLS_INSECURE)),
+	}
+}
@@ -0,0 +1,91 @@
+package display
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/util"
+	"github.com/giulianopz/newscanoe/internal/xterm"
+)
+
+// local clipboard tools, tried in order when running locally
+var clipboardCmds = []struct {
+	env string
+	cmd util.Command
+}{
+	{"WAYLAND_DISPLAY", util.Command{Template: "wl-copy", Mode: util.Background}},
+	{"DISPLAY", util.Command{Template: "xclip -selection clipboard", Mode: util.Background}},
+}
+
+// copyUrl copies the url of the current element to the clipboard
+func (d *display) copyUrl() {
+	url, _ := d.currentTarget()
+	if url == "" {
+		return
+	}
+	d.copyToClipboard(url, "url")
+}
+
+// copyText copies the text of the article currently displayed to the clipboard
+func (d *display) copyText() {
+	if d.article != nil {
+		d.copyToClipboard(d.article.String(), "article text")
+	}
+}
+
+func (d *display) copyToClipboard(text, what string) {
+
+	var err error
+	switch setting := d.config.Get(config.CLIPBOARD); setting {
+	case config.CLIPBOARD_OSC52:
+		d.copyWithOSC52(text)
+	case config.CLIPBOARD_AUTO:
+		err = d.copyWithLocalTool(text)
+	default:
+		err = copyWithCmd(util.Command{Template: setting, Mode: util.Background}, text)
+	}
+
+	if err != nil {
+		log.Default().Printf("cannot copy to clipboard: %v\n", util.CmdError(err))
+		d.setTmpBottomMessage(2*time.Second, "cannot copy to clipboard: check logs")
+		return
+	}
+	d.setTmpBottomMessage(2*time.Second, fmt.Sprintf("%s copied to clipboard!", what))
+}
+
+/*
+copyWithLocalTool copies the text with wl-copy or xclip, when running locally,
+falling back to OSC 52 over SSH or when no such tool is installed
+*/
+func (d *display) copyWithLocalTool(text string) error {
+	if !util.IsRemote() {
+		for _, c := range clipboardCmds {
+			if v, set := os.LookupEnv(c.env); set && v != "" && c.cmd.IsPresent() {
+				return copyWithCmd(c.cmd, text)
+			}
+		}
+	}
+	d.copyWithOSC52(text)
+	return nil
+}
+
+func copyWithCmd(c util.Command, text string) error {
+	cmd := c.Build("", "")
+	cmd.Stdin = strings.NewReader(text)
+	return util.StartDetached(cmd)
+}
+
+/*
+copyWithOSC52 asks the terminal emulator to set the clipboard, which works across SSH sessions:
+the sequence is written by the next refresh of the screen, so that it is not interleaved with the rendering
+*/
+func (d *display) copyWithOSC52(text string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pendingSeqs = append(d.pendingSeqs, xterm.SetClipboard(text))
+}
@@ -0,0 +1,56 @@
+package display
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/xterm"
+)
+
+func TestCopyWithOSC52(t *testing.T) {
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = out
+	t.Cleanup(func() {
+		os.Stdout = stdout
+		out.Close()
+	})
+
+	d := New(false)
+	d.SetWindowSize(80, 24)
+	d.config.Settings[config.CLIPBOARD] = config.CLIPBOARD_OSC52
+
+	d.copyToClipboard("https://example.com", "url")
+
+	written := func() string {
+		bs, err := os.ReadFile(out.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(bs)
+	}
+
+	t.Run("written by the next refresh only", func(t *testing.T) {
+		if got := written(); strings.Contains(got, xterm.SetClipboard("https://example.com")) {
+			t.Fatalf("sequence written before refreshing the screen: %q", got)
+		}
+		d.RefreshScreen()
+		if n := strings.Count(written(), xterm.SetClipboard("https://example.com")); n != 1 {
+			t.Errorf("got sequence written %d times, want once", n)
+		}
+	})
+
+	t.Run("written once", func(t *testing.T) {
+		d.RefreshScreen()
+		if n := strings.Count(written(), xterm.SetClipboard("https://example.com")); n != 1 {
+			t.Errorf("got sequence written %d times, want once", n)
+		}
+	})
+}
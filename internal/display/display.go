@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"runtime/debug"
 	"sort"
@@ -17,9 +18,14 @@ import (
 	"github.com/giulianopz/newscanoe/internal/bar"
 	"github.com/giulianopz/newscanoe/internal/cache"
 	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/download"
 	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/greader"
+	"github.com/giulianopz/newscanoe/internal/html"
+	"github.com/giulianopz/newscanoe/internal/httpclient"
 	"github.com/giulianopz/newscanoe/internal/util"
 	"github.com/giulianopz/newscanoe/internal/xterm"
+	"golang.org/x/sys/unix"
 )
 
 // display sections
@@ -27,6 +33,9 @@ const (
 	URLS_LIST = iota
 	ARTICLES_LIST
 	ARTICLE_TEXT
+	DOWNLOADS
+	STARRED
+	SEARCH
 )
 
 // num of lines reserved to top and bottom bars plus a final empty row
@@ -39,9 +48,12 @@ const (
 // for Unicode codes, see: http://xahlee.info/comp/unicode_computing_symbols.html
 // some of them are not correctly rendered by gnome-terminal: https://gitlab.gnome.org/GNOME/vte/-/issues/2580
 const (
-	urlsListSectionMsg     = "HELP: q = quit | r = reload | R = reload all | a = add a feed"
+	urlsListSectionMsg     = "HELP: q = quit | r = reload | R = reload all | a = add a feed | D = downloads | S = starred | / = search"
 	articlesListSectionMsg = "HELP: \u21B5 = view article | \u232B = go back"
-	articleTextSectionMsg  = "HELP: \u232B = go back |  \u25B2 = scroll up | \u25BC = scroll down"
+	articleTextSectionMsg  = "HELP: \u232B = go back |  \u25B2 = scroll up | \u25BC = scroll down | p = pager | y = copy url | Y = copy text | f = open link # | g = go to link # | t = toggle feed content | e = enqueue files | s = star"
+	starredSectionMsg      = "HELP: \u21B5 = view article | s = unstar | p = open with pager | y = copy url | \u232B = go back"
+	searchSectionMsg       = "HELP: \u21B5 = view article | / = search again | s = star | p = open with pager | y = copy url | \u232B = go back"
+	downloadsSectionMsg    = "HELP: \u21B5 = play | r = retry | d = remove | y = copy url | \u232B = go back"
 )
 
 type cell struct {
@@ -142,6 +154,8 @@ type display struct {
 	config *config.Config
 	// gob cache
 	cache *cache.Cache
+	// keeps the cache in sync with a server, nil if not configured
+	syncer *greader.Syncer
 
 	// message displayed in the bottom bar
 	topBarMsg string
@@ -149,13 +163,50 @@ type display struct {
 	bottomBarMsg string
 
 	parser *feed.Parser
+	// http clients fetching feeds and articles
+	clients *httpclient.Pool
+	// media files attached to articles, saved in background
+	downloads *download.Queue
 
-	editingMode bool
-	editingBuf  *buffer
+	editingMode   bool
+	editingBuf    *buffer
+	editingSubmit func(input string)
+	// state to be restored if editing is aborted
+	editingPrevMsg string
+	editingPrevPos pos
+
+	// next key is bound to a macro
+	macroPrefix bool
 
 	currentSection    int
 	currentFeedUrl    string
 	currentArticleUrl string
+
+	// the list of articles across feeds the current article was reached from, STARRED or SEARCH, zero if none
+	crossFeedView int
+	// feed url of every row of a list of articles across feeds
+	rowFeeds []string
+	// the last search
+	searchQuery string
+
+	// article currently displayed
+	article *html.Article
+	// horizontal offset of its code blocks
+	hscroll int
+	// the article shows the content provided by the feed instead of the text extracted from its web page
+	feedContent bool
+	// articles displayed before following a link
+	visited []*visit
+
+	termMu sync.Mutex
+	// terminal settings found before entering raw mode
+	origTermios unix.Termios
+	rawMode     bool
+	// an external program owns the terminal
+	foreground bool
+
+	// control sequences to be written along with the next refresh of the screen, like the ones setting the clipboard
+	pendingSeqs []string
 }
 
 type pos struct {
@@ -200,9 +251,10 @@ func New(debugMode bool) *display {
 			endoff:   0,
 		},
 		previous: make([]*pos, 0),
-		config:   &config.Config{},
+		config:   config.NewConfig(),
 		cache:    cache.NewCache(),
 		parser:   feed.NewParser(),
+		clients:  httpclient.NewPool(),
 	}
 	return d
 }
@@ -318,6 +370,7 @@ func (d *display) resetRows() {
 	d.rendered = make([][]*cell, 0)
 }
 
+// LoadConfig reads the config file and resolves the credentials of feeds, to be called before entering raw mode
 func (d *display) LoadConfig() error {
 	configFile, err := util.GetConfigFilePath()
 	if err != nil {
@@ -329,35 +382,133 @@ func (d *display) LoadConfig() error {
 			return err
 		}
 	}
+	// commands like pass may prompt for a passphrase
+	d.config.ResolveSecrets()
 	return nil
 }
 
+// httpClient returns the client to fetch the feed with the given url and its articles
+func (d *display) httpClient(feedUrl string) (*http.Client, error) {
+	return d.clients.Get(d.config.HTTPSettings(feedUrl))
+}
+
+// articleClient returns the client extracting the readable content of the web pages linked by the current feed
+func (d *display) articleClient() (*html.Client, error) {
+	c, err := d.httpClient(d.currentFeedUrl)
+	if err != nil {
+		return nil, err
+	}
+	return html.NewClient(
+		c,
+		d.config.GetDuration(config.ARTICLE_TIMEOUT),
+		d.config.GetInt(config.ARTICLE_MAX_SIZE),
+	), nil
+}
+
+// extractArticle extracts the readable content of a web page linked by the current feed
+func (d *display) extractArticle(url string) (*html.Article, error) {
+	c, err := d.articleClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.ExtractArticle(url)
+}
+
+/*
+fetchArticle extracts the readable content of a web page linked by the current feed as extractArticle does,
+but without holding the display lock, which is taken only to tell meanwhile that the page is loading
+*/
+func (d *display) fetchArticle(url string) (*html.Article, error) {
+
+	d.mu.Lock()
+	c, err := d.articleClient()
+	if err != nil {
+		d.mu.Unlock()
+		return nil, err
+	}
+	previousMsg := d.bottomBarMsg
+	d.setBottomMessage("loading article...")
+	d.mu.Unlock()
+
+	d.RefreshScreen()
+
+	article, err := c.ExtractArticle(url)
+
+	d.mu.Lock()
+	d.setBottomMessage(previousMsg)
+	d.mu.Unlock()
+
+	return article, err
+}
+
 func (d *display) LoadCache() error {
-	cachePath, err := util.GetCacheFilePath()
+
+	backend := d.config.Get(config.CACHE_BACKEND)
+	c, err := cache.Open(backend)
 	if err != nil {
 		return err
 	}
 
-	if util.Exists(cachePath) {
-		if err := d.cache.Decode(cachePath); err != nil {
+	// the cache is moved to a new database the first time it is chosen
+	if backend == cache.BOLT_BACKEND && !util.Exists(c.FilePath()) && util.Exists(cache.NewCache().FilePath()) {
+		count, err := cache.MigrateGob()
+		if err != nil {
 			return err
 		}
+		log.Default().Printf("migrated %d cached items to %s\n", count, c.FilePath())
+	}
+
+	d.cache = c
+	if err := d.cache.Load(); err != nil {
+		return err
 	}
 
 	d.cache.Merge(d.config)
+	d.cache.SetRetention(d.config.Retention)
+	d.cache.OnSaveError(d.onSaveError)
 	return nil
 }
 
+// onSaveError warns that the cache file could not be written in background, e.g. while another instance holds its lock
+func (d *display) onSaveError(err error) {
+
+	log.Default().Println(err)
+
+	d.mu.Lock()
+	d.setTmpBottomMessage(2*time.Second, fmt.Sprintf("cannot save cache (%s)!", saveErrMsg(err)))
+	d.mu.Unlock()
+
+	d.refreshInBackground()
+}
+
+// FlushCache waits for the cache file to be written with the last changes
+func (d *display) FlushCache() {
+	d.cache.Flush()
+}
+
 func (d *display) exitEditingMode() {
 	d.editingMode = false
 	d.editingBuf = nil
+	d.editingSubmit = nil
+}
+
+// abortEditing leaves the editing mode restoring the previous cursor position and bottom bar message
+func (d *display) abortEditing() {
+	d.setBottomMessage(d.editingPrevMsg)
+	d.setTmpBottomMessage(2*time.Second, "editing aborted!")
+	d.exitEditingMode()
+	*d.current = d.editingPrevPos
 }
 
-func (d *display) enterEditingMode() {
+// enterEditingMode lets the user type a line in the bottom bar, which is passed to submit once ENTER is pressed
+func (d *display) enterEditingMode(submit func(input string)) {
 	log.Default().Println("live editing enabled")
 
 	d.editingMode = true
 	d.editingBuf = new(buffer)
+	d.editingSubmit = submit
+	d.editingPrevMsg = d.bottomBarMsg
+	d.editingPrevPos = *d.current
 
 	d.current.cy = d.height
 	d.current.cx = 1
@@ -369,11 +520,16 @@ func (d *display) draw(buf *bytes.Buffer) {
 
 	/* top bar */
 
+	rightText := app.Version
+	if last := d.cache.LastRefresh(); !last.IsZero() {
+		rightText = fmt.Sprintf("refreshed %s %s", lastRefreshTime(last), app.Version)
+	}
+
 	topBar := bar.NewBar()
 	if d.topBarMsg != "" {
-		topBar.SetText(app.Name+" "+d.topBarMsg, app.Version)
+		topBar.SetText(app.Name+" "+d.topBarMsg, rightText)
 	} else {
-		topBar.SetText(app.Name, app.Version)
+		topBar.SetText(app.Name, rightText)
 	}
 	fmt.Fprint(buf, topBar.Build(d.width))
 	fmt.Fprint(buf, "\r\n")
@@ -452,6 +608,14 @@ func (d *display) draw(buf *bytes.Buffer) {
 	fmt.Fprint(buf, bottomBar.Build(d.width))
 }
 
+// lastRefreshTime formats the last time feeds were fetched, omitting the date if today
+func lastRefreshTime(t time.Time) string {
+	if y, m, day := t.Date(); y == time.Now().Year() && m == time.Now().Month() && day == time.Now().Day() {
+		return t.Format("15:04")
+	}
+	return t.Format("Jan 2 15:04")
+}
+
 func (d *display) RefreshScreen() {
 
 	log.Default().Println("refreshing screen")
@@ -471,6 +635,13 @@ func (d *display) RefreshScreen() {
 		fmt.Fprint(buf, ansi.ShowCursor())
 	}
 
+	d.mu.Lock()
+	for _, seq := range d.pendingSeqs {
+		fmt.Fprint(buf, seq)
+	}
+	d.pendingSeqs = nil
+	d.mu.Unlock()
+
 	fmt.Fprint(os.Stdout, buf.String())
 }
 
@@ -0,0 +1,248 @@
+package display
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/ansi"
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/download"
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+// LoadDownloads reads the download queue and resumes the downloads interrupted in a previous session
+func (d *display) LoadDownloads() error {
+
+	filePath, err := util.GetDownloadsFilePath()
+	if err != nil {
+		return err
+	}
+
+	d.downloads = download.NewQueue(filePath, util.ExpandHome(d.config.Get(config.DOWNLOAD_DIR)), d.httpClient)
+	if err := d.downloads.Decode(); err != nil {
+		return err
+	}
+	d.downloads.OnChange(d.onDownloadsChange)
+	d.downloads.Start()
+	return nil
+}
+
+// onDownloadsChange redraws the list of downloads, if displayed, to show their progress
+func (d *display) onDownloadsChange() {
+
+	d.mu.Lock()
+	if d.currentSection != DOWNLOADS {
+		d.mu.Unlock()
+		return
+	}
+	d.renderDownloadList()
+	d.mu.Unlock()
+
+	d.refreshInBackground()
+}
+
+// enqueueEnclosures adds the media files attached to the current article to the download queue
+func (d *display) enqueueEnclosures() {
+
+	item := d.currentItem()
+	if item == nil || len(item.Enclosures) == 0 {
+		d.setTmpBottomMessage(2*time.Second, "no file attached to this article!")
+		return
+	}
+
+	var feedName string
+	for _, f := range d.cache.GetFeeds() {
+		if f.Url == d.currentFeedUrl {
+			feedName = f.Name
+		}
+	}
+
+	var enqueued int
+	for _, e := range item.Enclosures {
+		added, err := d.downloads.Enqueue(&download.Download{
+			Url:      e.Url,
+			FeedUrl:  d.currentFeedUrl,
+			FeedName: feedName,
+			Title:    item.Title,
+			Type:     e.Type,
+			Size:     e.Length,
+		})
+		if err != nil {
+			log.Default().Printf("cannot save download queue: %v\n", err)
+		}
+		if added {
+			enqueued++
+		}
+	}
+
+	if enqueued == 0 {
+		d.setTmpBottomMessage(2*time.Second, "already enqueued!")
+		return
+	}
+	d.setTmpBottomMessage(2*time.Second, fmt.Sprintf("enqueued %d file(s): press D to see the downloads", enqueued))
+}
+
+// currentItem returns the item of the current feed selected or displayed, if any
+func (d *display) currentItem() *feed.Item {
+
+	var url string
+	switch d.currentSection {
+	case ARTICLES_LIST, STARRED, SEARCH:
+		if len(d.raw) == 0 {
+			return nil
+		}
+		url = d.currentUrl()
+	case ARTICLE_TEXT:
+		url = d.currentArticleUrl
+	default:
+		return nil
+	}
+
+	for _, f := range d.cache.GetFeeds() {
+		if f.Url == d.currentFeedUrl {
+			for _, i := range f.Items {
+				if i.Url == url {
+					return i
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// loadDownloadList displays the download queue, which can be left with BACKSPACE
+func (d *display) loadDownloadList() {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.currentSection = DOWNLOADS
+	d.renderDownloadList()
+
+	d.setTopMessage("> downloads")
+	d.setBottomMessage(downloadsSectionMsg)
+}
+
+// renderDownloadList lists the downloads in order of insertion, with their state or progress
+func (d *display) renderDownloadList() {
+
+	d.resetRows()
+
+	for _, dl := range d.downloads.Downloads() {
+
+		d.appendToRaw(dl.Url)
+
+		row := fmt.Sprintf("%-20s %s > %s", downloadStatus(dl), dl.FeedName, dl.Title)
+		switch dl.State {
+		case download.DOWNLOADING:
+			d.appendToRendered(fromStringWithStyle(row, ansi.BOLD))
+		case download.FAILED:
+			d.appendToRendered(fromString(fmt.Sprintf("%s (%s)", row, dl.Err)))
+		default:
+			d.appendToRendered(fromString(row))
+		}
+	}
+
+	if len(d.raw) == 0 {
+		d.setTmpBottomMessage(2*time.Second, "no download: press e on an article with attached files!")
+	}
+}
+
+func downloadStatus(dl download.Download) string {
+	switch dl.State {
+	case download.QUEUED:
+		return "[queued]"
+	case download.DOWNLOADING:
+		if p := dl.Progress(); p >= 0 {
+			return fmt.Sprintf("[%3d%%] %s", p, util.HumanSize(dl.Received))
+		}
+		return fmt.Sprintf("[...] %s", util.HumanSize(dl.Received))
+	case download.DONE:
+		return "[done]"
+	default:
+		return "[failed]"
+	}
+}
+
+// playDownload plays the selected download with the configured player, streaming it if not yet complete
+func (d *display) playDownload() {
+
+	if len(d.raw) == 0 {
+		return
+	}
+
+	dl, found := d.downloads.Get(d.currentUrl())
+	if !found {
+		return
+	}
+
+	target := dl.Url
+	if dl.State == download.DONE {
+		target = dl.Path
+	}
+
+	if err := d.launch(d.config.Command(config.PLAYER), target, dl.Title, nil); err != nil {
+		log.Default().Printf("cannot play %s: %v\n", target, util.CmdError(err))
+		d.setTmpBottomMessage(2*time.Second, "cannot play file: check logs")
+	}
+}
+
+// retryDownload puts the selected download back in the queue, if it failed
+func (d *display) retryDownload() {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.raw) == 0 {
+		return
+	}
+	if err := d.downloads.Retry(d.currentUrl()); err != nil {
+		log.Default().Println(err)
+		d.setTmpBottomMessage(2*time.Second, "not a failed download!")
+		return
+	}
+	d.renderDownloadList()
+}
+
+// removeDownload removes the selected download from the queue, keeping the file if complete
+func (d *display) removeDownload() {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.raw) == 0 {
+		return
+	}
+	if err := d.downloads.Remove(d.currentUrl()); err != nil {
+		log.Default().Println(err)
+		d.setTmpBottomMessage(2*time.Second, "cannot remove download: check logs")
+		return
+	}
+	d.renderDownloadList()
+	if row := d.currentRow(); row > 0 && row >= len(d.raw) {
+		d.moveCursor(ARROW_UP)
+	}
+}
+
+// enclosureLabel returns a short description of the files attached to an item, if any, e.g. [audio/mpeg 27.1 MB]
+func enclosureLabel(i *feed.Item) string {
+
+	if len(i.Enclosures) == 0 {
+		return ""
+	}
+
+	e := i.Enclosures[0]
+	label := e.Type
+	if label == "" {
+		label = "file"
+	}
+	if e.Length > 0 {
+		label += " " + util.HumanSize(e.Length)
+	}
+	if len(i.Enclosures) > 1 {
+		label += fmt.Sprintf(" +%d", len(i.Enclosures)-1)
+	}
+	return " [" + label + "]"
+}
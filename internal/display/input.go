@@ -1,10 +1,13 @@
 package display
 
 import (
+	"context"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/giulianopz/newscanoe/internal/ascii"
+	"github.com/giulianopz/newscanoe/internal/config"
 	"github.com/giulianopz/newscanoe/internal/util"
 	"golang.org/x/sys/unix"
 )
@@ -126,6 +129,13 @@ func (d *display) ReadKeyStroke(fd uintptr) byte {
 }
 
 func (d *display) ProcessKeyStroke(input byte) {
+	if input == ctrlPlus('z') {
+		// ISIG is disabled in raw mode, so the terminal does not send SIGTSTP by itself
+		if err := unix.Kill(unix.Getpid(), unix.SIGTSTP); err != nil {
+			log.Default().Printf("cannot suspend: %v\n", err)
+		}
+		return
+	}
 	if d.editingMode {
 		d.whileEditing(input)
 	} else {
@@ -134,12 +144,24 @@ func (d *display) ProcessKeyStroke(input byte) {
 }
 
 func (d *display) whileReading(input byte) {
+
+	d.selectRowFeed()
+
+	if d.macroPrefix {
+		d.macroPrefix = false
+		d.runMacro(input)
+		return
+	}
+
 	switch input {
 
 	case ctrlPlus('q'), 'q':
 		d.QuitC <- true
 
 	case 'r':
+		if d.currentSection == DOWNLOADS {
+			d.retryDownload()
+		}
 		if d.currentSection == URLS_LIST {
 			parsedFeed, err := d.fetchFeed(string(d.raw[d.currentRow()]))
 			if err != nil {
@@ -157,36 +179,105 @@ func (d *display) whileReading(input byte) {
 		if d.currentSection == URLS_LIST {
 			d.fetchAllFeeds()
 			d.renderFeedList()
+			go d.syncRemote(context.Background())
 		}
 
 	case 'a':
 		if d.currentSection == URLS_LIST {
-			d.enterEditingMode()
+			d.enterEditingMode(d.addNewFeed)
+		}
+
+	case 'D':
+		if d.currentSection == URLS_LIST {
+			d.trackPos()
+			d.loadDownloadList()
+			d.resetCurrentPos()
+		}
+
+	case 'S':
+		if d.currentSection == URLS_LIST {
+			d.trackPos()
+			d.crossFeedView = STARRED
+			d.loadStarredList()
+			d.resetCurrentPos()
+		}
+
+	case '/':
+		if d.currentSection == URLS_LIST || d.currentSection == SEARCH {
+			d.promptForSearch()
+		}
+
+	case 's':
+		if d.inArticleList() || d.currentSection == ARTICLE_TEXT {
+			d.toggleStar()
+		}
+
+	case 'e':
+		if d.inArticleList() || d.currentSection == ARTICLE_TEXT {
+			d.enqueueEnclosures()
+		}
+
+	case 'd':
+		if d.currentSection == DOWNLOADS {
+			d.removeDownload()
 		}
 
 	case 'o':
-		if d.currentSection == ARTICLES_LIST {
-			if !util.IsHeadless() {
-				if err := util.OpenWithBrowser(string(d.raw[d.currentRow()])); err != nil {
-					log.Default().Println("cannot open url with browser", err)
-					d.setTmpBottomMessage(2*time.Second, "cannot open url with browser: check logs")
-				}
+		if d.inArticleList() || d.currentSection == ARTICLE_TEXT {
+			if d.canOpenWithBrowser() {
+				d.openWith(config.BROWSER)
 			}
 		}
 
 	case 'l':
-		if d.currentSection == ARTICLES_LIST {
-			if util.IsLynxPresent() {
-				if err := util.OpenWithLynx(string(d.raw[d.currentRow()])); err != nil {
-					log.Default().Println("cannot open url with lynx", err)
-					d.setTmpBottomMessage(2*time.Second, "cannot open url with lynx: check logs")
-				}
+		if d.inArticleList() || d.currentSection == ARTICLE_TEXT {
+			if d.canOpenWithTextBrowser() {
+				d.openWith(config.TEXT_BROWSER)
 			}
 		}
 
+	case 'p':
+		if d.inArticleList() || d.currentSection == ARTICLE_TEXT {
+			d.openWithPager()
+		}
+
+	case 'f':
+		if d.currentSection == ARTICLE_TEXT && d.canOpenWithBrowser() {
+			d.promptForLink(d.openLinkWithBrowser)
+		}
+
+	case 'g':
+		if d.currentSection == ARTICLE_TEXT {
+			d.promptForLink(d.loadLink)
+		}
+
+	case 't':
+		if d.currentSection == ARTICLE_TEXT {
+			d.toggleArticleView()
+		}
+
+	case 'y':
+		d.copyUrl()
+
+	case 'Y':
+		if d.currentSection == ARTICLE_TEXT {
+			d.copyText()
+		}
+
+	case ',':
+		if len(d.config.Macros) != 0 {
+			d.macroPrefix = true
+			d.setTmpBottomMessage(2*time.Second, "macro: press a key")
+		}
+
 	case ARROW_UP, ARROW_DOWN:
 		d.moveCursor(input)
 
+	case ARROW_LEFT, ARROW_RIGHT:
+		if d.currentSection == ARTICLE_TEXT {
+			d.scrollCode(input)
+		}
+
 	case PAGE_UP, PAGE_DOWN:
 		d.scroll(input)
 
@@ -203,8 +294,11 @@ func (d *display) whileReading(input byte) {
 						d.resetCurrentPos()
 					}
 				}
-			case ARTICLES_LIST:
+			case ARTICLES_LIST, STARRED, SEARCH:
 				{
+					if len(d.raw) == 0 {
+						return
+					}
 					d.trackPos()
 					if err := d.loadArticleText(d.currentUrl()); err != nil {
 						d.restorePos()
@@ -212,26 +306,37 @@ func (d *display) whileReading(input byte) {
 						d.resetCurrentPos()
 					}
 				}
+			case DOWNLOADS:
+				d.playDownload()
 			}
 		}
 
 	case ascii.BACKSPACE:
 		{
 			switch d.currentSection {
-			case ARTICLES_LIST:
+			case ARTICLES_LIST, DOWNLOADS, STARRED, SEARCH:
 				{
 					if err := d.LoadFeedList(); err != nil {
 						log.Default().Printf("cannot load urls: %v", err)
 					}
 					d.currentFeedUrl = ""
+					d.crossFeedView = 0
 					d.restorePos()
 				}
 			case ARTICLE_TEXT:
 				{
-					if err := d.loadArticleList(d.currentFeedUrl); err != nil {
+					if d.goBackToVisited() {
+						return
+					}
+					if d.crossFeedView == STARRED {
+						d.loadStarredList()
+					} else if d.crossFeedView == SEARCH {
+						d.loadSearchList()
+					} else if err := d.loadArticleList(d.currentFeedUrl); err != nil {
 						log.Default().Printf("cannot load article of feed with url %q: %v", d.currentFeedUrl, err)
 					}
 					d.currentArticleUrl = ""
+					d.article = nil
 					d.restorePos()
 				}
 			}
@@ -292,7 +397,7 @@ func (d *display) whileEditing(input byte) {
 		}
 	case input == ascii.ENTER:
 		{
-			d.addNewFeed()
+			d.editingSubmit(strings.TrimSpace(d.editingBuf.String()))
 		}
 	case util.IsLetter(input), util.IsDigit(input), util.IsSpecialChar(input):
 		{
@@ -308,10 +413,7 @@ func (d *display) whileEditing(input byte) {
 		}
 	case input == QUIT:
 		{
-			d.setBottomMessage(urlsListSectionMsg)
-			d.setTmpBottomMessage(2*time.Second, "editing aborted!")
-			d.exitEditingMode()
-			d.resetCurrentPos()
+			d.abortEditing()
 		}
 	default:
 		{
@@ -407,3 +509,36 @@ func (d *display) scroll(dir byte) {
 		}
 	}
 }
+
+// num of chars code blocks are scrolled by
+const hscrollStep = 4
+
+// scrollCode scrolls horizontally the code blocks of the current article, which are not wrapped
+func (d *display) scrollCode(dir byte) {
+
+	if d.article == nil {
+		return
+	}
+
+	textSpace, _ := d.textArea()
+	max := maxCodeLineLen(d.article) - (textSpace - codeIndent)
+	if max < 0 {
+		max = 0
+	}
+
+	switch dir {
+	case ARROW_RIGHT:
+		d.hscroll += hscrollStep
+	case ARROW_LEFT:
+		d.hscroll -= hscrollStep
+	}
+
+	if d.hscroll > max {
+		d.hscroll = max
+	}
+	if d.hscroll < 0 {
+		d.hscroll = 0
+	}
+
+	d.renderArticleText()
+}
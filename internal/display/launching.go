@@ -0,0 +1,134 @@
+package display
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+// launch runs an external program for the given url and title, feeding it with the given input if any
+func (d *display) launch(c util.Command, url, title string, input io.Reader) error {
+
+	cmd := c.Build(url, title)
+	cmd.Stdin = input
+
+	if c.IsForeground() {
+		return d.runInForeground(cmd)
+	}
+	log.Default().Printf("running in background: %s\n", cmd.String())
+	return util.StartDetached(cmd)
+}
+
+// canOpenWithBrowser reports whether a browser was configured or the default one can be used
+func (d *display) canOpenWithBrowser() bool {
+	return d.config.IsSet(config.BROWSER) || !util.IsHeadless()
+}
+
+// canOpenWithTextBrowser reports whether a text browser was configured or the default one is installed
+func (d *display) canOpenWithTextBrowser() bool {
+	return d.config.IsSet(config.TEXT_BROWSER) || d.config.Command(config.TEXT_BROWSER).IsPresent()
+}
+
+// openWith opens the current article with the program configured by the given setting
+func (d *display) openWith(key string) {
+	url, title := d.currentTarget()
+	if err := d.launch(d.config.Command(key), url, title, nil); err != nil {
+		log.Default().Printf("cannot open url with %s: %v\n", key, util.CmdError(err))
+		d.setTmpBottomMessage(2*time.Second, fmt.Sprintf("cannot open url with %s: check logs", key))
+	}
+}
+
+// openWithPager pipes the text of the current article into the pager
+func (d *display) openWithPager() {
+
+	url, title := d.currentTarget()
+
+	var text string
+	switch d.currentSection {
+	case ARTICLES_LIST, STARRED, SEARCH:
+		item := d.currentItem()
+		if item == nil {
+			return
+		}
+		article, err := d.pageArticleOf(item)
+		if err != nil {
+			log.Default().Println(err)
+			d.setTmpBottomMessage(2*time.Second, fmt.Sprintf("cannot load article (%s) from url: %s", fetchErrMsg(err), url))
+			return
+		}
+		text = article.String()
+	case ARTICLE_TEXT:
+		text = d.article.String()
+	default:
+		return
+	}
+
+	if err := d.launch(d.config.Command(config.PAGER), "", title, strings.NewReader(text)); err != nil {
+		log.Default().Printf("cannot open article with pager: %v\n", util.CmdError(err))
+		d.setTmpBottomMessage(2*time.Second, "cannot open article with pager: check logs")
+	}
+}
+
+// runMacro runs the command bound to the given key for the current element
+func (d *display) runMacro(key byte) {
+
+	m, found := d.config.Macro(key)
+	if !found {
+		d.setTmpBottomMessage(2*time.Second, fmt.Sprintf("no macro bound to key: %c", key))
+		return
+	}
+
+	url, title := d.currentTarget()
+	if err := d.launch(m.Cmd, url, title, nil); err != nil {
+		log.Default().Printf("cannot run macro %c: %v\n", key, util.CmdError(err))
+		d.setTmpBottomMessage(2*time.Second, fmt.Sprintf("cannot run macro %c: check logs", key))
+	}
+}
+
+// currentTarget returns url and title of the element currently selected or displayed
+func (d *display) currentTarget() (url, title string) {
+	switch d.currentSection {
+	case URLS_LIST:
+		url = d.currentUrl()
+		for _, f := range d.config.Feeds {
+			if f.Url == url {
+				title = f.Name
+			}
+		}
+	case ARTICLES_LIST, STARRED, SEARCH:
+		if len(d.raw) != 0 {
+			url = d.currentUrl()
+		}
+	case DOWNLOADS:
+		if len(d.raw) != 0 {
+			url = d.currentUrl()
+			if dl, found := d.downloads.Get(url); found {
+				title = dl.Title
+			}
+		}
+		return
+	case ARTICLE_TEXT:
+		url = d.currentArticleUrl
+		if d.article != nil {
+			title = d.article.Title
+		}
+	}
+
+	if d.currentSection != URLS_LIST {
+		for _, f := range d.cache.GetFeeds() {
+			if f.Url == d.currentFeedUrl {
+				for _, i := range f.Items {
+					if i.Url == url {
+						title = i.Title
+					}
+				}
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,105 @@
+package display
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/html"
+)
+
+// visit is an article displayed before following one of its links
+type visit struct {
+	url     string
+	article *html.Article
+	topMsg  string
+}
+
+// promptForLink lets the user type the number of a link of the current article and then calls open with its url
+func (d *display) promptForLink(open func(url string)) {
+
+	if d.article == nil || len(d.article.Links) == 0 {
+		d.setTmpBottomMessage(2*time.Second, "no link in this article!")
+		return
+	}
+
+	d.enterEditingMode(func(input string) {
+
+		num, err := strconv.Atoi(input)
+		if err != nil {
+			d.abortEditing()
+			d.setTmpBottomMessage(2*time.Second, fmt.Sprintf("not a link number: %q", input))
+			return
+		}
+
+		link, err := d.article.Link(num)
+		if err != nil {
+			d.abortEditing()
+			d.setTmpBottomMessage(2*time.Second, err.Error())
+			return
+		}
+
+		d.setBottomMessage(d.editingPrevMsg)
+		d.exitEditingMode()
+		*d.current = d.editingPrevPos
+
+		open(link)
+	})
+}
+
+// openLinkWithBrowser opens a link of the current article with the browser
+func (d *display) openLinkWithBrowser(url string) {
+	if err := d.launch(d.config.Command(config.BROWSER), url, "", nil); err != nil {
+		log.Default().Printf("cannot open link with browser: %v\n", err)
+		d.setTmpBottomMessage(2*time.Second, "cannot open link with browser: check logs")
+	}
+}
+
+// loadLink displays the article found at a link of the current article, which can be left with BACKSPACE
+func (d *display) loadLink(url string) {
+
+	article, err := d.fetchArticle(url)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err != nil {
+		log.Default().Println(err)
+		d.setTmpBottomMessage(2*time.Second, fmt.Sprintf("cannot load article (%s) from url: %s", fetchErrMsg(err), url))
+		return
+	}
+
+	d.trackPos()
+	d.visited = append(d.visited, &visit{
+		url:     d.currentArticleUrl,
+		article: d.article,
+		topMsg:  d.topBarMsg,
+	})
+
+	d.showArticle(article)
+	d.currentArticleUrl = url
+	d.resetCurrentPos()
+
+	d.setTopMessage(fmt.Sprintf("> %s", article.Title))
+}
+
+// goBackToVisited displays again the article from which the current one was reached, if any
+func (d *display) goBackToVisited() bool {
+
+	if len(d.visited) == 0 {
+		return false
+	}
+
+	last := d.visited[len(d.visited)-1]
+	d.visited[len(d.visited)-1] = nil
+	d.visited = d.visited[:len(d.visited)-1]
+
+	d.showArticle(last.article)
+	d.currentArticleUrl = last.url
+	d.restorePos()
+
+	d.setTopMessage(last.topMsg)
+	return true
+}
@@ -2,6 +2,8 @@ package display
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
@@ -13,6 +15,7 @@ import (
 	"github.com/giulianopz/newscanoe/internal/feed"
 	"github.com/giulianopz/newscanoe/internal/html"
 	"github.com/giulianopz/newscanoe/internal/util"
+	"github.com/mmcdole/gofeed"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -51,20 +54,30 @@ func (d *display) fetchFeed(url string) (*feed.Feed, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	parsedFeed, err := d.parser.Parse(url)
+	client, err := d.httpClient(url)
 	if err != nil {
 		log.Default().Println(err)
-		d.setTmpBottomMessage(2*time.Second, "cannot parse feed!")
+		d.setTmpBottomMessage(2*time.Second, "cannot configure http client!")
+		return nil, err
+	}
+
+	auth, err := d.config.Auth(url)
+	if err != nil {
+		log.Default().Println(err)
+		d.setTmpBottomMessage(2*time.Second, "cannot resolve feed credentials: check logs")
+		return nil, err
+	}
+
+	parsedFeed, err := d.parser.Parse(url, client, auth)
+	if err != nil {
+		log.Default().Println(err)
+		d.setTmpBottomMessage(2*time.Second, fmt.Sprintf("cannot parse feed (%s)!", fetchErrMsg(err)))
 		return nil, err
 	}
 
 	parsedFeed = d.cache.AddFeed(parsedFeed, url)
 
-	go func() {
-		if err := d.cache.Encode(); err != nil {
-			log.Default().Println(err.Error())
-		}
-	}()
+	d.cache.Save()
 
 	return parsedFeed, nil
 }
@@ -90,7 +103,19 @@ func (d *display) fetchAllFeeds() {
 
 			log.Default().Printf("loading feed url: %s\n", string(url))
 
-			parsedFeed, err := d.parser.Parse(string(url))
+			client, err := d.httpClient(string(url))
+			if err != nil {
+				log.Default().Println(err)
+				return err
+			}
+
+			auth, err := d.config.Auth(string(url))
+			if err != nil {
+				log.Default().Println(err)
+				return err
+			}
+
+			parsedFeed, err := d.parser.Parse(string(url), client, auth)
 			if err != nil {
 				log.Default().Println(err)
 				return err
@@ -112,11 +137,7 @@ func (d *display) fetchAllFeeds() {
 		return
 	}
 
-	go func() {
-		if err := d.cache.Encode(); err != nil {
-			log.Default().Println(err.Error())
-		}
-	}()
+	d.cache.Save()
 
 	log.Default().Println("reloaded all feeds in: ", time.Since(start))
 }
@@ -150,23 +171,24 @@ func (d *display) loadArticleList(url string) error {
 			d.renderArticleList()
 
 			var browserHelp string
-			if !util.IsHeadless() {
+			if d.canOpenWithBrowser() {
 				browserHelp = " | o = open with browser"
 			}
 
-			var lynxHelp string
-			if util.IsLynxPresent() {
-				lynxHelp = " | l = open with lynx"
+			var textBrowserHelp string
+			if d.canOpenWithTextBrowser() {
+				textBrowserHelp = " | l = open with text browser"
+			}
+
+			var macroHelp string
+			if len(d.config.Macros) != 0 {
+				macroHelp = " | , = run macro"
 			}
 
 			d.setTopMessage(fmt.Sprintf("> %s", cachedFeed.Name))
-			d.setBottomMessage(fmt.Sprintf("%s %s %s", articlesListSectionMsg, browserHelp, lynxHelp))
+			d.setBottomMessage(fmt.Sprintf("%s %s %s | p = open with pager | y = copy url | e = enqueue files | s = star %s", articlesListSectionMsg, browserHelp, textBrowserHelp, macroHelp))
 
-			go func() {
-				if err := d.cache.Encode(); err != nil {
-					log.Default().Println(err.Error())
-				}
-			}()
+			d.cache.Save()
 		}
 	}
 	if !found {
@@ -178,61 +200,162 @@ func (d *display) loadArticleList(url string) error {
 
 func (d *display) loadArticleText(url string) error {
 
+	d.mu.Lock()
+	cachedFeed, i := d.cachedItem(d.currentFeedUrl, url)
+	d.mu.Unlock()
+	if i == nil {
+		return nil
+	}
+
+	// the page is fetched without holding the lock, which is taken again only to show the article
+	article, err := d.pageArticleOf(i)
+
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	for _, cachedFeed := range d.cache.GetFeeds() {
+	fromFeed := false
+	if err != nil {
+		log.Default().Println(err)
+		reason := fetchErrMsg(err)
+
+		fromFeed = true
+		article, err = d.feedArticleOf(i)
+		if err != nil {
+			log.Default().Println(err)
+			d.setTmpBottomMessage(2*time.Second, fmt.Sprintf("cannot load article (%s) from url: %s", reason, url))
+			return fmt.Errorf("cannot load aricle")
+		}
+		d.setTmpBottomMessage(2*time.Second, fmt.Sprintf("cannot extract article (%s): showing feed content!", reason))
+	}
 
-		if cachedFeed.Url == d.currentFeedUrl {
+	i.Unread = false
 
-			for _, i := range cachedFeed.Items {
+	d.showArticle(article)
+	d.feedContent = fromFeed
 
-				if i.Url == url {
+	d.currentArticleUrl = url
+	d.currentSection = ARTICLE_TEXT
 
-					text, err := html.ExtractText(i.Url)
-					if err != nil {
-						log.Default().Println(err)
-						d.setTmpBottomMessage(2*time.Second, fmt.Sprintf("cannot load article from url: %s", url))
-						return fmt.Errorf("cannot load aricle")
-					}
+	d.setTopMessage(articleTitle(cachedFeed, i, fromFeed))
+	d.setBottomMessage(articleTextSectionMsg)
 
-					i.Unread = false
+	d.cache.Save()
 
-					d.resetRows()
+	return nil
+}
 
-					scanner := bufio.NewScanner(strings.NewReader(text))
-					for scanner.Scan() {
-						line := strings.TrimSpace(scanner.Text())
-						if line != "" {
-							d.raw = append(d.raw, []byte(line+"\n"))
-						}
-					}
+// cachedItem returns the cached item with the given url of the feed with the given url, if any
+func (d *display) cachedItem(feedUrl, url string) (*feed.Feed, *feed.Item) {
+	for _, cachedFeed := range d.cache.GetFeeds() {
+		if cachedFeed.Url == feedUrl {
+			for _, i := range cachedFeed.Items {
+				if i.Url == url {
+					return cachedFeed, i
+				}
+			}
+		}
+	}
+	return nil, nil
+}
 
-					d.renderArticleText()
+// pageArticleOf returns the article extracted from the web page of an item, to be called without holding the display lock
+func (d *display) pageArticleOf(i *feed.Item) (*html.Article, error) {
+	d.mu.Lock()
+	article := i.Article
+	d.mu.Unlock()
+	if article != nil {
+		// saved when the item was starred
+		return article, nil
+	}
+	return d.fetchArticle(i.Url)
+}
+
+// toggleArticleView switches the current article between the text extracted from its web page and the content provided by its feed
+func (d *display) toggleArticleView() {
 
-					d.currentArticleUrl = url
-					d.currentSection = ARTICLE_TEXT
+	d.mu.Lock()
+	if len(d.visited) != 0 {
+		d.setTmpBottomMessage(2*time.Second, "not an article of the feed!")
+		d.mu.Unlock()
+		return
+	}
+	cachedFeed, i := d.cachedItem(d.currentFeedUrl, d.currentArticleUrl)
+	fromFeed := !d.feedContent
+	d.mu.Unlock()
 
-					d.setTopMessage(fmt.Sprintf("> %s > %s", cachedFeed.Name, i.Title))
-					d.setBottomMessage(articleTextSectionMsg)
+	if i == nil {
+		return
+	}
 
-					go func() {
-						if err := d.cache.Encode(); err != nil {
-							log.Default().Println(err.Error())
-						}
-					}()
+	var article *html.Article
+	var err error
+	if !fromFeed {
+		// the page is fetched without holding the lock
+		article, err = d.pageArticleOf(i)
+	}
 
-					return nil
-				}
-			}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if fromFeed {
+		article, err = d.feedArticleOf(i)
+	}
+	if err != nil {
+		log.Default().Println(err)
+		if fromFeed {
+			d.setTmpBottomMessage(2*time.Second, "no content provided by the feed!")
+		} else {
+			d.setTmpBottomMessage(2*time.Second, fmt.Sprintf("cannot load article (%s) from url: %s", fetchErrMsg(err), i.Url))
 		}
+		return
 	}
-	return nil
+
+	d.showArticle(article)
+	d.feedContent = fromFeed
+	d.resetCurrentPos()
+
+	d.setTopMessage(articleTitle(cachedFeed, i, fromFeed))
+}
+
+// feedArticleOf returns the readable content provided by the feed of an item, whose relative links point to the web page of the item
+func (d *display) feedArticleOf(i *feed.Item) (*html.Article, error) {
+
+	if strings.TrimSpace(i.Content) == "" {
+		return nil, fmt.Errorf("no content provided by the feed for item: %s", i.Url)
+	}
+
+	article, err := html.FromHTML(i.Content, i.Url)
+	if err != nil {
+		return nil, err
+	}
+	article.Title = i.Title
+	return article, nil
 }
 
-func (d *display) addNewFeed() {
+func articleTitle(f *feed.Feed, i *feed.Item, fromFeed bool) string {
+	if fromFeed {
+		return fmt.Sprintf("> %s > %s [feed content]", f.Name, i.Title)
+	}
+	return fmt.Sprintf("> %s > %s", f.Name, i.Title)
+}
+
+// showArticle renders the blocks of an article, followed by the list of its links
+func (d *display) showArticle(article *html.Article) {
 
-	url := strings.TrimSpace(d.editingBuf.String())
+	d.article = article
+	d.hscroll = 0
+
+	d.resetRows()
+
+	scanner := bufio.NewScanner(strings.NewReader(article.String()))
+	for scanner.Scan() {
+		d.appendToRaw(scanner.Text())
+	}
+
+	d.renderArticleText()
+}
+
+func (d *display) addNewFeed(url string) {
 
 	for _, f := range d.config.Feeds {
 		if f.Url == url {
@@ -255,7 +378,7 @@ func (d *display) addNewFeed() {
 
 	if err := d.config.Encode(); err != nil {
 		log.Default().Println(err)
-		d.setTmpBottomMessage(2*time.Second, "cannot write new feed to config!")
+		d.setTmpBottomMessage(2*time.Second, fmt.Sprintf("cannot write new feed to config (%s)!", saveErrMsg(err)))
 		return
 	}
 
@@ -291,3 +414,38 @@ func (d *display) addNewFeed() {
 	d.setTmpBottomMessage(2*time.Second, "new feed saved!")
 	d.exitEditingMode()
 }
+
+// fetchErrMsg describes briefly why a web page could not be fetched
+func fetchErrMsg(err error) string {
+	var statusErr *html.StatusError
+	var httpErr gofeed.HTTPError
+	var cmdErr *feed.CmdError
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.Status
+	case errors.As(err, &httpErr):
+		return httpErr.Status
+	case errors.As(err, &cmdErr) && cmdErr.Stderr != "":
+		// the last line printed on stderr is likely the most meaningful
+		lines := strings.Split(cmdErr.Stderr, "\n")
+		return lines[len(lines)-1]
+	case errors.Is(err, html.ErrTooLarge):
+		return "page too large"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timed out"
+	default:
+		return "check logs"
+	}
+}
+
+// saveErrMsg returns a short description of the failure of a write of the cache or config file
+func saveErrMsg(err error) string {
+	var lockedErr *util.LockedError
+	if errors.As(err, &lockedErr) {
+		if lockedErr.Pid == 0 {
+			return "locked by another instance"
+		}
+		return fmt.Sprintf("locked by another instance, pid %d", lockedErr.Pid)
+	}
+	return "check logs"
+}
@@ -0,0 +1,86 @@
+package display
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/html"
+	"golang.org/x/exp/slices"
+)
+
+func TestToggleArticleView(t *testing.T) {
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	paragraph := strings.Repeat("Linked lists are simple, augmentable and conceptual data structures. ", 10)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<html><head><title>Linked lists</title></head><body><article><h1>Linked lists</h1><p>%s</p><p>%s</p></article></body></html>`, paragraph, paragraph)
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	feedUrl := "https://example.com/feed.json"
+	withPage := feed.NewItem("With page", server.URL+"/page", time.Now())
+	withPage.Content = `<p>Read <a href="/about">more</a>.</p>`
+	withoutPage := feed.NewItem("Without page", server.URL+"/missing", time.Now())
+	withoutPage.Content = html.TextToHTML("First line\nSecond line")
+
+	d := New(false)
+	d.SetWindowSize(80, 24)
+	t.Cleanup(d.FlushCache)
+
+	f := feed.NewFeed("Example").WithUrl(feedUrl)
+	f.Items = []*feed.Item{withPage, withoutPage}
+	d.cache.AddFeed(f, feedUrl)
+	d.currentFeedUrl = feedUrl
+	d.currentSection = ARTICLES_LIST
+
+	t.Run("falls back to the feed content keeping its line breaks", func(t *testing.T) {
+		if err := d.loadArticleText(withoutPage.Url); err != nil {
+			t.Fatal(err)
+		}
+		if !d.feedContent {
+			t.Fatal("want feed content")
+		}
+		if got := d.article.String(); got != "First line\nSecond line\n" {
+			t.Errorf("got %q", got)
+		}
+
+		d.toggleArticleView()
+		if !d.feedContent {
+			t.Errorf("want feed content still shown, since the page is missing")
+		}
+	})
+
+	t.Run("switches between the page and the feed content", func(t *testing.T) {
+		if err := d.loadArticleText(withPage.Url); err != nil {
+			t.Fatal(err)
+		}
+		if d.feedContent || !strings.Contains(d.article.String(), "Linked lists are simple") {
+			t.Fatalf("want page content, got: %q", d.article)
+		}
+
+		d.toggleArticleView()
+		if !d.feedContent {
+			t.Fatal("want feed content")
+		}
+		// relative to the url of the item
+		if want := []string{server.URL + "/about"}; !slices.Equal(d.article.Links, want) {
+			t.Errorf("got links %q, want %q", d.article.Links, want)
+		}
+
+		d.toggleArticleView()
+		if d.feedContent || !strings.Contains(d.article.String(), "Linked lists are simple") {
+			t.Errorf("want page content again, got: %q", d.article)
+		}
+	})
+}
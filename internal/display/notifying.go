@@ -0,0 +1,84 @@
+package display
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/hooks"
+	"github.com/giulianopz/newscanoe/internal/notify"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+// how long new items are collected before being notified at once
+const notifyDelay = 2 * time.Second
+
+// EnableNotifications runs the configured notify command whenever a refresh finds new items, if any
+func (d *display) EnableNotifications() {
+
+	if !d.config.IsSet(config.NOTIFY) {
+		return
+	}
+
+	n := notify.New(notifyDelay, d.sendNotifications)
+	d.cache.OnNewItems(func(f *feed.Feed, items []*feed.Item) {
+		n.Add(f, items)
+	})
+}
+
+/*
+sendNotifications runs the notify command once with a summary of the new items of every feed (replacing %t),
+and once more for every new item of the feeds annotated with #"notify" (replacing %t with its title and %u with its url)
+*/
+func (d *display) sendNotifications(news []*notify.News) {
+
+	template := d.config.Get(config.NOTIFY)
+	if !strings.Contains(template, "%t") {
+		// e.g. notify-send newscanoe
+		template += " %t"
+	}
+	cmd := util.Command{Template: template, Mode: util.Background}
+
+	notifyItems := func(url, msg string) {
+		if err := util.StartDetached(cmd.Build(url, msg)); err != nil {
+			log.Default().Printf("cannot notify new items: %v\n", util.CmdError(err))
+		}
+	}
+
+	notifyItems("", notify.Summary(d.config.Get(config.NOTIFY_FORMAT), news))
+
+	for _, n := range news {
+		if d.config.HasAnnotation(n.FeedUrl, config.NOTIFY_FLAG) {
+			for _, i := range n.Items {
+				notifyItems(i.Url, notify.ItemMessage(d.config.Get(config.NOTIFY_ITEM), n.FeedName, i))
+			}
+		}
+	}
+}
+
+// how long to wait before retrying a failed delivery to a hook for the first time
+const hookBackoff = 5 * time.Second
+
+// EnableHooks delivers the new items found by refreshes to the configured hooks, if any
+func (d *display) EnableHooks() error {
+
+	if len(d.config.Hooks) == 0 {
+		return nil
+	}
+
+	client, err := d.httpClient("")
+	if err != nil {
+		return err
+	}
+
+	logPath, err := util.GetHooksLogFilePath()
+	if err != nil {
+		return err
+	}
+
+	r := hooks.NewRunner(d.config.Hooks, client, int(d.config.GetInt(config.HOOK_RETRIES)), hookBackoff, logPath)
+	d.cache.OnNewItems(r.Fire)
+	return nil
+}
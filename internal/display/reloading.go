@@ -0,0 +1,107 @@
+package display
+
+import (
+	"log"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/feed"
+)
+
+// how often feeds are checked for being due to an automatic reload
+const reloadCheckInterval = 30 * time.Second
+
+/*
+StartAutoReload reloads in background every feed whose reload interval has elapsed since it was last fetched:
+the interval is set globally or for a single feed by the reload-interval setting (zero disables it),
+but a feed is never reloaded more often than it advertises by its ttl or by the Cache-Control header of its server
+*/
+func (d *display) StartAutoReload() {
+	started := time.Now()
+	go func() {
+		d.reloadDueFeeds(started)
+		for range time.Tick(reloadCheckInterval) {
+			d.reloadDueFeeds(started)
+		}
+	}()
+}
+
+// reloadInterval returns the interval between two automatic reloads of a feed, zero if disabled
+func (d *display) reloadInterval(f *feed.Feed) time.Duration {
+	interval := d.config.FeedGetDuration(f.Url, config.RELOAD_INTERVAL)
+	if interval <= 0 {
+		return 0
+	}
+	return max(interval, f.TTL)
+}
+
+/*
+reloadDueFeeds fetches the feeds due to a reload without holding the display lock, which is taken only to cache them.
+Feeds with no time of last fetch, e.g. cached by a version which did not keep it, are due an interval after startup
+rather than all at once at startup
+*/
+func (d *display) reloadDueFeeds(started time.Time) {
+
+	d.mu.Lock()
+	due := make([]*feed.Feed, 0)
+	for _, f := range d.cache.GetFeeds() {
+		last := f.LastRefresh
+		if last.IsZero() {
+			last = started
+		}
+		if interval := d.reloadInterval(f); interval > 0 && time.Since(last) >= interval {
+			due = append(due, f)
+		}
+	}
+	d.mu.Unlock()
+
+	if len(due) == 0 {
+		return
+	}
+
+	var reloaded int
+	for _, f := range due {
+
+		log.Default().Printf("reloading feed in background: %s\n", f.Url)
+
+		client, err := d.httpClient(f.Url)
+		if err != nil {
+			log.Default().Println(err)
+			continue
+		}
+
+		auth, err := d.config.Auth(f.Url)
+		if err != nil {
+			log.Default().Println(err)
+			continue
+		}
+
+		parsedFeed, err := d.parser.Parse(f.Url, client, auth)
+
+		d.mu.Lock()
+		if err != nil {
+			log.Default().Println(err)
+			// retry at the next interval rather than at every check
+			f.LastRefresh = time.Now()
+		} else {
+			d.cache.AddFeed(parsedFeed, f.Url)
+			reloaded++
+		}
+		d.mu.Unlock()
+	}
+
+	if reloaded != 0 {
+		d.cache.Save()
+	}
+
+	d.mu.Lock()
+	if d.currentSection != URLS_LIST {
+		d.mu.Unlock()
+		return
+	}
+	// the cursor is left where it is, since rows are just re-rendered with the new counts
+	d.renderFeedList()
+	d.mu.Unlock()
+
+	d.refreshInBackground()
+}
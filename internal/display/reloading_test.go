@@ -0,0 +1,102 @@
+package display
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/feed"
+)
+
+func TestReloadDueFeeds(t *testing.T) {
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	var fetched atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched.Add(1)
+		fmt.Fprint(w, `<rss version="2.0"><channel><title>Example</title><item><title>Hello</title><link>https://example.com/1</link></item></channel></rss>`)
+	}))
+	t.Cleanup(server.Close)
+
+	d := New(false)
+	d.config.Settings[config.RELOAD_INTERVAL] = "1h"
+	// nothing to re-render
+	d.currentSection = ARTICLES_LIST
+	t.Cleanup(d.FlushCache)
+
+	f := feed.NewFeed("Example").WithUrl(server.URL)
+	d.cache.AddFeed(f, server.URL)
+	started := time.Now()
+
+	t.Run("feeds never fetched are not reloaded at startup", func(t *testing.T) {
+		d.reloadDueFeeds(started)
+		if n := fetched.Load(); n != 0 {
+			t.Errorf("got %d fetches, want none", n)
+		}
+	})
+
+	t.Run("feeds are reloaded once their interval elapsed", func(t *testing.T) {
+		d.reloadDueFeeds(started.Add(-2 * time.Hour))
+		if n := fetched.Load(); n != 1 {
+			t.Fatalf("got %d fetches, want 1", n)
+		}
+		if len(f.Items) != 1 || f.LastRefresh.Before(started) {
+			t.Errorf("reloaded feed not cached: %+v", f)
+		}
+
+		d.reloadDueFeeds(started.Add(-2 * time.Hour))
+		if n := fetched.Load(); n != 1 {
+			t.Errorf("got %d fetches, want no more until the next interval", n)
+		}
+	})
+}
+
+func TestReloadWhileInForeground(t *testing.T) {
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<rss version="2.0"><channel><title>Example</title><item><title>Hello</title><link>https://example.com/1</link></item></channel></rss>`)
+	}))
+	t.Cleanup(server.Close)
+
+	tty := openPty(t)
+	stdin, stdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = tty, tty
+	t.Cleanup(func() {
+		os.Stdin, os.Stdout = stdin, stdout
+	})
+
+	d := New(false)
+	d.config.Settings[config.RELOAD_INTERVAL] = "1h"
+	// re-rendered once reloaded
+	d.currentSection = URLS_LIST
+	t.Cleanup(d.FlushCache)
+	d.EnableRawMode()
+	t.Cleanup(d.DisableRawMode)
+
+	f := feed.NewFeed("Example").WithUrl(server.URL)
+	d.cache.AddFeed(f, server.URL)
+
+	reloaded := make(chan struct{})
+	go func() {
+		defer close(reloaded)
+		d.reloadDueFeeds(time.Now().Add(-2 * time.Hour))
+	}()
+
+	if err := d.runInForeground(exec.Command("sleep", "0.1")); err != nil {
+		t.Fatal(err)
+	}
+	<-reloaded
+
+	if len(f.Items) != 1 {
+		t.Errorf("reloaded feed not cached: %+v", f)
+	}
+}
@@ -1,11 +1,15 @@
 package display
 
 import (
+	"fmt"
 	"log"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/giulianopz/newscanoe/internal/ansi"
 	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/html"
 	"github.com/giulianopz/newscanoe/internal/util"
 )
 
@@ -16,7 +20,6 @@ func (d *display) renderFeedList() {
 	m := make(map[string]*feed.Feed)
 
 	for _, f := range d.cache.GetFeeds() {
-		f.CountUnread()
 		m[f.Url] = f
 	}
 
@@ -25,11 +28,22 @@ func (d *display) renderFeedList() {
 		if !found {
 			log.Default().Printf("feed url not found: %s\n", url)
 		} else {
-			d.appendToRendered(fromString(util.RenderFeedRow(f.UnreadCount, len(f.Items), f.Name)))
+			d.appendToRendered(fromString(util.RenderFeedRow(unreadCount(f), len(f.Items), f.Name)))
 		}
 	}
 }
 
+// unreadCount counts the unread items of a cached feed, leaving it untouched since it may be being written meanwhile
+func unreadCount(f *feed.Feed) int {
+	var count int
+	for _, i := range f.Items {
+		if i.Unread {
+			count++
+		}
+	}
+	return count
+}
+
 func (d *display) renderArticleList() {
 
 	var f *feed.Feed
@@ -42,10 +56,15 @@ func (d *display) renderArticleList() {
 	d.rendered = make([][]*cell, 0)
 	if f != nil {
 		for _, item := range f.GetItemsOrderedByDate() {
+			title := item.Title
+			if item.Starred {
+				title = starMark + title
+			}
+			row := util.RenderArticleRow(item.PubDate, title) + enclosureLabel(item)
 			if item.Unread {
-				d.appendToRendered(fromStringWithStyle(util.RenderArticleRow(item.PubDate, item.Title), ansi.BOLD))
+				d.appendToRendered(fromStringWithStyle(row, ansi.BOLD))
 			} else {
-				d.appendToRendered(fromString(util.RenderArticleRow(item.PubDate, item.Title)))
+				d.appendToRendered(fromString(row))
 			}
 		}
 	} else {
@@ -53,16 +72,28 @@ func (d *display) renderArticleList() {
 	}
 }
 
-func (d *display) renderArticleText() {
-
-	log.Default().Println("width: ", d.width)
+// textArea returns the width of the article text and of the left margin centering it
+func (d *display) textArea() (textSpace, margin int) {
 
-	var textSpace, margin int = d.width - 1, 0
+	textSpace, margin = d.width-1, 0
 
 	if d.width > 100 {
 		textSpace = (d.width - 1) / 4 * 2
 		margin = ((d.width - 1) - textSpace) / 2
 	}
+	return
+}
+
+func (d *display) renderArticleText() {
+
+	log.Default().Println("width: ", d.width)
+
+	textSpace, margin := d.textArea()
+
+	if d.article != nil {
+		d.rendered = renderArticle(d.article, textSpace, margin, d.hscroll)
+		return
+	}
 
 	runes := make([]rune, 0)
 	for row := range d.raw {
@@ -141,3 +172,141 @@ func add(margin int, line []*cell) []*cell {
 	}
 	return line
 }
+
+// num of spaces indenting code blocks
+const codeIndent = 4
+
+/*
+renderArticle renders the blocks of an article within the given text space:
+headings are bold, list items are indented and preceded by their bullet,
+quotes are italic and preceded by a '>' for each level of nesting,
+code blocks are not wrapped but cut, showing their lines from the given horizontal offset
+*/
+func renderArticle(article *html.Article, textSpace, margin, hscroll int) [][]*cell {
+
+	rendered := make([][]*cell, 0)
+	appendRow := func(cells ...[]*cell) {
+		row := make([]*cell, 0)
+		for _, c := range cells {
+			row = append(row, c...)
+		}
+		rendered = append(rendered, add(margin, row))
+	}
+
+	for i, b := range article.Blocks {
+
+		switch b.Kind {
+		case html.HEADING:
+			for _, line := range wrap(b.Text, textSpace) {
+				appendRow(fromStringWithStyle(line, ansi.BOLD))
+			}
+		case html.LIST_ITEM:
+			indent := strings.Repeat("  ", b.Depth-1)
+			marker := b.Marker + " "
+			if b.Marker == "" {
+				marker = "  "
+			}
+			space := strings.Repeat(" ", utf8.RuneCountInString(marker))
+			for j, line := range wrap(b.Text, textSpace-utf8.RuneCountInString(indent+marker)) {
+				if j == 0 {
+					appendRow(fromString(indent + marker + line))
+				} else {
+					appendRow(fromString(indent + space + line))
+				}
+			}
+		case html.QUOTE:
+			prefix := strings.Repeat("> ", b.Depth)
+			for _, line := range wrap(b.Text, textSpace-utf8.RuneCountInString(prefix)) {
+				appendRow(fromString(prefix), fromStringWithStyle(line, ansi.ITALIC))
+			}
+		case html.CODE:
+			for _, line := range strings.Split(b.Text, "\n") {
+				appendRow(fromString(strings.Repeat(" ", codeIndent) + cut(line, hscroll, textSpace-codeIndent)))
+			}
+		default:
+			for _, line := range wrap(b.Text, textSpace) {
+				appendRow(fromString(line))
+			}
+		}
+
+		isLast := i == len(article.Blocks)-1
+		if isLast || b.Kind != html.LIST_ITEM || article.Blocks[i+1].Kind != html.LIST_ITEM {
+			rendered = append(rendered, []*cell{})
+		}
+	}
+
+	if len(article.Links) != 0 {
+		appendRow(fromStringWithStyle("Links:", ansi.BOLD))
+		for i, l := range article.Links {
+			for _, line := range wrap(fmt.Sprintf("[%d] %s", i+1, l), textSpace) {
+				appendRow(fromString(line))
+			}
+		}
+	}
+
+	return rendered
+}
+
+// maxCodeLineLen returns the length of the longest line among the code blocks of an article
+func maxCodeLineLen(article *html.Article) int {
+	var max int
+	for _, b := range article.Blocks {
+		if b.Kind == html.CODE {
+			for _, line := range strings.Split(b.Text, "\n") {
+				if l := utf8.RuneCountInString(expandTabs(line)); l > max {
+					max = l
+				}
+			}
+		}
+	}
+	return max
+}
+
+// wrap splits a text in lines not longer than width, breaking them between words when possible
+func wrap(text string, width int) []string {
+
+	if width < 1 {
+		width = 1
+	}
+
+	lines := make([]string, 0)
+	for _, paragraph := range strings.Split(text, "\n") {
+
+		line := make([]rune, 0)
+		for _, word := range strings.Fields(paragraph) {
+
+			runes := []rune(word)
+			if len(line) != 0 && len(line)+1+len(runes) > width {
+				lines = append(lines, string(line))
+				line = make([]rune, 0)
+			}
+			if len(line) != 0 {
+				line = append(line, ' ')
+			}
+			for len(runes) > width {
+				lines = append(lines, string(runes[:width]))
+				runes = runes[width:]
+			}
+			line = append(line, runes...)
+		}
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// cut returns at most width chars of a line, starting from the given offset
+func cut(line string, offset, width int) string {
+	runes := []rune(expandTabs(line))
+	if offset >= len(runes) || width < 1 {
+		return ""
+	}
+	runes = runes[offset:]
+	if len(runes) > width {
+		runes = runes[:width]
+	}
+	return string(runes)
+}
+
+func expandTabs(line string) string {
+	return strings.ReplaceAll(line, "\t", "    ")
+}
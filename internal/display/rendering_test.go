@@ -3,8 +3,15 @@ package display
 import (
 	"bufio"
 	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/giulianopz/newscanoe/internal/html"
 )
 
 func TestRenderText(t *testing.T) {
@@ -118,3 +125,91 @@ For all those reasons, I love linked lists, and I hope that you will, at least,
 	})
 
 }
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestRenderArticle(t *testing.T) {
+
+	bs, err := os.ReadFile(filepath.Join("testdata", "article.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	article, err := html.FromHTML(string(bs), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		width   int
+		hscroll int
+	}{
+		{name: "narrow", width: 60},
+		{name: "wide", width: 157},
+		{name: "scrolled", width: 60, hscroll: 12},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("renders styled blocks in a %s window", tc.name), func(t *testing.T) {
+
+			d := New(false)
+			d.SetWindowSize(tc.width, 27)
+			d.article = article
+			d.hscroll = tc.hscroll
+
+			d.renderArticleText()
+
+			golden := filepath.Join("testdata", "article_"+tc.name+".golden")
+			got := dump(d.rendered)
+
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("rendered text does not match %s:\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+
+			for row := range d.rendered {
+				var currentRowLen int
+				for _, c := range d.rendered[row] {
+					if c.char != NULL {
+						currentRowLen++
+					}
+				}
+				if currentRowLen > d.width {
+					diff := currentRowLen - d.width
+					t.Errorf("row #%d is %d chars longer than window width", row, diff)
+				}
+			}
+		})
+	}
+}
+
+// dump renders rows as lines of text, marking with {params} every change of display attributes
+func dump(rows [][]*cell) string {
+	var sb strings.Builder
+	for _, row := range rows {
+		var current string
+		for _, c := range row {
+			if len(c.params) != 0 {
+				if params := fmt.Sprint(c.params); params != current {
+					fmt.Fprintf(&sb, "{%s}", strings.Trim(params, "[]"))
+					current = params
+				}
+			}
+			if c.char != NULL {
+				sb.WriteRune(c.char)
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
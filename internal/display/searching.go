@@ -0,0 +1,91 @@
+package display
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/ansi"
+	"github.com/giulianopz/newscanoe/internal/cache"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+// max number of articles listed by a search
+const maxSearchResults = 200
+
+// promptForSearch asks for words to be searched in the titles and texts of the articles of all feeds
+func (d *display) promptForSearch() {
+	d.enterEditingMode(func(input string) {
+
+		if input == "" {
+			d.abortEditing()
+			return
+		}
+
+		d.setBottomMessage(d.editingPrevMsg)
+		d.exitEditingMode()
+		*d.current = d.editingPrevPos
+
+		// searching again from the results replaces them
+		if d.currentSection != SEARCH {
+			d.trackPos()
+			d.crossFeedView = SEARCH
+		}
+		d.searchQuery = input
+		d.loadSearchList()
+		d.resetCurrentPos()
+	})
+}
+
+/*
+loadSearchList displays the articles of all feeds matching the last search, which can be left with BACKSPACE:
+the search runs without holding the display lock, since the first one builds the index of every cached item
+*/
+func (d *display) loadSearchList() {
+
+	d.mu.Lock()
+	query, width := d.searchQuery, d.width
+	d.mu.Unlock()
+
+	results := d.cache.Search(query, maxSearchResults, width)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.currentSection = SEARCH
+	d.renderSearchList(results)
+
+	d.setTopMessage(fmt.Sprintf("> search: %s", d.searchQuery))
+	d.setBottomMessage(searchSectionMsg)
+}
+
+// renderSearchList lists the results of the last search from the best match, each followed by a passage of its text
+func (d *display) renderSearchList(results []*cache.SearchResult) {
+
+	d.resetRows()
+	d.rowFeeds = make([]string, 0)
+
+	for _, r := range results {
+
+		d.appendToRaw(r.Item.Url)
+		d.rowFeeds = append(d.rowFeeds, r.Feed.Url)
+
+		title := fmt.Sprintf("%s > %s", r.Feed.Name, r.Item.Title)
+		if r.Item.Starred {
+			title = starMark + title
+		}
+		row := util.RenderArticleRow(r.Item.PubDate, title) + enclosureLabel(r.Item)
+		if r.Snippet != "" {
+			row += " | " + r.Snippet
+		}
+
+		if r.Item.Unread {
+			d.appendToRendered(fromStringWithStyle(row, ansi.BOLD))
+		} else {
+			d.appendToRendered(fromString(row))
+		}
+	}
+
+	if len(d.raw) == 0 {
+		d.setTmpBottomMessage(2*time.Second, fmt.Sprintf("no article matching: %s", d.searchQuery))
+	}
+}
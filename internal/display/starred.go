@@ -0,0 +1,132 @@
+package display
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/ansi"
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+// mark preceding the title of starred items
+const starMark = "★ "
+
+// inArticleList reports whether a list of articles is displayed, either of the current feed or across feeds
+func (d *display) inArticleList() bool {
+	return d.currentSection == ARTICLES_LIST || d.inCrossFeedList()
+}
+
+// inCrossFeedList reports whether a list of articles across feeds is displayed, i.e. the starred ones or the search results
+func (d *display) inCrossFeedList() bool {
+	return d.currentSection == STARRED || d.currentSection == SEARCH
+}
+
+// toggleStar stars the current article, saving the text extracted from its web page, or unstars it
+func (d *display) toggleStar() {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	item := d.currentItem()
+	if item == nil {
+		d.setTmpBottomMessage(2*time.Second, "not an article of the feed!")
+		return
+	}
+
+	item.Starred = !item.Starred
+
+	msg := "unstarred!"
+	if item.Starred {
+		msg = "starred!"
+		if err := d.saveArticleText(item); err != nil {
+			log.Default().Println(err)
+			msg = fmt.Sprintf("starred, but cannot save its text (%s)!", fetchErrMsg(err))
+		}
+	} else {
+		item.Article = nil
+	}
+	d.cache.Reindex(d.currentFeedUrl, item)
+
+	switch d.currentSection {
+	case ARTICLES_LIST:
+		d.renderArticleList()
+	case STARRED:
+		d.renderStarredList()
+		if row := d.currentRow(); row > 0 && row >= len(d.raw) {
+			d.moveCursor(ARROW_UP)
+		}
+	case SEARCH:
+		// the index is already built by the search
+		d.renderSearchList(d.cache.Search(d.searchQuery, maxSearchResults, d.width))
+	}
+
+	d.cache.Save()
+
+	d.setTmpBottomMessage(2*time.Second, msg)
+}
+
+// saveArticleText keeps the text extracted from the web page of a starred item, which may not be reachable later
+func (d *display) saveArticleText(i *feed.Item) error {
+
+	if i.Article != nil {
+		return nil
+	}
+
+	if d.currentSection == ARTICLE_TEXT && !d.feedContent && d.article != nil {
+		i.Article = d.article
+		return nil
+	}
+
+	article, err := d.extractArticle(i.Url)
+	if err != nil {
+		return err
+	}
+	i.Article = article
+	return nil
+}
+
+// loadStarredList displays the starred articles of all feeds, which can be left with BACKSPACE
+func (d *display) loadStarredList() {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.currentSection = STARRED
+	d.renderStarredList()
+
+	d.setTopMessage("> starred")
+	d.setBottomMessage(starredSectionMsg)
+}
+
+// renderStarredList lists the starred articles from the most recent, each preceded by the name of its feed
+func (d *display) renderStarredList() {
+
+	d.resetRows()
+	d.rowFeeds = make([]string, 0)
+
+	for _, s := range d.cache.Starred() {
+
+		d.appendToRaw(s.Item.Url)
+		d.rowFeeds = append(d.rowFeeds, s.Feed.Url)
+
+		row := util.RenderArticleRow(s.Item.PubDate, fmt.Sprintf("%s > %s", s.Feed.Name, s.Item.Title)) + enclosureLabel(s.Item)
+		if s.Item.Unread {
+			d.appendToRendered(fromStringWithStyle(row, ansi.BOLD))
+		} else {
+			d.appendToRendered(fromString(row))
+		}
+	}
+
+	if len(d.raw) == 0 {
+		d.setTmpBottomMessage(2*time.Second, "no starred article: press s on an article to star it!")
+	}
+}
+
+// selectRowFeed makes the feed of the article under the cursor the current one, in a list of articles across feeds
+func (d *display) selectRowFeed() {
+	if d.inCrossFeedList() && d.currentRow() < len(d.rowFeeds) {
+		d.currentFeedUrl = d.rowFeeds[d.currentRow()]
+	}
+}
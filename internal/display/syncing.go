@@ -0,0 +1,101 @@
+package display
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/greader"
+)
+
+// how long the last sync is waited for when quitting
+const quitSyncTimeout = 10 * time.Second
+
+/*
+EnableSync syncs the cache with the server set in the config file, if any: in background at startup and then every sync-interval,
+after reloading all the feeds and when quitting, so that the articles read or starred here are read or starred there too
+*/
+func (d *display) EnableSync() error {
+
+	s, err := greader.NewConfiguredSyncer(d.config, d.cache)
+	if err != nil || s == nil {
+		return err
+	}
+	// the feeds and items shown are changed by the sync
+	s.SetLocker(&d.mu)
+	d.syncer = s
+
+	go func() {
+		d.syncRemote(context.Background())
+
+		interval := d.config.GetDuration(config.SYNC_INTERVAL)
+		if interval <= 0 {
+			return
+		}
+		for range time.Tick(interval) {
+			d.syncRemote(context.Background())
+		}
+	}()
+	return nil
+}
+
+// syncRemote runs a sync, if enabled, then shows the feeds it added and the new counts of unread items
+func (d *display) syncRemote(ctx context.Context) {
+
+	if d.syncer == nil {
+		return
+	}
+
+	res, err := d.syncer.Sync(ctx)
+
+	d.mu.Lock()
+	if err != nil {
+		log.Default().Println(err)
+		d.setTmpBottomMessage(2*time.Second, fmt.Sprintf("cannot sync (%s)!", syncErrMsg(err)))
+	} else {
+		log.Default().Printf("synced: %+v\n", res)
+	}
+	if d.currentSection != URLS_LIST {
+		d.mu.Unlock()
+		return
+	}
+	d.mu.Unlock()
+
+	if err == nil && res.Feeds != 0 {
+		if err := d.LoadFeedList(); err != nil {
+			log.Default().Println(err)
+		}
+	} else {
+		d.mu.Lock()
+		d.renderFeedList()
+		d.mu.Unlock()
+	}
+
+	d.refreshInBackground()
+}
+
+// SyncBeforeQuit pushes to the server the last changes, if syncing is enabled, waiting for a while at most
+func (d *display) SyncBeforeQuit() {
+	if d.syncer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), quitSyncTimeout)
+	defer cancel()
+	if _, err := d.syncer.Sync(ctx); err != nil {
+		log.Default().Println(err)
+	}
+}
+
+func syncErrMsg(err error) string {
+	switch {
+	case errors.Is(err, greader.ErrUnauthorized):
+		return "wrong credentials"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timed out"
+	default:
+		return "check logs"
+	}
+}
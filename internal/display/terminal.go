@@ -0,0 +1,136 @@
+package display
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+
+	"github.com/giulianopz/newscanoe/internal/ansi"
+	"github.com/giulianopz/newscanoe/internal/termios"
+	"github.com/giulianopz/newscanoe/internal/xterm"
+	"golang.org/x/sys/unix"
+)
+
+// EnableRawMode puts the terminal in raw mode saving its previous settings
+func (d *display) EnableRawMode() {
+	d.termMu.Lock()
+	defer d.termMu.Unlock()
+
+	d.acquireTerminal()
+}
+
+// DisableRawMode restores the terminal settings saved before entering raw mode
+func (d *display) DisableRawMode() {
+	d.termMu.Lock()
+	defer d.termMu.Unlock()
+
+	if d.rawMode {
+		termios.DisableRawMode(os.Stdin.Fd(), d.origTermios)
+		d.rawMode = false
+	}
+}
+
+/*
+Suspend gives the terminal back to the shell and stops the process
+as if SIGTSTP was handled with its default action:
+the terminal will be taken back by Resume once the process is continued
+*/
+func (d *display) Suspend() {
+	d.termMu.Lock()
+	if !d.foreground {
+		d.releaseTerminal()
+	}
+	d.termMu.Unlock()
+
+	log.Default().Println("suspending")
+	if err := unix.Kill(0, unix.SIGSTOP); err != nil {
+		log.Default().Printf("cannot stop process group: %v\n", err)
+	}
+}
+
+// Resume takes the terminal back after the process was continued (SIGCONT)
+func (d *display) Resume() {
+	d.termMu.Lock()
+	defer d.termMu.Unlock()
+
+	log.Default().Println("resuming")
+	if !d.foreground {
+		d.acquireTerminal()
+		d.RefreshScreen()
+	}
+}
+
+// inForeground reports whether an external program owns the terminal
+func (d *display) inForeground() bool {
+	d.termMu.Lock()
+	defer d.termMu.Unlock()
+
+	return d.foreground
+}
+
+/*
+refreshInBackground refreshes the screen on behalf of a goroutine other than the one reading the input,
+unless an external program owns the terminal, which cannot take it over while the screen is written
+*/
+func (d *display) refreshInBackground() {
+	d.termMu.Lock()
+	defer d.termMu.Unlock()
+
+	if !d.foreground {
+		d.RefreshScreen()
+	}
+}
+
+/*
+runInForeground hands the terminal over to an external program for the whole duration of its execution,
+restoring the terminal settings before starting it and re-entering raw mode after it exits.
+Every program launched by the app must go through here, so that it does not inherit the raw mode
+*/
+func (d *display) runInForeground(cmd *exec.Cmd) error {
+
+	d.termMu.Lock()
+	d.releaseTerminal()
+	d.foreground = true
+	d.termMu.Unlock()
+
+	defer func() {
+		d.termMu.Lock()
+		defer d.termMu.Unlock()
+
+		d.foreground = false
+		d.acquireTerminal()
+	}()
+
+	if cmd.Stdin == nil {
+		cmd.Stdin = os.Stdin
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	log.Default().Printf("running in foreground: %s\n", cmd.String())
+	return cmd.Run()
+}
+
+// releaseTerminal resets the screen and the terminal settings to the state found at startup
+func (d *display) releaseTerminal() {
+	if !d.rawMode {
+		return
+	}
+	fmt.Fprint(os.Stdout, ansi.SGR(ansi.ALL_ATTRIBUTES_OFF))
+	d.Clear()
+	termios.DisableRawMode(os.Stdin.Fd(), d.origTermios)
+	d.rawMode = false
+}
+
+// acquireTerminal enters raw mode and sets up the terminal modes needed by the app
+func (d *display) acquireTerminal() {
+	if d.rawMode {
+		return
+	}
+	d.origTermios = termios.EnableRawMode(os.Stdin.Fd())
+	d.rawMode = true
+
+	fmt.Fprint(os.Stdout, xterm.DISABLE_MOUSE_TRACKING)
+	fmt.Fprint(os.Stdout, xterm.ENABLE_BRACKETED_PASTE)
+}
@@ -0,0 +1,120 @@
+package display
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/sys/unix"
+)
+
+func TestRunInForeground(t *testing.T) {
+
+	tty := openPty(t)
+	stdin, stdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = tty, tty
+	t.Cleanup(func() {
+		os.Stdin, os.Stdout = stdin, stdout
+	})
+
+	fd := int(tty.Fd())
+	orig, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := New(false)
+	d.EnableRawMode()
+	t.Cleanup(d.DisableRawMode)
+
+	if !isRaw(t, fd) {
+		t.Fatal("want raw mode")
+	}
+
+	out := filepath.Join(t.TempDir(), "stty")
+	if err := d.runInForeground(exec.Command("sh", "-c", "stty -a > "+out)); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("the program gets the terminal settings found at startup", func(t *testing.T) {
+		bs, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		modes := strings.FieldsFunc(string(bs), func(r rune) bool {
+			return r == ' ' || r == ';' || r == '\n'
+		})
+		for _, m := range []string{"icanon", "echo", "isig"} {
+			if orig.Lflag&lflags[m] != 0 && !slices.Contains(modes, m) {
+				t.Errorf("got %q disabled for the program: %s", m, bs)
+			}
+		}
+	})
+
+	t.Run("raw mode is entered again once the program exits", func(t *testing.T) {
+		if !isRaw(t, fd) {
+			t.Error("want raw mode")
+		}
+		if d.inForeground() {
+			t.Error("want no program in foreground")
+		}
+	})
+
+	t.Run("the terminal settings found at startup are restored on exit", func(t *testing.T) {
+		d.DisableRawMode()
+		got, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Lflag != orig.Lflag || got.Iflag != orig.Iflag || got.Oflag != orig.Oflag {
+			t.Errorf("got %+v, want %+v", got, orig)
+		}
+	})
+}
+
+var lflags = map[string]uint32{
+	"icanon": unix.ICANON,
+	"echo":   unix.ECHO,
+	"isig":   unix.ISIG,
+}
+
+func isRaw(t *testing.T, fd int) bool {
+	t.Helper()
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return termios.Lflag&(unix.ICANON|unix.ECHO|unix.ISIG) == 0
+}
+
+// openPty returns the terminal side of a new pseudo-terminal, whose output is discarded
+func openPty(t *testing.T) *os.File {
+	t.Helper()
+
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("cannot open pseudo-terminal: %v", err)
+	}
+	t.Cleanup(func() { master.Close() })
+
+	if err := unix.IoctlSetPointerInt(int(master.Fd()), unix.TIOCSPTLCK, 0); err != nil {
+		t.Fatal(err)
+	}
+	n, err := unix.IoctlGetInt(int(master.Fd()), unix.TIOCGPTN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tty, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("cannot open pseudo-terminal: %v", err)
+	}
+	t.Cleanup(func() { tty.Close() })
+
+	go io.Copy(io.Discard, master)
+	return tty
+}
@@ -0,0 +1,454 @@
+package download
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+// states of a download
+const (
+	QUEUED = iota
+	DOWNLOADING
+	DONE
+	FAILED
+)
+
+// suffix of the files being downloaded, renamed once complete
+const partSuffix = ".part"
+
+// minimum interval between two progress notifications of the same download
+const progressInterval = 500 * time.Millisecond
+
+// Download is a media file, like a podcast episode, to be saved locally
+type Download struct {
+	Url string
+	// feed and item the file is attached to
+	FeedUrl, FeedName string
+	Title             string
+	Type              string
+	// local path of the downloaded file
+	Path string
+	// expected size in bytes, zero if unknown
+	Size int64
+	// bytes already written to disk
+	Received int64
+	State    int
+	// cause of the failure
+	Err string
+}
+
+// Progress returns the percentage of the bytes downloaded so far, -1 if the size is unknown
+func (d *Download) Progress() int {
+	if d.State == DONE {
+		return 100
+	}
+	if d.Size <= 0 {
+		return -1
+	}
+	return int(min(d.Received*100/d.Size, 100))
+}
+
+/*
+Queue is a persistent list of downloads, processed one at a time in order of insertion.
+Downloads are written to a temporary file, whose content is kept when interrupted:
+they are resumed with an HTTP range request, if supported by the server, when the queue is started again.
+*/
+type Queue struct {
+	mu        sync.Mutex
+	downloads []*Download
+	// file where the queue is stored
+	filePath string
+	// directory where downloaded files are saved
+	dir string
+
+	// client returns the HTTP client for the feed with the given url
+	client func(feedUrl string) (*http.Client, error)
+	// onChange is called whenever the state or the progress of a download changes
+	onChange func()
+
+	wake    chan struct{}
+	started bool
+}
+
+func NewQueue(filePath, dir string, client func(feedUrl string) (*http.Client, error)) *Queue {
+	return &Queue{
+		downloads: make([]*Download, 0),
+		filePath:  filePath,
+		dir:       dir,
+		client:    client,
+		onChange:  func() {},
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// OnChange sets the function called whenever the state or the progress of a download changes
+func (q *Queue) OnChange(f func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onChange = f
+}
+
+// Downloads returns a copy of the downloads in the queue
+func (q *Queue) Downloads() []Download {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ret := make([]Download, 0, len(q.downloads))
+	for _, d := range q.downloads {
+		ret = append(ret, *d)
+	}
+	return ret
+}
+
+// Get returns a copy of the download of the given url, if any
+func (q *Queue) Get(url string) (Download, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if d := q.find(url); d != nil {
+		return *d, true
+	}
+	return Download{}, false
+}
+
+func (q *Queue) find(url string) *Download {
+	for _, d := range q.downloads {
+		if d.Url == url {
+			return d
+		}
+	}
+	return nil
+}
+
+// Enqueue adds a download to the queue, returning false if its url was already there
+func (q *Queue) Enqueue(d *Download) (bool, error) {
+
+	q.mu.Lock()
+
+	if q.find(d.Url) != nil {
+		q.mu.Unlock()
+		return false, nil
+	}
+
+	d.State = QUEUED
+	d.Received = 0
+	d.Err = ""
+	d.Path = q.pathOf(d)
+	q.downloads = append(q.downloads, d)
+
+	err := q.encode()
+	q.mu.Unlock()
+
+	q.notify()
+	return true, err
+}
+
+// Retry puts a failed download back in the queue
+func (q *Queue) Retry(url string) error {
+
+	q.mu.Lock()
+	d := q.find(url)
+	if d == nil || d.State != FAILED {
+		q.mu.Unlock()
+		return fmt.Errorf("not a failed download: %s", url)
+	}
+	d.State = QUEUED
+	d.Err = ""
+
+	err := q.encode()
+	q.mu.Unlock()
+
+	q.notify()
+	return err
+}
+
+// Remove removes a download from the queue, deleting its partial file if not complete: a complete file is kept
+func (q *Queue) Remove(url string) error {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, d := range q.downloads {
+		if d.Url == url {
+			if d.State == DOWNLOADING {
+				return fmt.Errorf("download in progress: %s", url)
+			}
+			if d.State != DONE {
+				if err := os.Remove(d.Path + partSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+					log.Default().Printf("cannot remove partial download: %v\n", err)
+				}
+			}
+			q.downloads = append(q.downloads[:i], q.downloads[i+1:]...)
+			return q.encode()
+		}
+	}
+	return nil
+}
+
+// pathOf returns where to save a download, i.e. a directory named after its feed, avoiding clashes with other downloads
+func (q *Queue) pathOf(d *Download) string {
+
+	name := "download"
+	if u, err := url.Parse(d.Url); err == nil {
+		if base := path.Base(u.Path); base != "." && base != "/" {
+			name = base
+		}
+	}
+
+	p := filepath.Join(q.dir, sanitize(d.FeedName), sanitize(name))
+
+	ext := filepath.Ext(p)
+	candidate := p
+	for n := 1; q.taken(candidate); n++ {
+		candidate = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(p, ext), n, ext)
+	}
+	return candidate
+}
+
+func (q *Queue) taken(p string) bool {
+	for _, d := range q.downloads {
+		if d.Path == p {
+			return true
+		}
+	}
+	_, err := os.Stat(p)
+	return err == nil
+}
+
+// sanitize turns a string into a valid file name
+func sanitize(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', 0:
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+// Start processes the queue in background, resuming the downloads interrupted in a previous session
+func (q *Queue) Start() {
+
+	q.mu.Lock()
+	if q.started {
+		q.mu.Unlock()
+		return
+	}
+	q.started = true
+	q.mu.Unlock()
+
+	go func() {
+		for {
+			if d := q.next(); d != nil {
+				q.process(d)
+				continue
+			}
+			<-q.wake
+		}
+	}()
+}
+
+// notify wakes up the goroutine processing the queue
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next marks the first queued download as in progress and returns it
+func (q *Queue) next() *Download {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, d := range q.downloads {
+		if d.State == QUEUED {
+			d.State = DOWNLOADING
+			return d
+		}
+	}
+	return nil
+}
+
+func (q *Queue) process(d *Download) {
+
+	q.changed()
+
+	err := q.fetch(d)
+
+	q.mu.Lock()
+	if err != nil {
+		log.Default().Printf("cannot download %s: %v\n", d.Url, err)
+		d.State = FAILED
+		d.Err = err.Error()
+	} else {
+		d.State = DONE
+	}
+	if err := q.encode(); err != nil {
+		log.Default().Printf("cannot save download queue: %v\n", err)
+	}
+	q.mu.Unlock()
+
+	q.changed()
+}
+
+// fetch downloads a file, resuming from what was saved so far to its temporary file
+func (q *Queue) fetch(d *Download) error {
+
+	q.mu.Lock()
+	feedUrl, target, dest := d.FeedUrl, d.Url, d.Path
+	q.mu.Unlock()
+
+	client, err := q.client(feedUrl)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	part := dest + partSuffix
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// the file was already complete
+		return os.Rename(part, dest)
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		// the server ignored the range request, if any
+		flags |= os.O_TRUNC
+		offset = 0
+	default:
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	q.mu.Lock()
+	d.Received = offset
+	if resp.ContentLength > 0 {
+		d.Size = offset + resp.ContentLength
+	}
+	q.mu.Unlock()
+
+	f, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, &progressReader{r: resp.Body, q: q, d: d})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(part, dest)
+}
+
+func (q *Queue) changed() {
+	q.mu.Lock()
+	onChange := q.onChange
+	q.mu.Unlock()
+
+	onChange()
+}
+
+// progressReader counts the bytes read from a response body, notifying the queue at regular intervals
+type progressReader struct {
+	r    io.Reader
+	q    *Queue
+	d    *Download
+	last time.Time
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+
+	p.q.mu.Lock()
+	p.d.Received += int64(n)
+	p.q.mu.Unlock()
+
+	if time.Since(p.last) > progressInterval {
+		p.last = time.Now()
+		p.q.changed()
+	}
+	return n, err
+}
+
+// encode writes the queue to its file, the caller holding the lock
+func (q *Queue) encode() error {
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(q.downloads); err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(q.filePath, buf.Bytes(), 0644)
+}
+
+// Decode reads the queue from its file, putting back in the queue the downloads interrupted in a previous session
+func (q *Queue) Decode() error {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	file, err := os.Open(q.filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	var downloads []*Download
+	if err := gob.NewDecoder(file).Decode(&downloads); err != nil {
+		return err
+	}
+
+	for _, d := range downloads {
+		if d.State == DOWNLOADING {
+			d.State = QUEUED
+		}
+	}
+	q.downloads = downloads
+	return nil
+}
@@ -0,0 +1,122 @@
+package download
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQueue(t *testing.T) {
+
+	episode := bytes.Repeat([]byte("0123456789"), 10_000)
+
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if r.URL.Path == "/missing.mp3" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "episode.mp3", time.Time{}, bytes.NewReader(episode))
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	queueFile := filepath.Join(dir, "downloads.gob")
+	client := func(string) (*http.Client, error) { return server.Client(), nil }
+
+	newQueue := func(t *testing.T) (*Queue, chan struct{}) {
+		t.Helper()
+		q := NewQueue(queueFile, dir, client)
+		if err := q.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		changes := make(chan struct{}, 100)
+		q.OnChange(func() { changes <- struct{}{} })
+		return q, changes
+	}
+
+	waitFor := func(t *testing.T, q *Queue, changes chan struct{}, url string, state int) Download {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			if d, _ := q.Get(url); d.State == state {
+				return d
+			}
+			select {
+			case <-changes:
+			case <-timeout:
+				d, _ := q.Get(url)
+				t.Fatalf("want state %d, got: %+v", state, d)
+			}
+		}
+	}
+
+	t.Run("resumes a partial download", func(t *testing.T) {
+
+		url := server.URL + "/episodes/episode.mp3"
+		dest := filepath.Join(dir, "Podcast", "episode.mp3")
+
+		// what was saved before being interrupted
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dest+partSuffix, episode[:30_000], 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		q, changes := newQueue(t)
+		added, err := q.Enqueue(&Download{Url: url, FeedName: "Podcast", Title: "Episode 1"})
+		if err != nil || !added {
+			t.Fatalf("cannot enqueue: %v", err)
+		}
+		if added, _ := q.Enqueue(&Download{Url: url, FeedName: "Podcast"}); added {
+			t.Fatal("enqueued the same url twice")
+		}
+		q.Start()
+
+		d := waitFor(t, q, changes, url, DONE)
+		if d.Path != dest || d.Progress() != 100 {
+			t.Errorf("unexpected download: %+v", d)
+		}
+		if got := ranges[len(ranges)-1]; got != "bytes=30000-" {
+			t.Errorf("want range request, got: %q", got)
+		}
+		got, err := os.ReadFile(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, episode) {
+			t.Errorf("corrupted download of %d bytes", len(got))
+		}
+	})
+
+	t.Run("persists failures", func(t *testing.T) {
+
+		url := server.URL + "/missing.mp3"
+
+		q, changes := newQueue(t)
+		if _, err := q.Enqueue(&Download{Url: url, FeedName: "Podcast"}); err != nil {
+			t.Fatal(err)
+		}
+		q.Start()
+		waitFor(t, q, changes, url, FAILED)
+
+		q, _ = newQueue(t)
+		downloads := q.Downloads()
+		if len(downloads) != 2 || downloads[0].State != DONE || downloads[1].State != FAILED || downloads[1].Err == "" {
+			t.Errorf("unexpected queue: %+v", downloads)
+		}
+
+		if err := q.Remove(url); err != nil {
+			t.Fatal(err)
+		}
+		if len(q.Downloads()) != 1 {
+			t.Errorf("download not removed")
+		}
+	})
+}
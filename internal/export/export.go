@@ -0,0 +1,401 @@
+package export
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	neturl "net/url"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/cache"
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/search"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+// formats of the exported feed
+const (
+	RSS  = "rss"
+	ATOM = "atom"
+	JSON = "json"
+)
+
+// length in runes of the passage of text shown for every item in the HTML page
+const summaryWidth = 280
+
+// Meta describes the exported feed as a whole
+type Meta struct {
+	Title string
+	// the web page the feed belongs to, if any
+	Link string
+	// when the feed was built
+	Updated time.Time
+}
+
+/*
+Entries returns the cached items published since the given time, if not zero, from the most recent, of all feeds
+or only of the ones annotated with the given tag, if any, and named as in the config file.
+The cache is queried rather than loaded as a whole, which for a database means looking up its indexes
+*/
+func Entries(c *cache.Cache, conf *config.Config, since time.Time, tag string) ([]*cache.Entry, error) {
+
+	entries, err := c.Items(cache.Query{Since: since})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(conf.Feeds))
+	for _, f := range conf.Feeds {
+		names[f.Url] = f.Name
+	}
+
+	selected := make([]*cache.Entry, 0, len(entries))
+	for _, e := range entries {
+		if tag != "" && !conf.HasAnnotation(e.Feed.Url, tag) {
+			continue
+		}
+		if name, found := names[e.Feed.Url]; found {
+			e.Feed.Name = name
+		}
+		selected = append(selected, e)
+	}
+	return selected, nil
+}
+
+/*
+Write writes the given items of several feeds as a single feed in the given format, in the given order,
+attributing every item to its feed: in RSS by the source element, in Atom by the source and author elements,
+in JSON Feed by its author and a _source extension
+*/
+func Write(w io.Writer, format string, meta Meta, entries []*cache.Entry) error {
+	switch format {
+	case RSS:
+		return writeRSS(w, meta, entries)
+	case ATOM:
+		return writeAtom(w, meta, entries)
+	case JSON:
+		return writeJSON(w, meta, entries)
+	default:
+		return fmt.Errorf("unknown feed format: %q", format)
+	}
+}
+
+// guid returns a unique id of an item: its url, unless missing
+func guid(e *cache.Entry) string {
+	if e.Item.Url != "" {
+		return e.Item.Url
+	}
+	return e.Feed.Url + "#" + neturl.QueryEscape(e.Item.Title)
+}
+
+func hasPubDate(i *feed.Item) bool {
+	return i.PubDate != util.NoPubDate && !i.PubDate.IsZero()
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string     `xml:"title"`
+	Link          string     `xml:"link"`
+	Description   string     `xml:"description"`
+	LastBuildDate string     `xml:"lastBuildDate"`
+	Generator     string     `xml:"generator"`
+	Items         []*rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link,omitempty"`
+	Description string        `xml:"description,omitempty"`
+	PubDate     string        `xml:"pubDate,omitempty"`
+	Guid        rssGuid       `xml:"guid"`
+	Source      rssSource     `xml:"source"`
+	Enclosure   *rssEnclosure `xml:"enclosure"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssSource struct {
+	Url  string `xml:"url,attr"`
+	Name string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	Url    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// see: https://www.rssboard.org/rss-specification
+func writeRSS(w io.Writer, meta Meta, entries []*cache.Entry) error {
+
+	doc := rss{Version: "2.0", Channel: rssChannel{
+		Title:         meta.Title,
+		Link:          meta.Link,
+		Description:   meta.Title,
+		LastBuildDate: meta.Updated.Format(time.RFC1123Z),
+		Generator:     "newscanoe",
+		Items:         make([]*rssItem, 0, len(entries)),
+	}}
+
+	for _, e := range entries {
+		item := &rssItem{
+			Title:       e.Item.Title,
+			Link:        e.Item.Url,
+			Description: e.Item.Content,
+			Guid:        rssGuid{IsPermaLink: e.Item.Url != "", Value: guid(e)},
+			Source:      rssSource{Url: e.Feed.Url, Name: e.Feed.Name},
+		}
+		if hasPubDate(e.Item) {
+			item.PubDate = e.Item.PubDate.Format(time.RFC1123Z)
+		}
+		// RSS allows a single enclosure per item
+		if len(e.Item.Enclosures) != 0 {
+			enc := e.Item.Enclosures[0]
+			item.Enclosure = &rssEnclosure{Url: enc.Url, Length: enc.Length, Type: enc.Type}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+
+	return writeXML(w, doc)
+}
+
+type atomFeed struct {
+	XMLName xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string       `xml:"title"`
+	Id      string       `xml:"id"`
+	Updated string       `xml:"updated"`
+	Link    []atomLink   `xml:"link"`
+	Author  atomPerson   `xml:"author"`
+	Entries []*atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title     string       `xml:"title"`
+	Id        string       `xml:"id"`
+	Updated   string       `xml:"updated"`
+	Published string       `xml:"published,omitempty"`
+	Link      []atomLink   `xml:"link"`
+	Author    atomPerson   `xml:"author"`
+	Content   *atomContent `xml:"content"`
+	Source    atomSource   `xml:"source"`
+}
+
+type atomLink struct {
+	Rel    string `xml:"rel,attr,omitempty"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	Uri  string `xml:"uri,omitempty"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomSource struct {
+	Id    string     `xml:"id"`
+	Title string     `xml:"title"`
+	Link  []atomLink `xml:"link"`
+}
+
+// see: https://datatracker.ietf.org/doc/html/rfc4287
+func writeAtom(w io.Writer, meta Meta, entries []*cache.Entry) error {
+
+	id := meta.Link
+	if id == "" {
+		id = "urn:newscanoe:export"
+	}
+	doc := atomFeed{
+		Title:   meta.Title,
+		Id:      id,
+		Updated: meta.Updated.Format(time.RFC3339),
+		Author:  atomPerson{Name: meta.Title},
+		Entries: make([]*atomEntry, 0, len(entries)),
+	}
+	if meta.Link != "" {
+		doc.Link = []atomLink{{Rel: "alternate", Href: meta.Link}}
+	}
+
+	for _, e := range entries {
+
+		// every entry must have an update time
+		updated := meta.Updated
+		entry := &atomEntry{
+			Title:  e.Item.Title,
+			Id:     guid(e),
+			Author: atomPerson{Name: e.Feed.Name, Uri: e.Feed.Url},
+			Source: atomSource{Id: e.Feed.Url, Title: e.Feed.Name, Link: []atomLink{{Rel: "self", Href: e.Feed.Url}}},
+		}
+		if hasPubDate(e.Item) {
+			updated = e.Item.PubDate
+			entry.Published = e.Item.PubDate.Format(time.RFC3339)
+		}
+		entry.Updated = updated.Format(time.RFC3339)
+		if e.Item.Url != "" {
+			entry.Link = append(entry.Link, atomLink{Rel: "alternate", Href: e.Item.Url})
+		}
+		for _, enc := range e.Item.Enclosures {
+			entry.Link = append(entry.Link, atomLink{Rel: "enclosure", Href: enc.Url, Type: enc.Type, Length: enc.Length})
+		}
+		if e.Item.Content != "" {
+			entry.Content = &atomContent{Type: "html", Value: e.Item.Content}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type jsonFeed struct {
+	Version     string      `json:"version"`
+	Title       string      `json:"title"`
+	HomePageUrl string      `json:"home_page_url,omitempty"`
+	Items       []*jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	Id            string           `json:"id"`
+	Url           string           `json:"url,omitempty"`
+	Title         string           `json:"title"`
+	ContentHtml   string           `json:"content_html,omitempty"`
+	DatePublished string           `json:"date_published,omitempty"`
+	Authors       []jsonAuthor     `json:"authors"`
+	Attachments   []jsonAttachment `json:"attachments,omitempty"`
+	// the feed of the item, as an extension
+	Source jsonAuthor `json:"_source"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+type jsonAttachment struct {
+	Url         string `json:"url"`
+	MimeType    string `json:"mime_type"`
+	SizeInBytes int64  `json:"size_in_bytes,omitempty"`
+}
+
+// see: https://www.jsonfeed.org/version/1.1/
+func writeJSON(w io.Writer, meta Meta, entries []*cache.Entry) error {
+
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       meta.Title,
+		HomePageUrl: meta.Link,
+		Items:       make([]*jsonItem, 0, len(entries)),
+	}
+
+	for _, e := range entries {
+		source := jsonAuthor{Name: e.Feed.Name, Url: e.Feed.Url}
+		item := &jsonItem{
+			Id:          guid(e),
+			Url:         e.Item.Url,
+			Title:       e.Item.Title,
+			ContentHtml: e.Item.Content,
+			Authors:     []jsonAuthor{source},
+			Source:      source,
+		}
+		if hasPubDate(e.Item) {
+			item.DatePublished = e.Item.PubDate.Format(time.RFC3339)
+		}
+		for _, enc := range e.Item.Enclosures {
+			item.Attachments = append(item.Attachments, jsonAttachment{Url: enc.Url, MimeType: enc.Type, SizeInBytes: enc.Length})
+		}
+		doc.Items = append(doc.Items, item)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+var page = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="generator" content="newscanoe">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 48em; margin: 2em auto; padding: 0 1em; font-family: sans-serif; line-height: 1.5; }
+article { margin-bottom: 1.5em; }
+.meta { color: #666; font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>{{if .Link}}<a href="{{.Link}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}</h1>
+{{range .Items}}<article>
+<h2>{{if .Url}}<a href="{{.Url}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}</h2>
+<p class="meta"><a href="{{.FeedUrl}}">{{.FeedName}}</a>{{if .Date}} &middot; <time datetime="{{.Datetime}}">{{.Date}}</time>{{end}}</p>
+{{if .Summary}}<p>{{.Summary}}</p>{{end}}
+</article>
+{{end}}<p class="meta">Updated on {{.Updated}}</p>
+</body>
+</html>
+`))
+
+// WriteHTML writes the given items as a static web page, every item followed by a passage of its text and the name of its feed
+func WriteHTML(w io.Writer, meta Meta, entries []*cache.Entry) error {
+
+	type pageItem struct {
+		Title, Url, FeedName, FeedUrl, Date, Datetime, Summary string
+	}
+
+	items := make([]*pageItem, 0, len(entries))
+	for _, e := range entries {
+		item := &pageItem{
+			Title:    e.Item.Title,
+			Url:      e.Item.Url,
+			FeedName: e.Feed.Name,
+			FeedUrl:  e.Feed.Url,
+			Summary:  search.Snippet(search.Text(e.Item), "", summaryWidth),
+		}
+		if hasPubDate(e.Item) {
+			item.Date = e.Item.PubDate.Format("2 January 2006")
+			item.Datetime = e.Item.PubDate.Format(time.RFC3339)
+		}
+		items = append(items, item)
+	}
+
+	return page.Execute(w, map[string]any{
+		"Title":   meta.Title,
+		"Link":    meta.Link,
+		"Updated": meta.Updated.Format("2 January 2006, 15:04 MST"),
+		"Items":   items,
+	})
+}
+
+// Formats returns the supported formats, for usage messages
+func Formats() string {
+	return RSS + "|" + ATOM + "|" + JSON
+}
@@ -0,0 +1,188 @@
+package export
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/cache"
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/util"
+	"github.com/mmcdole/gofeed"
+)
+
+func entries() []*cache.Entry {
+
+	lwn := feed.NewFeed("LWN").WithUrl("https://lwn.net/headlines/rss")
+	podcast := feed.NewFeed("Podcast & Co.").WithUrl("https://example.com/podcast?format=rss")
+
+	release := feed.NewItem("Kernel release", "https://lwn.net/Articles/1/", time.Date(2024, 8, 3, 10, 0, 0, 0, time.UTC))
+	release.Content = "<p>The <b>6.11</b> kernel is out.</p>"
+	episode := feed.NewItem("Episode 1", "https://example.com/1", time.Date(2024, 8, 2, 10, 0, 0, 0, time.UTC))
+	episode.Enclosures = []*feed.Enclosure{{Url: "https://example.com/1.mp3", Type: "audio/mpeg", Length: 1024}}
+	undated := feed.NewItem("Undated", "", util.NoPubDate)
+
+	return []*cache.Entry{
+		{Feed: lwn, Item: release},
+		{Feed: podcast, Item: episode},
+		{Feed: lwn, Item: undated},
+	}
+}
+
+func TestWrite(t *testing.T) {
+
+	meta := Meta{Title: "Blogroll", Link: "https://example.org/", Updated: time.Date(2024, 8, 4, 0, 0, 0, 0, time.UTC)}
+
+	for _, format := range []string{RSS, ATOM, JSON} {
+		t.Run(format, func(t *testing.T) {
+
+			var buf bytes.Buffer
+			if err := Write(&buf, format, meta, entries()); err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err := gofeed.NewParser().ParseString(buf.String())
+			if err != nil {
+				t.Fatalf("cannot parse the exported feed: %v\n%s", err, buf.String())
+			}
+			if parsed.Title != "Blogroll" || len(parsed.Items) != 3 {
+				t.Fatalf("unexpected feed: %q with %d items", parsed.Title, len(parsed.Items))
+			}
+
+			first := parsed.Items[0]
+			if first.Title != "Kernel release" || first.Link != "https://lwn.net/Articles/1/" {
+				t.Errorf("unexpected first item: %q %q", first.Title, first.Link)
+			}
+			if first.PublishedParsed == nil || !first.PublishedParsed.Equal(entries()[0].Item.PubDate) {
+				t.Errorf("got publication date %v, want %v", first.PublishedParsed, entries()[0].Item.PubDate)
+			}
+			if !strings.Contains(first.Content+first.Description, "6.11") {
+				t.Errorf("content of item not exported: %q", first.Content+first.Description)
+			}
+			if encs := parsed.Items[1].Enclosures; len(encs) != 1 || encs[0].URL != "https://example.com/1.mp3" {
+				t.Errorf("unexpected enclosures: %+v", encs)
+			}
+			// an Atom entry must have an update time, taken by parsers as the publication date when missing
+			if format != ATOM && parsed.Items[2].PublishedParsed != nil {
+				t.Errorf("made-up publication date of undated item: %v", parsed.Items[2].PublishedParsed)
+			}
+
+			// every item is attributed to its feed
+			out := buf.String()
+			for _, source := range []string{"https://lwn.net/headlines/rss", "Podcast &amp; Co.", "https://example.com/podcast?format=rss"} {
+				if format == JSON {
+					source = strings.ReplaceAll(source, "&amp;", "\\u0026")
+				}
+				if !strings.Contains(out, source) {
+					t.Errorf("source %q missing from exported feed:\n%s", source, out)
+				}
+			}
+		})
+	}
+
+	if err := Write(&bytes.Buffer{}, "opml", meta, entries()); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, Meta{Title: "Blogroll <team>", Updated: time.Now()}, entries()); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+
+	for _, want := range []string{
+		"<title>Blogroll &lt;team&gt;</title>",
+		`<a href="https://lwn.net/Articles/1/">Kernel release</a>`,
+		`<a href="https://example.com/podcast?format=rss">Podcast &amp; Co.</a>`,
+		`<time datetime="2024-08-03T10:00:00Z">3 August 2024</time>`,
+		"<p>The 6.11 kernel is out.</p>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("%q missing from page:\n%s", want, page)
+		}
+	}
+	if strings.Index(page, "Kernel release") > strings.Index(page, "Episode 1") {
+		t.Error("items not in the given order")
+	}
+}
+
+func TestEntries(t *testing.T) {
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	day := func(d int) time.Time {
+		return time.Date(2024, 8, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	c, err := cache.Open(cache.BOLT_BACKEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.AddFeed(&feed.Feed{Name: "LWN", Url: "https://lwn.net/headlines/rss", Items: []*feed.Item{
+		feed.NewItem("Kernel release", "https://lwn.net/Articles/1/", day(3)),
+		feed.NewItem("Old news", "https://lwn.net/Articles/0/", day(1)),
+	}}, "https://lwn.net/headlines/rss")
+	c.AddFeed(&feed.Feed{Name: "Example", Url: "https://example.com/rss", Items: []*feed.Item{
+		feed.NewItem("Hello", "https://example.com/1", day(2)),
+	}}, "https://example.com/rss")
+	if err := c.Encode(); err != nil {
+		t.Fatal(err)
+	}
+
+	confPath := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(confPath, []byte("https://lwn.net/headlines/rss #\"LWN.net\" #\"team\"\nhttps://example.com/rss #\"Example\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf := config.NewConfig()
+	if err := conf.Decode(confPath); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		since time.Time
+		tag   string
+		want  []string
+	}{
+		{"all", time.Time{}, "", []string{"Kernel release", "Hello", "Old news"}},
+		{"since", day(2), "", []string{"Kernel release", "Hello"}},
+		{"tagged", time.Time{}, "team", []string{"Kernel release", "Old news"}},
+		{"tagged since", day(2), "team", []string{"Kernel release"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// looked up in the indexes of the database
+			unloaded, err := cache.Open(cache.BOLT_BACKEND)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries, err := Entries(unloaded, conf, tt.since, tt.tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(unloaded.GetFeeds()) != 0 {
+				t.Error("cache loaded as a whole")
+			}
+
+			got := make([]string, 0, len(entries))
+			for _, e := range entries {
+				got = append(got, e.Item.Title)
+				if e.Feed.Url == "https://lwn.net/headlines/rss" && e.Feed.Name != "LWN.net" {
+					t.Errorf("feed not named as in the config file: %q", e.Feed.Name)
+				}
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package feed
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Auth holds the credentials sent to fetch a feed
+type Auth struct {
+	// HTTP Basic authentication
+	User, Password string
+	// bearer token sent with the Authorization header
+	Token string
+	// custom headers
+	Header http.Header
+	// cookies sent to the hosts they belong to: the host of their domain only, or its subdomains too if it starts with a dot
+	Cookies []*http.Cookie
+}
+
+func (a *Auth) apply(req *http.Request) {
+	if a == nil {
+		return
+	}
+
+	for name, values := range a.Header {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+
+	if a.User != "" {
+		req.SetBasicAuth(a.User, a.Password)
+	} else if a.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	}
+
+	a.addCookies(req)
+}
+
+// addCookies adds the cookies belonging to the url of a request, as a browser would send them
+func (a *Auth) addCookies(req *http.Request) {
+	host := req.URL.Hostname()
+	for _, c := range a.Cookies {
+		if domain, subdomains := strings.CutPrefix(c.Domain, "."); subdomains && !isDomainOrSubdomain(host, domain) {
+			continue
+		} else if !subdomains && domain != "" && !strings.EqualFold(host, domain) {
+			continue
+		}
+		if c.Secure && req.URL.Scheme != "https" {
+			continue
+		}
+		if !pathMatches(req.URL.Path, c.Path) {
+			continue
+		}
+		req.AddCookie(c)
+	}
+}
+
+/*
+client returns a copy of the given client which, when following redirects, sends the cookies belonging to every url
+and drops the custom headers when leaving the host of the feed, as the client itself does with the Authorization header
+*/
+func (a *Auth) client(c *http.Client) *http.Client {
+	if a == nil || (len(a.Header) == 0 && len(a.Cookies) == 0) {
+		return c
+	}
+
+	redirecting := *c
+	redirecting.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if c.CheckRedirect != nil {
+			if err := c.CheckRedirect(req, via); err != nil {
+				return err
+			}
+		} else if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+
+		if !isDomainOrSubdomain(req.URL.Hostname(), via[0].URL.Hostname()) {
+			for name := range a.Header {
+				req.Header.Del(name)
+			}
+		}
+
+		// copied from the first request, whatever the url
+		req.Header.Del("Cookie")
+		a.addCookies(req)
+		return nil
+	}
+	return &redirecting
+}
+
+// the max number of redirects followed, as by the default policy of http.Client
+const maxRedirects = 10
+
+// isDomainOrSubdomain reports whether host is the given domain or one of its subdomains
+func isDomainOrSubdomain(host, domain string) bool {
+	host, domain = strings.ToLower(host), strings.ToLower(domain)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// pathMatches reports whether a cookie with the given path belongs to a request path, see: https://www.rfc-editor.org/rfc/rfc6265#section-5.1.4
+func pathMatches(reqPath, cookiePath string) bool {
+	if reqPath == "" {
+		reqPath = "/"
+	}
+	if cookiePath == "" || cookiePath == "/" {
+		return true
+	}
+	if !strings.HasPrefix(reqPath, cookiePath) {
+		return false
+	}
+	return len(reqPath) == len(cookiePath) || strings.HasSuffix(cookiePath, "/") || reqPath[len(cookiePath)] == '/'
+}
+
+/*
+LoadCookies reads a cookie file in the Netscape format, as written by curl or by browser extensions,
+skipping expired cookies.
+see: https://curl.se/docs/http-cookies.html
+*/
+func LoadCookies(path string) ([]*http.Cookie, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cookies := make([]*http.Cookie, 0)
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+
+		line := strings.TrimPrefix(strings.TrimSpace(s.Text()), "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("malformed line in cookie file %s: %q", path, line)
+		}
+
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed expiry in cookie file %s: %q", path, line)
+		}
+		if expiry != 0 && time.Unix(expiry, 0).Before(time.Now()) {
+			continue
+		}
+
+		// the include-subdomains flag
+		domain := strings.TrimPrefix(fields[0], ".")
+		if fields[1] == "TRUE" {
+			domain = "." + domain
+		}
+
+		cookies = append(cookies, &http.Cookie{
+			Domain: domain,
+			Path:   fields[2],
+			Secure: fields[3] == "TRUE",
+			Name:   fields[5],
+			Value:  fields[6],
+		})
+	}
+
+	return cookies, s.Err()
+}
@@ -0,0 +1,180 @@
+package feed
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const rssFeed = `<?xml version="1.0"?><rss version="2.0"><channel><title>Private</title>
+<item><title>Hello</title><link>https://example.com/hello</link></item></channel></rss>`
+
+func TestParseWithAuth(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		session, _ := r.Cookie("session")
+		switch {
+		case user == "me" && password == "secret",
+			r.Header.Get("Authorization") == "Bearer t0k3n",
+			r.Header.Get("X-Api-Key") == "key",
+			session != nil && session.Value == "abc":
+			w.Write([]byte(rssFeed))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cookieFile := filepath.Join(t.TempDir(), "cookies.txt")
+	cookies := "# Netscape HTTP Cookie File\n" +
+		"#HttpOnly_127.0.0.1\tFALSE\t/\tFALSE\t0\tsession\tabc\n" +
+		"127.0.0.1\tFALSE\t/\tFALSE\t1\texpired\tx\n"
+	if err := os.WriteFile(cookieFile, []byte(cookies), 0600); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCookies(cookieFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 1 {
+		t.Fatalf("want 1 cookie, got %d", len(loaded))
+	}
+
+	tests := []struct {
+		name    string
+		auth    *Auth
+		wantErr bool
+	}{
+		{"no credentials", nil, true},
+		{"basic", &Auth{User: "me", Password: "secret"}, false},
+		{"wrong password", &Auth{User: "me", Password: "wrong"}, true},
+		{"bearer token", &Auth{Token: "t0k3n"}, false},
+		{"custom header", &Auth{Header: http.Header{"X-Api-Key": {"key"}}}, false},
+		{"cookie file", &Auth{Cookies: loaded}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewParser().Parse(server.URL, server.Client(), tt.auth)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error: %v, got: %v", tt.wantErr, err)
+			}
+			if err == nil && f.Name != "Private" {
+				t.Errorf("unexpected feed name: %q", f.Name)
+			}
+		})
+	}
+}
+
+func TestCookieAttributes(t *testing.T) {
+
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			// to the feed, with the cookies belonging to its path
+			http.Redirect(w, r, "/private/feed", http.StatusFound)
+			return
+		}
+		for _, c := range r.Cookies() {
+			sent = append(sent, c.Name)
+		}
+		w.Write([]byte(rssFeed))
+	}))
+	t.Cleanup(server.Close)
+
+	auth := &Auth{Cookies: []*http.Cookie{
+		{Domain: "127.0.0.1", Path: "/", Name: "everywhere", Value: "1"},
+		{Domain: "127.0.0.1", Path: "/private", Name: "private", Value: "1"},
+		{Domain: "127.0.0.1", Path: "/priv", Name: "prefix", Value: "1"},
+		{Domain: "127.0.0.1", Path: "/public", Name: "public", Value: "1"},
+		{Domain: "127.0.0.1", Path: "/", Secure: true, Name: "secure", Value: "1"},
+		{Domain: "example.com", Path: "/", Name: "other", Value: "1"},
+	}}
+
+	if _, err := NewParser().Parse(server.URL, server.Client(), auth); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 || sent[0] != "everywhere" || sent[1] != "private" {
+		t.Errorf("got cookies %q, want the ones of the feed path and not secure", sent)
+	}
+}
+
+func TestCookieSubdomains(t *testing.T) {
+
+	cookieFile := filepath.Join(t.TempDir(), "cookies.txt")
+	cookies := "example.com\tFALSE\t/\tFALSE\t0\thost-only\t1\n" +
+		".example.com\tTRUE\t/\tFALSE\t0\tsubdomains\t1\n" +
+		"feeds.example.com\tFALSE\t/\tFALSE\t0\tfeeds\t1\n"
+	if err := os.WriteFile(cookieFile, []byte(cookies), 0600); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCookies(cookieFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://feeds.example.com/rss", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	(&Auth{Cookies: loaded}).addCookies(req)
+
+	sent := make([]string, 0)
+	for _, c := range req.Cookies() {
+		sent = append(sent, c.Name)
+	}
+	if len(sent) != 2 || sent[0] != "subdomains" || sent[1] != "feeds" {
+		t.Errorf("got cookies %q, want the ones including subdomains and the ones of the host", sent)
+	}
+}
+
+func TestAuthOnRedirect(t *testing.T) {
+
+	var header, cookie string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("X-Api-Key")
+		if c, err := r.Cookie("session"); err == nil {
+			cookie = c.Value
+		}
+		w.Write([]byte(rssFeed))
+	}))
+	t.Cleanup(target.Close)
+
+	// the same server under another name
+	_, port, _ := net.SplitHostPort(target.Listener.Addr().String())
+	otherHost := "http://localhost:" + port
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusFound)
+	}))
+	t.Cleanup(origin.Close)
+
+	auth := &Auth{
+		Header:  http.Header{"X-Api-Key": {"key"}},
+		Cookies: []*http.Cookie{{Domain: "127.0.0.1", Path: "/", Name: "session", Value: "abc"}},
+	}
+
+	tests := []struct {
+		name                   string
+		to                     string
+		wantHeader, wantCookie string
+	}{
+		{"same host", target.URL + "/feed", "key", "abc"},
+		{"other host", otherHost + "/feed", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, cookie = "", ""
+			if _, err := NewParser().Parse(origin.URL+"/?to="+url.QueryEscape(tt.to), origin.Client(), auth); err != nil {
+				t.Fatal(err)
+			}
+			if header != tt.wantHeader || cookie != tt.wantCookie {
+				t.Errorf("got header %q and cookie %q, want %q and %q", header, cookie, tt.wantHeader, tt.wantCookie)
+			}
+		})
+	}
+}
@@ -1,9 +1,11 @@
 package feed
 
 import (
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/giulianopz/newscanoe/internal/html"
 	"github.com/giulianopz/newscanoe/internal/util"
 	"github.com/mmcdole/gofeed"
 	"golang.org/x/exp/slices"
@@ -14,6 +16,10 @@ type Feed struct {
 	Url         string
 	Items       []*Item
 	UnreadCount int
+	// minimum interval between two refreshes, as advertised by the feed or by its server
+	TTL time.Duration
+	// last time the feed was fetched
+	LastRefresh time.Time
 }
 
 func NewFeed(name string) *Feed {
@@ -27,6 +33,9 @@ func NewFeedFrom(parsedFeed *gofeed.Feed, url string) *Feed {
 	title := strings.TrimSpace(parsedFeed.Title)
 
 	f := NewFeed(title).WithUrl(url)
+	if ttl, found := parsedFeed.Custom[ttlKey]; found {
+		f.TTL, _ = time.ParseDuration(ttl)
+	}
 
 	for _, parsedItem := range parsedFeed.Items {
 		f.Items = append(f.Items, NewItemFrom(parsedItem))
@@ -41,6 +50,16 @@ func (f *Feed) HasItem(title string) bool {
 	})
 }
 
+// GetItem returns the item with the given title, if any
+func (f *Feed) GetItem(title string) *Item {
+	for _, i := range f.Items {
+		if i.Title == title {
+			return i
+		}
+	}
+	return nil
+}
+
 func (f *Feed) WithUrl(url string) *Feed {
 	f.Url = url
 	return f
@@ -77,6 +96,23 @@ type Item struct {
 	Url     string
 	PubDate time.Time
 	Unread  bool
+	// HTML content provided by the feed, or just a summary of it
+	Content string
+	// media files attached to the item, like the episodes of a podcast
+	Enclosures []*Enclosure
+	// starred items are kept even when dropped by the feed or when the cache is cleaned
+	Starred bool
+	// readable text extracted from the web page of a starred item
+	Article *html.Article
+}
+
+// Enclosure is a media file attached to an item
+type Enclosure struct {
+	Url string
+	// MIME type, e.g. audio/mpeg
+	Type string
+	// size in bytes, zero if unknown
+	Length int64
 }
 
 func NewItem(title, url string, pubDate time.Time) *Item {
@@ -93,5 +129,18 @@ func NewItemFrom(parsedItem *gofeed.Item) *Item {
 	if parsedItem.PublishedParsed != nil {
 		pubDate = *parsedItem.PublishedParsed
 	}
-	return NewItem(parsedItem.Title, parsedItem.Link, pubDate)
+	item := NewItem(parsedItem.Title, parsedItem.Link, pubDate)
+	item.Content = parsedItem.Content
+	if item.Content == "" {
+		item.Content = parsedItem.Description
+	}
+	for _, e := range parsedItem.Enclosures {
+		length, _ := strconv.ParseInt(strings.TrimSpace(e.Length), 10, 64)
+		item.Enclosures = append(item.Enclosures, &Enclosure{
+			Url:    e.URL,
+			Type:   e.Type,
+			Length: max(length, 0),
+		})
+	}
+	return item
 }
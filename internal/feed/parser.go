@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"log"
 	"log/slog"
+	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/giulianopz/newscanoe/internal/html"
 	"github.com/mmcdole/gofeed"
 	"github.com/mmcdole/gofeed/atom"
+	ext "github.com/mmcdole/gofeed/extensions"
 	"github.com/mmcdole/gofeed/json"
 	"github.com/mmcdole/gofeed/rss"
 )
@@ -33,17 +37,41 @@ func NewParser() *Parser {
 	}
 }
 
-func (p *Parser) Parse(url string) (*Feed, error) {
+/*
+Parse fetches the feed found at the given url with the given client and parses it,
+sending the given credentials, if any: the url can also be a local file or a command (see sources.go)
+*/
+func (p *Parser) Parse(url string, client *http.Client, auth *Auth) (*Feed, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
-	parsedFeed, err := p.ParseURLWithContext(url, ctx)
+
+	parsedFeed, maxAge, err := p.fetch(ctx, url, client, auth)
 	if err != nil {
 		slog.Error("cannot parse feed", "url", url, "err", err)
 		return nil, err
 	}
-	return NewFeedFrom(parsedFeed, url), nil
+
+	f := NewFeedFrom(parsedFeed, url)
+	f.TTL = max(f.TTL, maxAge)
+	f.LastRefresh = time.Now()
+	return f, nil
+}
+
+func (p *Parser) fetch(ctx context.Context, url string, client *http.Client, auth *Auth) (*gofeed.Feed, time.Duration, error) {
+
+	doc, maxAge, err := p.open(ctx, url, client, auth)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer doc.Close()
+
+	parsedFeed, err := p.Parser.Parse(doc)
+	return parsedFeed, maxAge, err
 }
 
+// key of the refresh interval advertised by a feed, stored among the custom fields of the translated feed
+const ttlKey = "ttl"
+
 type translator struct{}
 
 func (t *translator) Translate(feed interface{}) (*gofeed.Feed, error) {
@@ -56,6 +84,7 @@ func (t *translator) Translate(feed interface{}) (*gofeed.Feed, error) {
 			result.Title = t.rssFeedTitle(f)
 			result.Link = t.rssFeedLink(f)
 			result.Items = t.rssFeedItems(f)
+			result.Custom = ttlOf(t.rssFeedTTL(f))
 		}
 	case *atom.Feed:
 		{
@@ -63,6 +92,7 @@ func (t *translator) Translate(feed interface{}) (*gofeed.Feed, error) {
 			result.Title = f.Title
 			result.Link = t.atomFeedLink(f)
 			result.Items = t.atomFeedItems(f)
+			result.Custom = ttlOf(syndicationPeriod(f.Extensions))
 		}
 
 	case *json.Feed:
@@ -78,8 +108,23 @@ func (t *translator) Translate(feed interface{}) (*gofeed.Feed, error) {
 	return result, nil
 }
 
+func ttlOf(d time.Duration) map[string]string {
+	if d <= 0 {
+		return nil
+	}
+	return map[string]string{ttlKey: d.String()}
+}
+
 // RSS
 
+// rssFeedTTL returns how long the feed can be cached, as stated by its ttl element or by the syndication module
+func (t *translator) rssFeedTTL(rss *rss.Feed) time.Duration {
+	if minutes, err := strconv.Atoi(strings.TrimSpace(rss.TTL)); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return syndicationPeriod(rss.Extensions)
+}
+
 func (t *translator) rssFeedLink(rss *rss.Feed) (link string) {
 	if rss.Link != "" {
 		link = rss.Link
@@ -112,9 +157,27 @@ func (t *translator) rssFeedItem(rssItem *rss.Item) *gofeed.Item {
 	item.Title = t.rssItemTitle(rssItem)
 	item.Link = rssItem.Link
 	item.PublishedParsed = t.rssItemPublishedParsed(rssItem)
+	item.Content = rssItem.Content
+	item.Description = rssItem.Description
+	item.Enclosures = t.rssItemEnclosures(rssItem)
 	return item
 }
 
+func (t *translator) rssItemEnclosures(rssItem *rss.Item) []*gofeed.Enclosure {
+	enclosures := []*gofeed.Enclosure{}
+	for _, e := range rssItem.Enclosures {
+		if e.URL != "" {
+			enclosures = append(enclosures, &gofeed.Enclosure{URL: e.URL, Length: e.Length, Type: e.Type})
+		}
+	}
+	// gofeed fills Enclosure with the first element of Enclosures
+	if len(enclosures) == 0 && rssItem.Enclosure != nil && rssItem.Enclosure.URL != "" {
+		e := rssItem.Enclosure
+		enclosures = append(enclosures, &gofeed.Enclosure{URL: e.URL, Length: e.Length, Type: e.Type})
+	}
+	return enclosures
+}
+
 func (t *translator) rssItemTitle(rssItem *rss.Item) (title string) {
 	if rssItem.Title != "" {
 		title = rssItem.Title
@@ -173,9 +236,24 @@ func (t *translator) atomFeedItem(entry *atom.Entry) *gofeed.Item {
 	item.Title = entry.Title
 	item.Link = t.atomItemLink(entry)
 	item.PublishedParsed = t.atomItemPublishedParsed(entry)
+	if entry.Content != nil {
+		item.Content = entry.Content.Value
+	}
+	item.Description = entry.Summary
+	item.Enclosures = t.atomItemEnclosures(entry)
 	return item
 }
 
+func (t *translator) atomItemEnclosures(entry *atom.Entry) []*gofeed.Enclosure {
+	enclosures := []*gofeed.Enclosure{}
+	for _, l := range entry.Links {
+		if l.Rel == "enclosure" && l.Href != "" {
+			enclosures = append(enclosures, &gofeed.Enclosure{URL: l.Href, Length: l.Length, Type: l.Type})
+		}
+	}
+	return enclosures
+}
+
 func (t *translator) atomItemLink(entry *atom.Entry) string {
 	if l := firstLinkWithType("alternate", entry.Links); l != nil {
 		return l.Href
@@ -206,9 +284,30 @@ func (t *translator) jsonFeedItem(jsonItem *json.Item) *gofeed.Item {
 	item.Link = jsonItem.URL
 	item.Title = jsonItem.Title
 	item.PublishedParsed = t.jsonItemPublishedParsed(jsonItem)
+	// plain text is converted so that its line breaks are kept, like every content
+	item.Content = jsonItem.ContentHTML
+	if item.Content == "" && jsonItem.ContentText != "" {
+		item.Content = html.TextToHTML(jsonItem.ContentText)
+	}
+	if jsonItem.Summary != "" {
+		item.Description = html.TextToHTML(jsonItem.Summary)
+	}
+	item.Enclosures = t.jsonItemEnclosures(jsonItem)
 	return item
 }
 
+func (t *translator) jsonItemEnclosures(jsonItem *json.Item) []*gofeed.Enclosure {
+	enclosures := []*gofeed.Enclosure{}
+	if jsonItem.Attachments != nil {
+		for _, a := range *jsonItem.Attachments {
+			if a.URL != "" {
+				enclosures = append(enclosures, &gofeed.Enclosure{URL: a.URL, Length: strconv.FormatInt(a.SizeInBytes, 10), Type: a.MimeType})
+			}
+		}
+	}
+	return enclosures
+}
+
 func (t *translator) jsonItemPublishedParsed(jsonItem *json.Item) *time.Time {
 	if jsonItem.DatePublished != "" {
 		publishTime, err := parseDate(jsonItem.DatePublished)
@@ -223,6 +322,45 @@ func (t *translator) jsonItemPublishedParsed(jsonItem *json.Item) *time.Time {
 
 // common
 
+// periods of the syndication module
+var updatePeriods = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+	"yearly":  365 * 24 * time.Hour,
+}
+
+/*
+syndicationPeriod returns the interval between two updates of a feed stated by the syndication module,
+i.e. sy:updatePeriod divided by sy:updateFrequency
+see: https://web.resource.org/rss/1.0/modules/syndication/
+*/
+func syndicationPeriod(extensions ext.Extensions) time.Duration {
+
+	sy, found := extensions["sy"]
+	if !found {
+		return 0
+	}
+
+	value := func(name string) string {
+		if e := sy[name]; len(e) != 0 {
+			return strings.ToLower(strings.TrimSpace(e[0].Value))
+		}
+		return ""
+	}
+
+	period, found := updatePeriods[value("updatePeriod")]
+	if !found {
+		return 0
+	}
+	frequency, err := strconv.Atoi(value("updateFrequency"))
+	if err != nil || frequency < 1 {
+		frequency = 1
+	}
+	return period / time.Duration(frequency)
+}
+
 func firstEntry(entries []string) string {
 	if entries == nil {
 		return ""
@@ -0,0 +1,128 @@
+package feed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseEnclosures(t *testing.T) {
+
+	feeds := map[string]string{
+		"/rss": `<?xml version="1.0"?><rss version="2.0"><channel><title>Podcast</title>
+<item><title>Episode 1</title><link>https://example.com/1</link>
+<enclosure url="https://example.com/1.mp3" length="27100000" type="audio/mpeg"/></item></channel></rss>`,
+		"/atom": `<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"><title>Podcast</title>
+<entry><title>Episode 1</title><link rel="alternate" href="https://example.com/1"/>
+<link rel="enclosure" href="https://example.com/1.mp3" length="27100000" type="audio/mpeg"/></entry></feed>`,
+		"/json": `{"version":"https://jsonfeed.org/version/1.1","title":"Podcast","items":[{"id":"1","title":"Episode 1",
+"url":"https://example.com/1","attachments":[{"url":"https://example.com/1.mp3","mime_type":"audio/mpeg","size_in_bytes":27100000}]}]}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(feeds[r.URL.Path]))
+	}))
+	t.Cleanup(server.Close)
+
+	for path := range feeds {
+		t.Run(path, func(t *testing.T) {
+			f, err := NewParser().Parse(server.URL+path, server.Client(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(f.Items) != 1 || len(f.Items[0].Enclosures) != 1 {
+				t.Fatalf("want an item with an enclosure, got: %+v", f.Items)
+			}
+			want := Enclosure{Url: "https://example.com/1.mp3", Type: "audio/mpeg", Length: 27100000}
+			if got := *f.Items[0].Enclosures[0]; got != want {
+				t.Errorf("want %+v, got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		doc          string
+		cacheControl string
+		want         time.Duration
+	}{
+		{
+			name: "rss ttl",
+			doc:  `<rss version="2.0"><channel><title>T</title><ttl>90</ttl></channel></rss>`,
+			want: 90 * time.Minute,
+		},
+		{
+			name: "syndication module",
+			doc: `<rss version="2.0" xmlns:sy="http://purl.org/rss/1.0/modules/syndication/"><channel><title>T</title>
+<sy:updatePeriod>daily</sy:updatePeriod><sy:updateFrequency>4</sy:updateFrequency></channel></rss>`,
+			want: 6 * time.Hour,
+		},
+		{
+			name:         "cache control longer than ttl",
+			doc:          `<rss version="2.0"><channel><title>T</title><ttl>10</ttl></channel></rss>`,
+			cacheControl: "public, max-age=3600",
+			want:         time.Hour,
+		},
+		{
+			name: "none",
+			doc:  `<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title></feed>`,
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.cacheControl != "" {
+					w.Header().Set("Cache-Control", tt.cacheControl)
+				}
+				w.Write([]byte(tt.doc))
+			}))
+			defer server.Close()
+
+			f, err := NewParser().Parse(server.URL, server.Client(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.TTL != tt.want {
+				t.Errorf("want ttl %v, got %v", tt.want, f.TTL)
+			}
+			if time.Since(f.LastRefresh) > time.Minute {
+				t.Errorf("last refresh not set: %v", f.LastRefresh)
+			}
+		})
+	}
+}
+
+func TestParseJSONFeedText(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version":"https://jsonfeed.org/version/1.1","title":"Notes","items":[
+{"id":"1","url":"https://example.com/1","content_text":"First line\nSecond <line>"},
+{"id":"2","url":"https://example.com/2","content_html":"<p>Hello</p>","content_text":"Hello"},
+{"id":"3","url":"https://example.com/3","summary":"Just a summary"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	f, err := NewParser().Parse(server.URL, server.Client(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"https://example.com/1": "<p>First line<br>Second &lt;line&gt;</p>",
+		"https://example.com/2": "<p>Hello</p>",
+		"https://example.com/3": "<p>Just a summary</p>",
+	}
+	if len(f.Items) != len(want) {
+		t.Fatalf("got %d items, want %d", len(f.Items), len(want))
+	}
+	for _, i := range f.Items {
+		if i.Content != want[i.Url] {
+			t.Errorf("got content %q for %s, want %q", i.Content, i.Url, want[i.Url])
+		}
+	}
+}
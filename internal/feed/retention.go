@@ -0,0 +1,42 @@
+package feed
+
+import (
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+// Retention limits the items kept in the cache for a feed
+type Retention struct {
+	// max number of items, zero meaning no limit
+	MaxItems int
+	// max age of items by publication date, zero meaning no limit
+	MaxAge time.Duration
+	// unread and starred items are kept anyway, if so set
+	KeepUnread, KeepStarred bool
+}
+
+// Prune drops the oldest items exceeding the retention limits, returning how many were dropped
+func (f *Feed) Prune(r Retention, now time.Time) int {
+
+	if r.MaxItems <= 0 && r.MaxAge <= 0 {
+		return 0
+	}
+
+	kept := make([]*Item, 0, len(f.Items))
+	for n, i := range f.GetItemsOrderedByDate() {
+
+		exempt := (r.KeepStarred && i.Starred) || (r.KeepUnread && i.Unread)
+		tooMany := r.MaxItems > 0 && n >= r.MaxItems
+		// items without a publication date are never too old
+		tooOld := r.MaxAge > 0 && i.PubDate != util.NoPubDate && now.Sub(i.PubDate) > r.MaxAge
+
+		if exempt || !(tooMany || tooOld) {
+			kept = append(kept, i)
+		}
+	}
+
+	dropped := len(f.Items) - len(kept)
+	f.Items = kept
+	return dropped
+}
@@ -0,0 +1,68 @@
+package feed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+func TestPrune(t *testing.T) {
+
+	now := time.Date(2024, 8, 13, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	newFeed := func() *Feed {
+		f := NewFeed("Example")
+		for _, i := range []struct {
+			title           string
+			age             time.Duration
+			unread, starred bool
+		}{
+			{"today", 0, true, false},
+			{"yesterday", day, false, false},
+			{"last week", 7 * day, false, true},
+			{"last month", 30 * day, true, false},
+			{"last year", 365 * day, false, false},
+		} {
+			item := NewItem(i.title, "https://example.com/"+i.title, now.Add(-i.age))
+			item.Unread, item.Starred = i.unread, i.starred
+			f.Items = append(f.Items, item)
+		}
+		undated := NewItem("undated", "https://example.com/undated", util.NoPubDate)
+		undated.Unread = false
+		f.Items = append(f.Items, undated)
+		return f
+	}
+
+	tests := []struct {
+		name      string
+		retention Retention
+		want      []string
+	}{
+		{"no limits", Retention{}, []string{"today", "yesterday", "last week", "last month", "last year", "undated"}},
+		{"max items", Retention{MaxItems: 2}, []string{"today", "yesterday"}},
+		{"max age", Retention{MaxAge: 10 * day}, []string{"today", "yesterday", "last week", "undated"}},
+		{"keep starred", Retention{MaxItems: 2, KeepStarred: true}, []string{"today", "yesterday", "last week"}},
+		{"keep unread and starred", Retention{MaxAge: 2 * day, KeepUnread: true, KeepStarred: true}, []string{"today", "yesterday", "last week", "last month", "undated"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFeed()
+			dropped := f.Prune(tt.retention, now)
+
+			got := make([]string, 0)
+			for _, i := range f.Items {
+				got = append(got, i.Title)
+			}
+			if len(got) != len(tt.want) || dropped != 6-len(tt.want) {
+				t.Fatalf("want %q, got %q (dropped %d)", tt.want, got, dropped)
+			}
+			for n := range got {
+				if got[n] != tt.want[n] {
+					t.Errorf("want %q, got %q", tt.want, got)
+				}
+			}
+		})
+	}
+}
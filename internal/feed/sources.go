@@ -0,0 +1,156 @@
+package feed
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// prefixes of the feed sources which are not fetched over HTTP
+const (
+	// a local file, e.g. file:///home/me/feed.xml
+	FILE_SOURCE = "file://"
+	// a command printing a feed, e.g. exec:~/bin/feed.sh
+	EXEC_SOURCE = "exec:"
+	// a command turning a web page into a feed, e.g. filter:~/bin/to-rss.sh:https://example.com/news
+	FILTER_SOURCE = "filter:"
+)
+
+// IsRemote reports whether the feed with the given url is fetched over HTTP
+func IsRemote(url string) bool {
+	for _, prefix := range []string{FILE_SOURCE, EXEC_SOURCE, FILTER_SOURCE} {
+		if strings.HasPrefix(url, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// CmdError is the failure of the command of an exec: or filter: source, together with what it printed on stderr
+type CmdError struct {
+	Cmd    string
+	Err    error
+	Stderr string
+}
+
+func (e *CmdError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("command %q failed: %v", e.Cmd, e.Err)
+	}
+	return fmt.Sprintf("command %q failed: %v: %s", e.Cmd, e.Err, e.Stderr)
+}
+
+func (e *CmdError) Unwrap() error {
+	return e.Err
+}
+
+/*
+open returns the document of the feed with the given url, according to its source,
+together with how long it can be cached as stated by the server (Cache-Control: max-age), if fetched over HTTP
+*/
+func (p *Parser) open(ctx context.Context, url string, client *http.Client, auth *Auth) (io.ReadCloser, time.Duration, error) {
+	switch {
+	case strings.HasPrefix(url, FILE_SOURCE):
+		u, err := neturl.Parse(url)
+		if err != nil {
+			return nil, 0, err
+		}
+		f, err := os.Open(u.Path)
+		return f, 0, err
+
+	case strings.HasPrefix(url, EXEC_SOURCE):
+		out, err := run(ctx, strings.TrimPrefix(url, EXEC_SOURCE), nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		return io.NopCloser(bytes.NewReader(out)), 0, nil
+
+	case strings.HasPrefix(url, FILTER_SOURCE):
+		cmd, target, found := strings.Cut(strings.TrimPrefix(url, FILTER_SOURCE), ":")
+		if !found || cmd == "" || target == "" {
+			return nil, 0, fmt.Errorf("filter source must be in the form filter:command:url: %q", url)
+		}
+
+		body, maxAge, err := p.get(ctx, target, client, auth)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer body.Close()
+
+		out, err := run(ctx, cmd, body)
+		if err != nil {
+			return nil, 0, err
+		}
+		return io.NopCloser(bytes.NewReader(out)), maxAge, nil
+
+	default:
+		return p.get(ctx, url, client, auth)
+	}
+}
+
+// get returns the body of the response to a GET request for the given url and the max-age of its Cache-Control header
+func (p *Parser) get(ctx context.Context, url string, client *http.Client, auth *Auth) (io.ReadCloser, time.Duration, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	auth.apply(req)
+
+	resp, err := auth.client(client).Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, 0, gofeed.HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
+	}
+	return resp.Body, maxAge(resp.Header), nil
+}
+
+// maxAge returns the max-age directive of the Cache-Control header, if any
+func maxAge(h http.Header) time.Duration {
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(directive), "=")
+		if found && strings.EqualFold(key, "max-age") {
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && seconds > 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return 0
+}
+
+// run runs a shell command line fed with the given input, returning what it printed on stdout
+func run(ctx context.Context, cmdLine string, stdin io.Reader) ([]byte, error) {
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", cmdLine)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, &CmdError{
+			Cmd:    cmdLine,
+			Err:    err,
+			Stderr: strings.TrimSpace(stderr.String()),
+		}
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,96 @@
+package feed
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSources(t *testing.T) {
+
+	dir := t.TempDir()
+	feedFile := filepath.Join(dir, "feed.xml")
+	if err := os.WriteFile(feedFile, []byte(rssFeed), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// a web page listing news, not a feed
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello|https://example.com/hello\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	filter := filepath.Join(dir, "to-rss.sh")
+	script := `#!/bin/sh
+echo '<?xml version="1.0"?><rss version="2.0"><channel><title>Private</title>'
+while IFS='|' read -r title link; do
+	echo "<item><title>$title</title><link>$link</link></item>"
+done
+echo '</channel></rss>'
+`
+	if err := os.WriteFile(filter, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"file", "file://" + feedFile},
+		{"exec", "exec:cat " + feedFile},
+		{"filter", "filter:" + filter + ":" + server.URL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewParser().Parse(tt.url, server.Client(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Name != "Private" || f.Url != tt.url {
+				t.Errorf("unexpected feed: %q from %q", f.Name, f.Url)
+			}
+			if len(f.Items) != 1 || f.Items[0].Url != "https://example.com/hello" {
+				t.Errorf("unexpected items: %+v", f.Items)
+			}
+		})
+	}
+
+	t.Run("captures stderr", func(t *testing.T) {
+		_, err := NewParser().Parse("exec:echo 'no such feed' >&2; exit 3", server.Client(), nil)
+		var cmdErr *CmdError
+		if !errors.As(err, &cmdErr) {
+			t.Fatalf("want a command error, got: %v", err)
+		}
+		if cmdErr.Stderr != "no such feed" || !strings.Contains(err.Error(), "no such feed") {
+			t.Errorf("unexpected stderr: %q", cmdErr.Stderr)
+		}
+	})
+}
+
+func TestCmdError(t *testing.T) {
+
+	exitErr := errors.New("exit status 3")
+
+	tests := []struct {
+		name string
+		err  *CmdError
+		want string
+	}{
+		{"without stderr", &CmdError{Cmd: "feed.sh", Err: exitErr}, `command "feed.sh" failed: exit status 3`},
+		{"with stderr", &CmdError{Cmd: "feed.sh --all", Err: exitErr, Stderr: "no such feed"}, `command "feed.sh --all" failed: exit status 3: no such feed`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if !errors.Is(tt.err, exitErr) {
+				t.Errorf("want %v wrapped", exitErr)
+			}
+		})
+	}
+}
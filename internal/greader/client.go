@@ -0,0 +1,396 @@
+package greader
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// streams and tags of the Google Reader API
+const (
+	ReadingList = "user/-/state/com.google/reading-list"
+	Read        = "user/-/state/com.google/read"
+	Starred     = "user/-/state/com.google/starred"
+
+	// prefix of the long form of item ids, followed by the id as 16 hexadecimal digits
+	longIdPrefix = "tag:google.com,2005:reader/item/"
+	// prefix of the ids of the streams of feeds
+	feedStreamPrefix = "feed/"
+)
+
+// max number of items or ids asked for in a single request
+const pageSize = 1000
+
+var ErrUnauthorized = errors.New("wrong credentials or expired session")
+
+/*
+Client talks to a server implementing the Google Reader API, as FreshRSS and Miniflux do,
+logging in with a user and a password at the first request.
+see: https://github.com/FreshRSS/FreshRSS/blob/edge/p/api/greader.php
+*/
+type Client struct {
+	baseUrl        string
+	user, password string
+	http           *http.Client
+
+	// token authorizing the requests, returned by the login
+	auth string
+	// token required to change the state of items, valid for a while
+	editToken string
+}
+
+// Subscription is a feed followed on the server
+type Subscription struct {
+	Id         string     `json:"id"`
+	Title      string     `json:"title"`
+	Url        string     `json:"url"`
+	HtmlUrl    string     `json:"htmlUrl"`
+	Categories []Category `json:"categories"`
+}
+
+type Category struct {
+	Id    string `json:"id"`
+	Label string `json:"label"`
+}
+
+// FeedUrl returns the url of the feed followed, which some servers only give as the id of its stream
+func (s *Subscription) FeedUrl() string {
+	if s.Url != "" {
+		return s.Url
+	}
+	return strings.TrimPrefix(s.Id, feedStreamPrefix)
+}
+
+// Item is an item of a feed followed on the server
+type Item struct {
+	Id            string   `json:"id"`
+	CrawlTimeMsec string   `json:"crawlTimeMsec,omitempty"`
+	TimestampUsec string   `json:"timestampUsec,omitempty"`
+	Title         string   `json:"title"`
+	Published     int64    `json:"published"`
+	Updated       int64    `json:"updated,omitempty"`
+	Canonical     []Link   `json:"canonical"`
+	Alternate     []Link   `json:"alternate"`
+	Summary       Content  `json:"summary"`
+	Content       Content  `json:"content"`
+	Categories    []string `json:"categories"`
+	Enclosure     []Link   `json:"enclosure,omitempty"`
+	Origin        Origin   `json:"origin"`
+}
+
+// Origin is the feed of an item
+type Origin struct {
+	StreamId string `json:"streamId"`
+	Title    string `json:"title"`
+	HtmlUrl  string `json:"htmlUrl,omitempty"`
+}
+
+type Link struct {
+	Href   string `json:"href"`
+	Type   string `json:"type,omitempty"`
+	Length string `json:"length,omitempty"`
+}
+
+type Content struct {
+	Content string `json:"content"`
+}
+
+// Url returns the url of the web page of an item
+func (i *Item) Url() string {
+	for _, links := range [][]Link{i.Canonical, i.Alternate} {
+		if len(links) != 0 {
+			return links[0].Href
+		}
+	}
+	return ""
+}
+
+// Text returns the HTML content of an item, or else its summary
+func (i *Item) Text() string {
+	if i.Content.Content != "" {
+		return i.Content.Content
+	}
+	return i.Summary.Content
+}
+
+// Has reports whether an item is tagged with the given state, e.g. Read
+func (i *Item) Has(tag string) bool {
+	for _, c := range i.Categories {
+		if currentUser(c) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// currentUser returns a tag of the given user as a tag of the current one, i.e. "-", as some servers give the tags of items
+func currentUser(tag string) string {
+	parts := strings.SplitN(tag, "/", 3)
+	if len(parts) == 3 && parts[0] == "user" {
+		parts[1] = "-"
+		return strings.Join(parts, "/")
+	}
+	return tag
+}
+
+func NewClient(baseUrl, user, password string, c *http.Client) *Client {
+	return &Client{
+		baseUrl:  strings.TrimSuffix(baseUrl, "/"),
+		user:     user,
+		password: password,
+		http:     c,
+	}
+}
+
+// login asks for the token authorizing the next requests
+func (c *Client) login(ctx context.Context) error {
+
+	form := neturl.Values{"Email": {c.user}, "Passwd": {c.password}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseUrl+"/accounts/ClientLogin", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return ErrUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cannot log in to %s: %s", c.baseUrl, resp.Status)
+	}
+
+	s := bufio.NewScanner(resp.Body)
+	for s.Scan() {
+		if auth, found := strings.CutPrefix(s.Text(), "Auth="); found {
+			c.auth = strings.TrimSpace(auth)
+			return nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("no token returned by the login to %s", c.baseUrl)
+}
+
+/*
+do sends a request to the API, logging in first if not yet done or if the session expired,
+and decodes the JSON response into v, if not nil
+*/
+func (c *Client) do(ctx context.Context, method, path string, params neturl.Values, v any) error {
+
+	for attempt := 0; ; attempt++ {
+
+		if c.auth == "" {
+			if err := c.login(ctx); err != nil {
+				return err
+			}
+		}
+
+		var body io.Reader
+		url := c.baseUrl + path
+		if method == http.MethodGet {
+			url += "?" + params.Encode()
+		} else {
+			body = strings.NewReader(params.Encode())
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, url, body)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "GoogleLogin auth="+c.auth)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			resp.Body.Close()
+			c.auth, c.editToken = "", ""
+			continue
+		}
+
+		err = decode(resp, v)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", method, path, err)
+		}
+		return nil
+	}
+}
+
+func decode(resp *http.Response, v any) error {
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case resp.StatusCode != http.StatusOK:
+		return errors.New(resp.Status)
+	case v == nil:
+		return nil
+	}
+	if s, ok := v.(*string); ok {
+		data, err := io.ReadAll(resp.Body)
+		*s = strings.TrimSpace(string(data))
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Subscriptions returns the feeds followed on the server
+func (c *Client) Subscriptions(ctx context.Context) ([]*Subscription, error) {
+	var list struct {
+		Subscriptions []*Subscription `json:"subscriptions"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/reader/api/0/subscription/list", neturl.Values{"output": {"json"}}, &list); err != nil {
+		return nil, err
+	}
+	return list.Subscriptions, nil
+}
+
+// Items returns the items of all the feeds followed, published or updated since the given time if not zero, up to limit items
+func (c *Client) Items(ctx context.Context, since time.Time, limit int) ([]*Item, error) {
+
+	items := make([]*Item, 0)
+	params := neturl.Values{"output": {"json"}, "n": {strconv.Itoa(min(pageSize, limit))}}
+	if !since.IsZero() {
+		params.Set("ot", strconv.FormatInt(since.Unix(), 10))
+	}
+
+	for len(items) < limit {
+
+		var page struct {
+			Items        []*Item `json:"items"`
+			Continuation string  `json:"continuation"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/reader/api/0/stream/contents/"+ReadingList, params, &page); err != nil {
+			return nil, err
+		}
+
+		items = append(items, page.Items...)
+		if page.Continuation == "" || len(page.Items) == 0 {
+			break
+		}
+		params.Set("c", page.Continuation)
+	}
+
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+// ItemIds returns the ids of the items of a stream, excluding the ones tagged as exclude, if not empty
+func (c *Client) ItemIds(ctx context.Context, stream, exclude string) ([]string, error) {
+
+	ids := make([]string, 0)
+	params := neturl.Values{"output": {"json"}, "s": {stream}, "n": {strconv.Itoa(pageSize)}}
+	if exclude != "" {
+		params.Set("xt", exclude)
+	}
+
+	for {
+		var page struct {
+			ItemRefs []struct {
+				Id string `json:"id"`
+			} `json:"itemRefs"`
+			Continuation string `json:"continuation"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/reader/api/0/stream/items/ids", params, &page); err != nil {
+			return nil, err
+		}
+
+		for _, ref := range page.ItemRefs {
+			id, err := NormalizeId(ref.Id)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		if page.Continuation == "" || len(page.ItemRefs) == 0 {
+			return ids, nil
+		}
+		params.Set("c", page.Continuation)
+	}
+}
+
+// Tag adds (or removes, if add is false) a tag to the items with the given ids, e.g. Read or Starred
+func (c *Client) Tag(ctx context.Context, ids []string, tag string, add bool) error {
+
+	var retried bool
+	for len(ids) > 0 {
+
+		if c.editToken == "" {
+			if err := c.do(ctx, http.MethodGet, "/reader/api/0/token", neturl.Values{}, &c.editToken); err != nil {
+				return err
+			}
+		}
+
+		batch := ids[:min(len(ids), pageSize)]
+		params := neturl.Values{"T": {c.editToken}}
+		for _, id := range batch {
+			params.Add("i", longId(id))
+		}
+		if add {
+			params.Set("a", tag)
+		} else {
+			params.Set("r", tag)
+		}
+
+		var ok string
+		err := c.do(ctx, http.MethodPost, "/reader/api/0/edit-tag", params, &ok)
+		if errors.Is(err, ErrUnauthorized) && !retried {
+			// the token expired
+			c.editToken, retried = "", true
+			continue
+		} else if err != nil {
+			return err
+		}
+		ids, retried = ids[len(batch):], false
+	}
+	return nil
+}
+
+/*
+NormalizeId returns the short form of an item id, i.e. a decimal number, given either its short form
+or its long form: tag:google.com,2005:reader/item/ followed by 16 hexadecimal digits
+*/
+func NormalizeId(id string) (string, error) {
+	if hex, found := strings.CutPrefix(id, longIdPrefix); found {
+		n, err := strconv.ParseUint(hex, 16, 64)
+		if err != nil {
+			return "", fmt.Errorf("malformed item id: %q", id)
+		}
+		return strconv.FormatInt(int64(n), 10), nil
+	}
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return "", fmt.Errorf("malformed item id: %q", id)
+	}
+	return id, nil
+}
+
+// longId returns the long form of an item id given in its short form
+func longId(id string) string {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return id
+	}
+	return fmt.Sprintf("%s%016x", longIdPrefix, uint64(n))
+}
@@ -0,0 +1,459 @@
+package greader
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/cache"
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+const (
+	// tag of the items marked as unread by the user, as opposed to the ones not yet read
+	KeptUnread = "user/-/state/com.google/kept-unread"
+
+	// prefix of the path of the API as served by FreshRSS, which some clients append to the url of the server
+	freshRSSPrefix = "/api/greader.php"
+	apiPrefix      = "/reader/api/0/"
+
+	// number of items returned by a single request, unless asked otherwise
+	defaultPageSize = 20
+	// the cache is read again to serve the changes of other instances, but not more often than this
+	refreshInterval = 2 * time.Second
+)
+
+/*
+Server serves the cache over the Google Reader API, as implemented by FreshRSS, so that clients like Reeder or NetNewsWire
+can read the cached items and mark them as read or starred: the changes are saved to the cache as the TUI does,
+and the ones made meanwhile by the TUI are read again before serving a request.
+Feeds are streams identified by their url, e.g. feed/https://lwn.net/headlines/rss, and items by a hash of their feed url and title.
+see: https://feedhq.readthedocs.io/en/latest/api/reference.html
+*/
+type Server struct {
+	mu             sync.Mutex
+	cache          *cache.Cache
+	user, password string
+	lastRefresh    time.Time
+}
+
+// entry is a cached item together with its feed and its id
+type entry struct {
+	feed *feed.Feed
+	item *feed.Item
+	id   string
+}
+
+func NewServer(c *cache.Cache, user, password string) *Server {
+	return &Server{
+		cache:    c,
+		user:     user,
+		password: password,
+	}
+}
+
+// ItemId returns the id of an item, in its short form, as served
+func ItemId(feedUrl, title string) string {
+	h := fnv.New64a()
+	h.Write([]byte(feedUrl))
+	h.Write([]byte{0})
+	h.Write([]byte(title))
+	// positive, since some clients parse ids as signed integers
+	return strconv.FormatInt(int64(h.Sum64()&(1<<63-1)), 10)
+}
+
+// token returns a token derived from the credentials, so that it is still valid when the server is restarted
+func (s *Server) token(purpose string) string {
+	mac := hmac.New(sha256.New, []byte(s.password))
+	mac.Write([]byte(purpose + ":" + s.user))
+	return s.user + "/" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	// paths are not cleaned, since the ids of streams are urls
+	path := strings.TrimPrefix(r.URL.Path, freshRSSPrefix)
+
+	if path == "/accounts/ClientLogin" {
+		s.login(w, r)
+		return
+	}
+
+	method, found := strings.CutPrefix(path, apiPrefix)
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	auth, _ := strings.CutPrefix(r.Header.Get("Authorization"), "GoogleLogin auth=")
+	if subtle.ConstantTimeCompare([]byte(auth), []byte(s.token("auth"))) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// changes must be authorized by the edit token too
+	editing := method == "edit-tag" || method == "mark-all-as-read"
+	if editing && (r.Method != http.MethodPost || r.PostForm.Get("T") != s.token("edit")) {
+		w.Header().Set("X-Reader-Google-Bad-Token", "true")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.lastRefresh) >= refreshInterval {
+		if err := s.cache.Refresh(); err != nil {
+			log.Default().Println(err)
+		}
+		s.lastRefresh = time.Now()
+	}
+
+	switch {
+	case method == "token":
+		fmt.Fprint(w, s.token("edit"))
+	case method == "user-info":
+		writeJSON(w, map[string]string{"userId": "1", "userName": s.user, "userProfileId": "1", "userEmail": ""})
+	case method == "subscription/list":
+		s.subscriptions(w)
+	case method == "tag/list":
+		writeJSON(w, map[string]any{"tags": []map[string]string{{"id": Starred}}})
+	case method == "unread-count":
+		s.unreadCount(w)
+	case method == "stream/items/ids":
+		s.itemIds(w, r)
+	case method == "stream/items/contents":
+		s.itemContents(w, r)
+	case strings.HasPrefix(method, "stream/contents"):
+		s.streamContents(w, r, strings.TrimPrefix(strings.TrimPrefix(method, "stream/contents"), "/"))
+	case method == "edit-tag":
+		s.editTag(w, r)
+	case method == "mark-all-as-read":
+		s.markAllAsRead(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+
+	user, password := r.FormValue("Email"), r.FormValue("Passwd")
+	if user != s.user || subtle.ConstantTimeCompare([]byte(password), []byte(s.password)) != 1 {
+		http.Error(w, "Error=BadAuthentication", http.StatusUnauthorized)
+		return
+	}
+
+	auth := s.token("auth")
+	fmt.Fprintf(w, "SID=%s\nLSID=null\nAuth=%s\n", auth, auth)
+}
+
+func (s *Server) subscriptions(w http.ResponseWriter) {
+	subs := make([]*Subscription, 0)
+	for _, f := range s.cache.GetFeeds() {
+		subs = append(subs, &Subscription{Id: feedStreamPrefix + f.Url, Title: f.Name, Url: f.Url, Categories: []Category{}})
+	}
+	writeJSON(w, map[string]any{"subscriptions": subs})
+}
+
+func (s *Server) unreadCount(w http.ResponseWriter) {
+
+	type count struct {
+		Id                      string `json:"id"`
+		Count                   int    `json:"count"`
+		NewestItemTimestampUsec string `json:"newestItemTimestampUsec"`
+	}
+
+	counts := make([]*count, 0)
+	total := &count{Id: ReadingList}
+	var newest int64
+	for _, f := range s.cache.GetFeeds() {
+		c := &count{Id: feedStreamPrefix + f.Url}
+		var feedNewest int64
+		for _, i := range f.Items {
+			if i.Unread {
+				c.Count++
+			}
+			feedNewest = max(feedNewest, published(f, i))
+		}
+		c.NewestItemTimestampUsec = usec(feedNewest)
+		counts = append(counts, c)
+		total.Count += c.Count
+		newest = max(newest, feedNewest)
+	}
+	total.NewestItemTimestampUsec = usec(newest)
+	counts = append(counts, total)
+
+	writeJSON(w, map[string]any{"max": total.Count, "unreadcounts": counts})
+}
+
+func (s *Server) itemIds(w http.ResponseWriter, r *http.Request) {
+
+	entries, continuation := s.page(r, r.Form.Get("s"))
+
+	refs := make([]map[string]string, 0, len(entries))
+	for _, e := range entries {
+		refs = append(refs, map[string]string{"id": e.id, "timestampUsec": usec(published(e.feed, e.item))})
+	}
+	resp := map[string]any{"itemRefs": refs}
+	if continuation != "" {
+		resp["continuation"] = continuation
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) streamContents(w http.ResponseWriter, r *http.Request, stream string) {
+
+	if stream == "" {
+		stream = r.Form.Get("s")
+	}
+	entries, continuation := s.page(r, stream)
+
+	resp := map[string]any{"id": stream, "updated": time.Now().Unix(), "items": toItems(entries)}
+	if continuation != "" {
+		resp["continuation"] = continuation
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) itemContents(w http.ResponseWriter, r *http.Request) {
+
+	byId := s.entries()
+	entries := make([]*entry, 0)
+	for _, id := range r.Form["i"] {
+		if short, err := NormalizeId(id); err == nil && byId[short] != nil {
+			entries = append(entries, byId[short])
+		}
+	}
+	writeJSON(w, map[string]any{"items": toItems(entries)})
+}
+
+func (s *Server) editTag(w http.ResponseWriter, r *http.Request) {
+
+	byId := s.entries()
+	for _, id := range r.PostForm["i"] {
+		short, err := NormalizeId(id)
+		if err != nil || byId[short] == nil {
+			continue
+		}
+		e := byId[short]
+		for _, tag := range r.PostForm["a"] {
+			tag = currentUser(tag)
+			setState(e, tag, true)
+		}
+		for _, tag := range r.PostForm["r"] {
+			tag = currentUser(tag)
+			setState(e, tag, false)
+		}
+	}
+
+	s.save()
+	fmt.Fprint(w, "OK")
+}
+
+func setState(e *entry, tag string, add bool) {
+	switch tag {
+	case Read:
+		e.item.Unread = !add
+	case KeptUnread:
+		e.item.Unread = add
+	case Starred:
+		e.item.Starred = add
+	}
+}
+
+// markAllAsRead marks as read the items of a stream, published before the given time, if any
+func (s *Server) markAllAsRead(w http.ResponseWriter, r *http.Request) {
+
+	var before int64
+	if ts, err := strconv.ParseInt(r.PostForm.Get("ts"), 10, 64); err == nil {
+		before = ts / int64(time.Second/time.Microsecond)
+	}
+
+	for _, e := range s.stream(r.PostForm.Get("s")) {
+		if before == 0 || published(e.feed, e.item) <= before {
+			e.item.Unread = false
+		}
+	}
+
+	s.save()
+	fmt.Fprint(w, "OK")
+}
+
+func (s *Server) save() {
+	for _, f := range s.cache.GetFeeds() {
+		f.CountUnread()
+	}
+	s.cache.Save()
+}
+
+// entries returns every cached item by id
+func (s *Server) entries() map[string]*entry {
+	byId := make(map[string]*entry)
+	for _, f := range s.cache.GetFeeds() {
+		for _, i := range f.Items {
+			e := &entry{feed: f, item: i, id: ItemId(f.Url, i.Title)}
+			byId[e.id] = e
+		}
+	}
+	return byId
+}
+
+// stream returns the items of a stream, i.e. the ones of all feeds, of a feed or the starred ones, from the most recent
+func (s *Server) stream(stream string) []*entry {
+
+	stream = currentUser(stream)
+	entries := make([]*entry, 0)
+	for _, f := range s.cache.GetFeeds() {
+
+		feedStream := feedStreamPrefix + f.Url
+		for _, i := range f.Items {
+			switch {
+			case stream == ReadingList, stream == feedStream,
+				stream == Starred && i.Starred,
+				stream == Read && !i.Unread:
+				entries = append(entries, &entry{feed: f, item: i, id: ItemId(f.Url, i.Title)})
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return published(entries[i].feed, entries[i].item) > published(entries[j].feed, entries[j].item)
+	})
+	return entries
+}
+
+/*
+page returns the items of a stream filtered and paginated by the parameters of a request, together with the continuation
+of the next page, if any: n (the size of the page), c (the continuation), xt and it (tags to be excluded and included),
+ot and nt (the times the items are newer and older than) and r=o (from the oldest)
+*/
+func (s *Server) page(r *http.Request, stream string) ([]*entry, string) {
+
+	form := r.Form
+	ot, _ := strconv.ParseInt(form.Get("ot"), 10, 64)
+	nt, _ := strconv.ParseInt(form.Get("nt"), 10, 64)
+
+	entries := make([]*entry, 0)
+	for _, e := range s.stream(stream) {
+		p := published(e.feed, e.item)
+		switch {
+		case ot != 0 && p < ot, nt != 0 && p > nt:
+			continue
+		case hasAny(e, form["xt"]), len(form["it"]) != 0 && !hasAny(e, form["it"]):
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	if form.Get("r") == "o" {
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+	}
+
+	size, err := strconv.Atoi(form.Get("n"))
+	if err != nil || size <= 0 {
+		size = defaultPageSize
+	}
+	start, _ := strconv.Atoi(form.Get("c"))
+	start = min(max(start, 0), len(entries))
+	end := min(start+size, len(entries))
+
+	var continuation string
+	if end < len(entries) {
+		continuation = strconv.Itoa(end)
+	}
+	return entries[start:end], continuation
+}
+
+// hasAny reports whether an item has any of the given tags
+func hasAny(e *entry, tags []string) bool {
+	for _, tag := range tags {
+		switch currentUser(tag) {
+		case Read:
+			if !e.item.Unread {
+				return true
+			}
+		case Starred:
+			if e.item.Starred {
+				return true
+			}
+		case ReadingList:
+			return true
+		}
+	}
+	return false
+}
+
+func toItems(entries []*entry) []*Item {
+
+	items := make([]*Item, 0, len(entries))
+	for _, e := range entries {
+
+		p := published(e.feed, e.item)
+		categories := []string{ReadingList}
+		if !e.item.Unread {
+			categories = append(categories, Read)
+		}
+		if e.item.Starred {
+			categories = append(categories, Starred)
+		}
+
+		item := &Item{
+			Id:            longId(e.id),
+			CrawlTimeMsec: strconv.FormatInt(p*1000, 10),
+			TimestampUsec: usec(p),
+			Title:         e.item.Title,
+			Published:     p,
+			Updated:       p,
+			Canonical:     []Link{{Href: e.item.Url}},
+			Alternate:     []Link{{Href: e.item.Url, Type: "text/html"}},
+			Summary:       Content{Content: e.item.Content},
+			Categories:    categories,
+			Origin:        Origin{StreamId: feedStreamPrefix + e.feed.Url, Title: e.feed.Name},
+		}
+		for _, enc := range e.item.Enclosures {
+			item.Enclosure = append(item.Enclosure, Link{Href: enc.Url, Type: enc.Type, Length: strconv.FormatInt(enc.Length, 10)})
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// published returns the publication time of an item in seconds, or the last refresh of its feed if unknown
+func published(f *feed.Feed, i *feed.Item) int64 {
+	if i.PubDate == util.NoPubDate || i.PubDate.IsZero() {
+		if f.LastRefresh.IsZero() {
+			return 0
+		}
+		return f.LastRefresh.Unix()
+	}
+	return i.PubDate.Unix()
+}
+
+func usec(secs int64) string {
+	return strconv.FormatInt(secs*int64(time.Second/time.Microsecond), 10)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Default().Println(err)
+	}
+}
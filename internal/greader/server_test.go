@@ -0,0 +1,115 @@
+package greader
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/cache"
+	"github.com/giulianopz/newscanoe/internal/feed"
+)
+
+func TestServer(t *testing.T) {
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	day := func(d int) time.Time {
+		return time.Date(2024, 8, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	c := cache.NewCache()
+	c.AddFeed(&feed.Feed{Name: "LWN", Url: "https://lwn.net/headlines/rss", Items: []*feed.Item{
+		feed.NewItem("Kernel release", "https://lwn.net/Articles/1/", day(3)),
+		feed.NewItem("Security updates", "https://lwn.net/Articles/2/", day(2)),
+	}}, "https://lwn.net/headlines/rss")
+	c.AddFeed(&feed.Feed{Name: "Example", Url: "https://example.com/rss?format=rss", Items: []*feed.Item{
+		feed.NewItem("Hello", "https://example.com/1", day(1)),
+	}}, "https://example.com/rss?format=rss")
+	if err := c.Encode(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Flush()
+
+	server := NewServer(c, "me", "secret")
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	ctx := context.Background()
+
+	if _, err := NewClient(ts.URL, "me", "wrong", ts.Client()).Subscriptions(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("got error %v, want %v", err, ErrUnauthorized)
+	}
+
+	// as clients of FreshRSS do
+	client := NewClient(ts.URL+freshRSSPrefix, "me", "secret", ts.Client())
+
+	subs, err := client.Subscriptions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 2 || subs[1].FeedUrl() != "https://example.com/rss?format=rss" {
+		t.Errorf("unexpected subscriptions: %+v", subs)
+	}
+
+	items, err := client.Items(ctx, time.Time{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 || items[0].Title != "Kernel release" || items[2].Origin.StreamId != "feed/https://example.com/rss?format=rss" {
+		t.Fatalf("unexpected items: %+v", items)
+	}
+
+	kernel, err := NormalizeId(items[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hello := ItemId("https://example.com/rss?format=rss", "Hello")
+	if err := client.Tag(ctx, []string{kernel}, Read, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Tag(ctx, []string{hello}, Starred, true); err != nil {
+		t.Fatal(err)
+	}
+
+	unread, err := client.ItemIds(ctx, ReadingList, Read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unread) != 2 || unread[0] == kernel {
+		t.Errorf("unexpected unread items: %v", unread)
+	}
+	starred, err := client.ItemIds(ctx, Starred, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(starred) != 1 || starred[0] != hello {
+		t.Errorf("unexpected starred items: %v", starred)
+	}
+
+	// the changes are saved to the cache, as the TUI sees them
+	c.Flush()
+	tui := cache.NewCache()
+	if err := tui.Load(); err != nil {
+		t.Fatal(err)
+	}
+	lwn := tui.GetFeeds()[0]
+	if lwn.GetItem("Kernel release").Unread || !tui.GetFeeds()[1].GetItem("Hello").Starred {
+		t.Error("changes made by a client not saved to the cache")
+	}
+
+	// and the changes made in the TUI are served
+	lwn.GetItem("Security updates").Unread = false
+	if err := tui.Encode(); err != nil {
+		t.Fatal(err)
+	}
+	server.lastRefresh = time.Time{}
+
+	unread, err = client.ItemIds(ctx, ReadingList, Read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unread) != 1 {
+		t.Errorf("changes made in the TUI not served: %v", unread)
+	}
+}
@@ -0,0 +1,404 @@
+package greader
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/cache"
+	"github.com/giulianopz/newscanoe/internal/config"
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/httpclient"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+const (
+	// max number of items fetched by a sync
+	maxItems = 5000
+	// items are fetched from a while before the last sync, in case the clock of the server is not in sync
+	sinceMargin = time.Hour
+)
+
+/*
+Syncer keeps the cache in sync with a server implementing the Google Reader API: the feeds followed on the server
+are added to the config file, their items are added to the cache, and the items read or starred on either side
+since the last sync are read or starred on the other one too
+*/
+type Syncer struct {
+	mu     sync.Mutex
+	client *Client
+	cache  *cache.Cache
+	config *config.Config
+	// path of the file keeping the state of the items as last synced
+	filePath string
+	// held while the feeds and items of the cache or of the config are changed, as by anyone else reading them
+	lock sync.Locker
+}
+
+// state is the state of the items as left by the last sync, to tell which side changed them since then
+type state struct {
+	LastSync time.Time
+	// by the short id of the item on the server
+	Items map[string]*syncedItem
+}
+
+type syncedItem struct {
+	FeedUrl, Title  string
+	Unread, Starred bool
+}
+
+// Result counts what a sync changed
+type Result struct {
+	// feeds added to the config file
+	Feeds int
+	// items added to the cache
+	Items int
+	// items whose state was changed in the cache
+	Pulled int
+	// items whose state was changed on the server
+	Pushed int
+}
+
+func NewSyncer(client *Client, c *cache.Cache, conf *config.Config, filePath string) *Syncer {
+	return &Syncer{
+		client:   client,
+		cache:    c,
+		config:   conf,
+		filePath: filePath,
+		lock:     new(sync.Mutex),
+	}
+}
+
+// SetLocker sets the lock held while the syncer changes the feeds and items of the cache, e.g. the one of the UI showing them
+func (s *Syncer) SetLocker(l sync.Locker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lock = l
+}
+
+// NewConfiguredSyncer returns a syncer set up by the sync settings of the config file, nil if syncing is disabled
+func NewConfiguredSyncer(conf *config.Config, c *cache.Cache) (*Syncer, error) {
+
+	switch backend := conf.Get(config.SYNC_BACKEND); backend {
+	case "":
+		return nil, nil
+	case config.SYNC_GREADER:
+	default:
+		return nil, fmt.Errorf("unknown sync backend: %q", backend)
+	}
+
+	url := conf.Get(config.SYNC_URL)
+	if url == "" {
+		return nil, fmt.Errorf("missing %s setting", config.SYNC_URL)
+	}
+	user, password, err := conf.Credentials(config.SYNC_USER, config.SYNC_PASSWORD)
+	if err != nil {
+		return nil, err
+	}
+	client, err := httpclient.New(conf.HTTPSettings(url))
+	if err != nil {
+		return nil, err
+	}
+	filePath, err := util.GetSyncFilePath()
+	if err != nil {
+		return nil, err
+	}
+	return NewSyncer(NewClient(url, user, password, client), c, conf, filePath), nil
+}
+
+// changes collects the ids of the items to be tagged or untagged on the server
+type changes map[string]map[bool][]string
+
+func (ch changes) add(tag string, add bool, id string) {
+	if ch[tag] == nil {
+		ch[tag] = make(map[bool][]string)
+	}
+	ch[tag][add] = append(ch[tag][add], id)
+}
+
+// Sync runs a sync, saving the cache and, if feeds were added, the config file
+func (s *Syncer) Sync(ctx context.Context) (*Result, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.loadState()
+	if err != nil {
+		return nil, err
+	}
+	started := time.Now()
+	res := &Result{}
+
+	subs, err := s.client.Subscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	feedUrls := make(map[string]string, len(subs))
+	for _, sub := range subs {
+		feedUrls[sub.Id] = sub.FeedUrl()
+	}
+	if err := s.addSubscriptions(subs, res); err != nil {
+		return nil, err
+	}
+
+	// the state on the server before the changes of this sync
+	unreadIds, err := s.client.ItemIds(ctx, ReadingList, Read)
+	if err != nil {
+		return nil, err
+	}
+	starredIds, err := s.client.ItemIds(ctx, Starred, "")
+	if err != nil {
+		return nil, err
+	}
+	unread, starred := toSet(unreadIds), toSet(starredIds)
+
+	var since time.Time
+	if !st.LastSync.IsZero() {
+		since = st.LastSync.Add(-sinceMargin)
+	}
+	items, err := s.client.Items(ctx, since, maxItems)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	push := s.apply(st, groupByFeed(items, feedUrls), unread, starred, res)
+	s.lock.Unlock()
+
+	for tag, byAction := range push {
+		for add, ids := range byAction {
+			if err := s.client.Tag(ctx, ids, tag, add); err != nil {
+				// the state is not saved, so that the changes are pushed again by the next sync
+				s.cache.Save()
+				return nil, err
+			}
+		}
+	}
+
+	s.lock.Lock()
+	for _, f := range s.cache.GetFeeds() {
+		f.CountUnread()
+	}
+	s.lock.Unlock()
+	s.cache.Save()
+
+	st.LastSync = started
+	if err := s.saveState(st); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// addSubscriptions adds to the config file the feeds followed on the server, if not there yet
+func (s *Syncer) addSubscriptions(subs []*Subscription, res *Result) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, sub := range subs {
+		if err := s.config.AddFeed(feed.NewFeed(sub.Title).WithUrl(sub.FeedUrl()), sub.FeedUrl()); err == nil {
+			res.Feeds++
+		}
+	}
+	if res.Feeds == 0 {
+		return nil
+	}
+	s.cache.Merge(s.config)
+	return s.config.Encode()
+}
+
+/*
+apply adds the items fetched from the server to the cache and reconciles the state of the items synced before,
+given the items unread and starred on the server, returning the changes to be pushed to the server
+*/
+func (s *Syncer) apply(st *state, fetched map[string]map[string]*feed.Item, unread, starred map[string]bool, res *Result) changes {
+
+	push := make(changes)
+	added := make(map[string]bool)
+
+	for url, byId := range fetched {
+
+		f := feed.NewFeed(url).WithUrl(url)
+		for _, i := range byId {
+			f.Items = append(f.Items, i)
+		}
+		// not a refresh of the feed itself
+		if cached := s.cachedFeed(url); cached != nil {
+			f.Name, f.TTL, f.LastRefresh = cached.Name, cached.TTL, cached.LastRefresh
+		}
+		before := s.countItems(url)
+		cached := s.cache.AddFeed(f, url)
+		res.Items += len(cached.Items) - before
+
+		for id, remote := range byId {
+			if _, known := st.Items[id]; known {
+				continue
+			}
+			local := cached.GetItem(remote.Title)
+			if local == nil {
+				continue
+			}
+			// an item cached before being synced is read or starred if so on either side
+			if local != remote {
+				local.Unread = local.Unread && remote.Unread
+				local.Starred = local.Starred || remote.Starred
+			}
+			if local.Unread != remote.Unread {
+				push.add(Read, !local.Unread, id)
+				res.Pushed++
+			}
+			if local.Starred != remote.Starred {
+				push.add(Starred, local.Starred, id)
+				res.Pushed++
+			}
+			st.Items[id] = &syncedItem{FeedUrl: url, Title: local.Title, Unread: local.Unread, Starred: local.Starred}
+			added[id] = true
+		}
+	}
+
+	for id, synced := range st.Items {
+
+		if added[id] {
+			continue
+		}
+		local := s.cachedItem(synced.FeedUrl, synced.Title)
+		if local == nil {
+			// dropped from the cache
+			delete(st.Items, id)
+			continue
+		}
+
+		if pushed, pulled := reconcile(&local.Unread, &synced.Unread, unread[id]); pushed {
+			push.add(Read, !local.Unread, id)
+			res.Pushed++
+		} else if pulled {
+			res.Pulled++
+		}
+		if pushed, pulled := reconcile(&local.Starred, &synced.Starred, starred[id]); pushed {
+			push.add(Starred, local.Starred, id)
+			res.Pushed++
+		} else if pulled {
+			res.Pulled++
+		}
+	}
+	return push
+}
+
+/*
+reconcile brings the state of an item in the cache and on the server to the same value, given its value at the last sync:
+the side which changed it since then wins. It reports whether the value in the cache has to be pushed to the server
+or whether the one on the server was pulled into the cache
+*/
+func reconcile(local, last *bool, remote bool) (pushed, pulled bool) {
+	switch {
+	case *local != *last && remote == *last:
+		pushed = true
+	case remote != *last && *local != remote:
+		*local = remote
+		pulled = true
+	}
+	*last = *local
+	return pushed, pulled
+}
+
+// groupByFeed turns the items fetched from the server into items of the feeds of the cache, by feed url and then by id
+func groupByFeed(items []*Item, feedUrls map[string]string) map[string]map[string]*feed.Item {
+
+	feeds := make(map[string]map[string]*feed.Item)
+	for _, i := range items {
+
+		id, err := NormalizeId(i.Id)
+		if err != nil {
+			continue
+		}
+		url, found := feedUrls[i.Origin.StreamId]
+		if !found {
+			url = strings.TrimPrefix(i.Origin.StreamId, feedStreamPrefix)
+		}
+
+		pubDate := util.NoPubDate
+		if i.Published > 0 {
+			pubDate = time.Unix(i.Published, 0).UTC()
+		}
+		item := feed.NewItem(i.Title, i.Url(), pubDate)
+		item.Content = i.Text()
+		item.Unread = !i.Has(Read)
+		item.Starred = i.Has(Starred)
+		for _, e := range i.Enclosure {
+			length, _ := strconv.ParseInt(e.Length, 10, 64)
+			item.Enclosures = append(item.Enclosures, &feed.Enclosure{Url: e.Href, Type: e.Type, Length: max(length, 0)})
+		}
+
+		if feeds[url] == nil {
+			feeds[url] = make(map[string]*feed.Item)
+		}
+		feeds[url][id] = item
+	}
+	return feeds
+}
+
+func (s *Syncer) cachedFeed(url string) *feed.Feed {
+	for _, f := range s.cache.GetFeeds() {
+		if f.Url == url {
+			return f
+		}
+	}
+	return nil
+}
+
+func (s *Syncer) cachedItem(feedUrl, title string) *feed.Item {
+	if f := s.cachedFeed(feedUrl); f != nil {
+		return f.GetItem(title)
+	}
+	return nil
+}
+
+func (s *Syncer) countItems(url string) int {
+	if f := s.cachedFeed(url); f != nil {
+		return len(f.Items)
+	}
+	return 0
+}
+
+func toSet(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+func (s *Syncer) loadState() (*state, error) {
+
+	st := &state{Items: make(map[string]*syncedItem)}
+
+	data, err := os.ReadFile(s.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(st); err != nil {
+		return nil, err
+	}
+	if st.Items == nil {
+		st.Items = make(map[string]*syncedItem)
+	}
+	return st, nil
+}
+
+func (s *Syncer) saveState(st *state) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(st); err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(s.filePath, buf.Bytes(), 0644)
+}
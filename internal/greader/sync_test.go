@@ -0,0 +1,255 @@
+package greader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/giulianopz/newscanoe/internal/cache"
+	"github.com/giulianopz/newscanoe/internal/config"
+)
+
+type fakeItem struct {
+	id            int64
+	feed, title   string
+	read, starred bool
+	published     int64
+}
+
+// fakeServer implements the subset of the Google Reader API used to sync
+type fakeServer struct {
+	mu    sync.Mutex
+	items []*fakeItem
+	// the token returned by the last login, emptied to expire the session
+	auth   string
+	logins int
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.URL.Path == "/accounts/ClientLogin" {
+		if r.FormValue("Email") != "me" || r.FormValue("Passwd") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.logins++
+		s.auth = fmt.Sprintf("token%d", s.logins)
+		fmt.Fprintf(w, "SID=none\nLSID=none\nAuth=%s\n", s.auth)
+		return
+	}
+
+	if s.auth == "" || r.Header.Get("Authorization") != "GoogleLogin auth="+s.auth {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/reader/api/0/subscription/list":
+		json.NewEncoder(w).Encode(map[string]any{"subscriptions": []map[string]any{
+			{"id": "feed/1", "title": "LWN", "url": "https://lwn.net/headlines/rss"},
+			{"id": "feed/2", "title": "Example", "url": "https://example.com/rss"},
+		}})
+
+	case r.URL.Path == "/reader/api/0/stream/items/ids":
+		refs := make([]map[string]string, 0)
+		for _, i := range s.items {
+			if r.FormValue("s") == Starred && i.starred || r.FormValue("s") == ReadingList && r.FormValue("xt") == Read && !i.read {
+				refs = append(refs, map[string]string{"id": fmt.Sprint(i.id)})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"itemRefs": refs})
+
+	case strings.HasPrefix(r.URL.Path, "/reader/api/0/stream/contents/"):
+		// one item per page, to go through continuations
+		start := 0
+		fmt.Sscan(r.FormValue("c"), &start)
+		page := map[string]any{"items": []any{}}
+		if start < len(s.items) {
+			i := s.items[start]
+			categories := []string{ReadingList}
+			if i.read {
+				categories = append(categories, "user/1234/state/com.google/read")
+			}
+			if i.starred {
+				categories = append(categories, Starred)
+			}
+			page["items"] = []any{map[string]any{
+				"id":         longId(fmt.Sprint(i.id)),
+				"title":      i.title,
+				"published":  i.published,
+				"canonical":  []map[string]string{{"href": "https://example.com/" + fmt.Sprint(i.id)}},
+				"summary":    map[string]string{"content": "<p>" + i.title + "</p>"},
+				"categories": categories,
+				"origin":     map[string]string{"streamId": i.feed},
+			}}
+			if start+1 < len(s.items) {
+				page["continuation"] = fmt.Sprint(start + 1)
+			}
+		}
+		json.NewEncoder(w).Encode(page)
+
+	case r.URL.Path == "/reader/api/0/token":
+		fmt.Fprintln(w, "edit-token")
+
+	case r.URL.Path == "/reader/api/0/edit-tag" && r.Method == http.MethodPost:
+		r.ParseForm()
+		if r.PostForm.Get("T") != "edit-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		for _, id := range r.PostForm["i"] {
+			short, _ := NormalizeId(id)
+			for _, i := range s.items {
+				if fmt.Sprint(i.id) != short {
+					continue
+				}
+				for _, tag := range r.PostForm["a"] {
+					i.read = i.read || tag == Read
+					i.starred = i.starred || tag == Starred
+				}
+				for _, tag := range r.PostForm["r"] {
+					i.read = i.read && tag != Read
+					i.starred = i.starred && tag != Starred
+				}
+			}
+		}
+		fmt.Fprint(w, "OK")
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *fakeServer) item(title string) *fakeItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, i := range s.items {
+		if i.title == title {
+			return i
+		}
+	}
+	return nil
+}
+
+func TestSync(t *testing.T) {
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	server := &fakeServer{items: []*fakeItem{
+		{id: 1, feed: "feed/1", title: "Kernel release", published: 1723507200},
+		{id: 2, feed: "feed/1", title: "Security updates", published: 1723420800, read: true},
+		{id: 3, feed: "feed/2", title: "Hello", published: 1723334400, starred: true},
+	}}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	conf := config.NewConfig()
+	c := cache.NewCache()
+	defer c.Flush()
+
+	s := NewSyncer(NewClient(ts.URL, "me", "secret", ts.Client()), c, conf, filepath.Join(t.TempDir(), "sync.gob"))
+
+	res, err := s.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *res != (Result{Feeds: 2, Items: 3}) {
+		t.Errorf("unexpected result of first sync: %+v", res)
+	}
+	if len(conf.Feeds) != 2 || conf.Feeds[0].Name != "LWN" {
+		t.Errorf("subscriptions not added to config: %v", conf.Feeds)
+	}
+
+	local := func(feedUrl, title string) (unread, starred bool) {
+		for _, f := range c.GetFeeds() {
+			if f.Url == feedUrl {
+				if i := f.GetItem(title); i != nil {
+					return i.Unread, i.Starred
+				}
+			}
+		}
+		t.Fatalf("item not cached: %s", title)
+		return
+	}
+	if unread, _ := local("https://lwn.net/headlines/rss", "Kernel release"); !unread {
+		t.Error("unread item synced as read")
+	}
+	if unread, _ := local("https://lwn.net/headlines/rss", "Security updates"); unread {
+		t.Error("read item synced as unread")
+	}
+	if _, starred := local("https://example.com/rss", "Hello"); !starred {
+		t.Error("starred item synced as not starred")
+	}
+
+	// changed in the TUI, once the sync has written the cache
+	c.Flush()
+	for _, f := range c.GetFeeds() {
+		if i := f.GetItem("Kernel release"); i != nil {
+			i.Unread = false
+		}
+		if i := f.GetItem("Hello"); i != nil {
+			i.Starred = false
+		}
+	}
+	// changed on the server, e.g. from a phone
+	server.item("Security updates").read = false
+	// and the session expired meanwhile
+	server.mu.Lock()
+	server.auth = ""
+	server.mu.Unlock()
+
+	res, err = s.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *res != (Result{Pushed: 2, Pulled: 1}) {
+		t.Errorf("unexpected result of second sync: %+v", res)
+	}
+	if !server.item("Kernel release").read || server.item("Hello").starred {
+		t.Error("changes made in the TUI not pushed to the server")
+	}
+	if unread, _ := local("https://lwn.net/headlines/rss", "Security updates"); !unread {
+		t.Error("changes made on the server not pulled into the cache")
+	}
+	if server.logins != 2 {
+		t.Errorf("got %d logins, want 2", server.logins)
+	}
+
+	// nothing changed since
+	res, err = s.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *res != (Result{}) {
+		t.Errorf("unexpected result of third sync: %+v", res)
+	}
+}
+
+func TestNormalizeId(t *testing.T) {
+	for id, want := range map[string]string{
+		"tag:google.com,2005:reader/item/000000000000001f": "31",
+		"31": "31",
+		"tag:google.com,2005:reader/item/ffffffffffffffff": "-1",
+	} {
+		got, err := NormalizeId(id)
+		if err != nil || got != want {
+			t.Errorf("NormalizeId(%q) = %q, %v, want %q", id, got, err, want)
+		}
+		if got == want && longId(got) != id && strings.HasPrefix(id, longIdPrefix) {
+			t.Errorf("longId(%q) = %q, want %q", got, longId(got), id)
+		}
+	}
+	if _, err := NormalizeId("not an id"); err == nil {
+		t.Error("malformed id accepted")
+	}
+}
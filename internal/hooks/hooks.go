@@ -0,0 +1,289 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/util"
+)
+
+// kinds of hooks
+const (
+	// a command fed with the item on its standard input
+	EXEC = "exec"
+	// an HTTP endpoint receiving the item as the body of a POST request
+	POST = "post"
+)
+
+// keys of the filters of a hook
+const (
+	feedFilter  = "feed"
+	matchFilter = "match"
+)
+
+// how long a single delivery can last
+const deliveryTimeout = 30 * time.Second
+
+/*
+Hook delivers the new items found by a refresh to a command or to an HTTP endpoint,
+optionally only the ones of a feed (matching its url or its name) or whose title matches a regular expression
+*/
+type Hook struct {
+	Kind   string
+	Target string
+	Feed   string
+	Match  *regexp.Regexp
+}
+
+// New returns a hook of the given kind, filtered by the given key=value pairs (feed=<url or name>, match=<regexp>)
+func New(kind, target string, filters ...string) (*Hook, error) {
+
+	if kind != EXEC && kind != POST {
+		return nil, fmt.Errorf("hook kind must be either %q or %q: %q", EXEC, POST, kind)
+	}
+	if strings.TrimSpace(target) == "" {
+		return nil, fmt.Errorf("missing target of %s hook", kind)
+	}
+
+	h := &Hook{Kind: kind, Target: target}
+	for _, f := range filters {
+		key, value, found := strings.Cut(f, "=")
+		if !found {
+			return nil, fmt.Errorf("hook filter must be in the form key=value: %q", f)
+		}
+		switch key {
+		case feedFilter:
+			h.Feed = value
+		case matchFilter:
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regexp in hook filter %q: %w", f, err)
+			}
+			h.Match = re
+		default:
+			return nil, fmt.Errorf("unknown hook filter: %q", f)
+		}
+	}
+	return h, nil
+}
+
+func (h *Hook) String() string {
+	return h.Kind + " " + h.Target
+}
+
+// Matches reports whether the hook is interested in an item of the given feed
+func (h *Hook) Matches(f *feed.Feed, i *feed.Item) bool {
+	if h.Feed != "" && h.Feed != f.Url && h.Feed != f.Name {
+		return false
+	}
+	return h.Match == nil || h.Match.MatchString(i.Title)
+}
+
+// Event is the JSON document delivered to hooks for every new item
+type Event struct {
+	Feed    string     `json:"feed"`
+	FeedUrl string     `json:"feed_url"`
+	Title   string     `json:"title"`
+	Url     string     `json:"url"`
+	Date    *time.Time `json:"date,omitempty"`
+	Content string     `json:"content,omitempty"`
+}
+
+func NewEvent(f *feed.Feed, i *feed.Item) *Event {
+	e := &Event{
+		Feed:    f.Name,
+		FeedUrl: f.Url,
+		Title:   i.Title,
+		Url:     i.Url,
+		Content: i.Content,
+	}
+	if i.PubDate != util.NoPubDate && !i.PubDate.IsZero() {
+		date := i.PubDate
+		e.Date = &date
+	}
+	return e
+}
+
+/*
+Runner delivers new items to hooks in background, one at a time in order of arrival,
+retrying failed deliveries with an exponential backoff and appending the ones failed for good to a log file.
+Firing never waits for deliveries, however many are pending, since it happens while the cache is locked
+*/
+type Runner struct {
+	hooks  []*Hook
+	client *http.Client
+	// attempts after the first one
+	retries int
+	// wait before the first retry, doubled at every attempt
+	backoff time.Duration
+	// file where failed deliveries are logged
+	logPath string
+
+	mu sync.Mutex
+	// deliveries not yet started, in order of arrival
+	pending []delivery
+	// wakes up the delivery loop when deliveries are pending
+	wake chan struct{}
+	wg   sync.WaitGroup
+}
+
+type delivery struct {
+	hook    *Hook
+	payload []byte
+}
+
+func NewRunner(hooks []*Hook, client *http.Client, retries int, backoff time.Duration, logPath string) *Runner {
+	r := &Runner{
+		hooks:   hooks,
+		client:  client,
+		retries: max(retries, 0),
+		backoff: backoff,
+		logPath: logPath,
+		wake:    make(chan struct{}, 1),
+	}
+	go r.loop()
+	return r
+}
+
+// Fire delivers the new items of a feed to every hook interested in them
+func (r *Runner) Fire(f *feed.Feed, items []*feed.Item) {
+	for _, i := range items {
+		var payload []byte
+		for _, h := range r.hooks {
+			if !h.Matches(f, i) {
+				continue
+			}
+			if payload == nil {
+				bs, err := json.Marshal(NewEvent(f, i))
+				if err != nil {
+					log.Default().Printf("cannot encode item %s: %v\n", i.Url, err)
+					break
+				}
+				payload = bs
+			}
+			r.wg.Add(1)
+			r.mu.Lock()
+			r.pending = append(r.pending, delivery{hook: h, payload: payload})
+			r.mu.Unlock()
+		}
+	}
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+		// the loop is already awake
+	}
+}
+
+// Wait blocks until all the items fired so far have been delivered or have failed for good
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+func (r *Runner) loop() {
+	for range r.wake {
+		for d, found := r.next(); found; d, found = r.next() {
+			r.deliverWithRetries(d)
+			r.wg.Done()
+		}
+	}
+}
+
+// next takes the first pending delivery, if any
+func (r *Runner) next() (delivery, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.pending) == 0 {
+		return delivery{}, false
+	}
+	d := r.pending[0]
+	r.pending[0] = delivery{}
+	r.pending = r.pending[1:]
+	return d, true
+}
+
+func (r *Runner) deliverWithRetries(d delivery) {
+
+	var err error
+	wait := r.backoff
+	for attempt := 0; attempt <= r.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(wait)
+			wait *= 2
+		}
+		if err = r.deliver(d.hook, d.payload); err == nil {
+			return
+		}
+		log.Default().Printf("hook %s failed (attempt %d): %v\n", d.hook, attempt+1, err)
+	}
+	r.logFailure(d, err)
+}
+
+func (r *Runner) deliver(h *Hook, payload []byte) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+	defer cancel()
+
+	switch h.Kind {
+	case POST:
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.Target, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("unexpected status: %s", resp.Status)
+		}
+		return nil
+
+	default:
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "sh", "-c", h.Target)
+		cmd.Stdin = bytes.NewReader(payload)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return fmt.Errorf("%w: %s", util.CmdError(err), msg)
+			}
+			return util.CmdError(err)
+		}
+		return nil
+	}
+}
+
+// logFailure appends a line to the failure log with the time, the hook, the item and the error
+func (r *Runner) logFailure(d delivery, cause error) {
+
+	var e Event
+	json.Unmarshal(d.payload, &e)
+
+	f, err := os.OpenFile(r.logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.Default().Printf("cannot open hooks log: %v\n", err)
+		return
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "%s\t%s\t%s\t%v\n", time.Now().Format(time.RFC3339), d.hook, e.Url, cause)
+}
@@ -0,0 +1,176 @@
+package hooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+)
+
+func TestRunner(t *testing.T) {
+
+	var (
+		mu       sync.Mutex
+		received []Event
+		attempts int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts++
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// fails the first time
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var e Event
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &e); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = append(received, e)
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "hooks.log")
+	execOut := filepath.Join(dir, "items.jsonl")
+
+	post, err := New(POST, server.URL, "feed=LWN", "match=(?i)kernel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := New(EXEC, "cat >> "+execOut+"; echo >> "+execOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken, err := New(EXEC, "echo 'chat is down' >&2; exit 1", "feed=https://go.dev/blog/feed.atom")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRunner([]*Hook{post, store, broken}, server.Client(), 2, time.Millisecond, logPath)
+
+	lwn := feed.NewFeed("LWN").WithUrl("https://lwn.net/headlines/rss")
+	golang := feed.NewFeed("Go Blog").WithUrl("https://go.dev/blog/feed.atom")
+	date := time.Date(2024, 8, 13, 0, 0, 0, 0, time.UTC)
+
+	r.Fire(lwn, []*feed.Item{
+		feed.NewItem("Kernel release status", "https://lwn.net/1", date),
+		feed.NewItem("Security updates", "https://lwn.net/2", date),
+	})
+	r.Fire(golang, []*feed.Item{feed.NewItem("Go 1.23", "https://go.dev/blog/go1.23", date)})
+	r.Wait()
+
+	t.Run("posts matching items retrying failures", func(t *testing.T) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(received) != 1 || attempts != 2 {
+			t.Fatalf("want 1 item after 2 attempts, got %d after %d", len(received), attempts)
+		}
+		e := received[0]
+		if e.Feed != "LWN" || e.FeedUrl != lwn.Url || e.Title != "Kernel release status" || e.Url != "https://lwn.net/1" || e.Date == nil || !e.Date.Equal(date) {
+			t.Errorf("unexpected event: %+v", e)
+		}
+	})
+
+	t.Run("feeds commands with every item", func(t *testing.T) {
+		bs, err := os.ReadFile(execOut)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(bs)), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("want 3 items, got: %q", lines)
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(lines[2]), &e); err != nil || e.Title != "Go 1.23" {
+			t.Errorf("unexpected item: %q (%v)", lines[2], err)
+		}
+	})
+
+	t.Run("logs failures", func(t *testing.T) {
+		bs, err := os.ReadFile(logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(bs)), "\n")
+		if len(lines) != 1 || !strings.Contains(lines[0], "https://go.dev/blog/go1.23") || !strings.Contains(lines[0], "chat is down") {
+			t.Errorf("unexpected failure log: %q", lines)
+		}
+	})
+}
+
+func TestFireDoesNotBlock(t *testing.T) {
+
+	release := make(chan struct{})
+	var (
+		mu       sync.Mutex
+		received int
+	)
+	// an endpoint hanging until released
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		mu.Lock()
+		received++
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	post, err := New(POST, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRunner([]*Hook{post}, server.Client(), 0, time.Millisecond, filepath.Join(t.TempDir(), "hooks.log"))
+
+	f := feed.NewFeed("LWN").WithUrl("https://lwn.net/headlines/rss")
+	items := make([]*feed.Item, 0)
+	for n := 0; n < 1000; n++ {
+		items = append(items, feed.NewItem(fmt.Sprintf("Item %d", n), fmt.Sprintf("https://lwn.net/%d", n), time.Now()))
+	}
+
+	fired := make(chan struct{})
+	go func() {
+		r.Fire(f, items)
+		close(fired)
+	}()
+	select {
+	case <-fired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Fire blocked by pending deliveries")
+	}
+
+	close(release)
+	r.Wait()
+	if received != len(items) {
+		t.Errorf("got %d items, want %d", received, len(items))
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, args := range [][]string{
+		{"mail", "sendmail me"},
+		{EXEC, " "},
+		{POST, "http://localhost", "match=("},
+		{POST, "http://localhost", "tag=news"},
+	} {
+		if _, err := New(args[0], args[1], args[2:]...); err == nil {
+			t.Errorf("want error for: %q", args)
+		}
+	}
+}
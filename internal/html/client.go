@@ -0,0 +1,97 @@
+package html
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// StatusError is returned when a web page cannot be fetched because of a non-2xx response
+type StatusError struct {
+	Code   int
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response: %s", e.Status)
+}
+
+// ErrTooLarge is returned when a web page exceeds the max size allowed
+var ErrTooLarge = errors.New("page too large")
+
+// Client fetches the web pages to extract articles from
+type Client struct {
+	client  *http.Client
+	timeout time.Duration
+	maxSize int64
+}
+
+func NewClient(client *http.Client, timeout time.Duration, maxSize int64) *Client {
+	return &Client{
+		client:  client,
+		timeout: timeout,
+		maxSize: maxSize,
+	}
+}
+
+// ExtractArticle fetches a web page and extracts its readable content
+func (c *Client) ExtractArticle(url string) (*Article, error) {
+
+	page, err := c.Fetch(url)
+	if err != nil {
+		return nil, err
+	}
+	return FromPage(page, url)
+}
+
+/*
+Fetch returns the content of a web page converted to UTF-8,
+according to the charset declared by the Content-Type header or by the page itself
+(see: https://html.spec.whatwg.org/multipage/parsing.html#determining-the-character-encoding)
+*/
+func (c *Client) Fetch(url string) (string, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", &StatusError{Code: resp.StatusCode, Status: resp.Status}
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, c.maxSize+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(raw)) > c.maxSize {
+		return "", fmt.Errorf("%w: more than %d bytes", ErrTooLarge, c.maxSize)
+	}
+
+	body, err := charset.NewReader(bytes.NewReader(raw), resp.Header.Get("Content-Type"))
+	if err != nil {
+		return "", err
+	}
+
+	bs, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
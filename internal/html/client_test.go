@@ -0,0 +1,63 @@
+package html
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetch(t *testing.T) {
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/latin1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		// "perché" encoded as ISO-8859-1
+		w.Write([]byte("<html><head><meta charset=\"iso-8859-1\"></head><body>perch\xe9</body></html>"))
+	})
+	mux.HandleFunc("/forbidden", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "go away", http.StatusForbidden)
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 2048))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	c := NewClient(srv.Client(), 100*time.Millisecond, 1024)
+
+	t.Run("converts page to UTF-8 according to meta charset", func(t *testing.T) {
+		page, err := c.Fetch(srv.URL + "/latin1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "<html><head><meta charset=\"iso-8859-1\"></head><body>perché</body></html>"; page != want {
+			t.Errorf("got %q, want %q", page, want)
+		}
+	})
+
+	t.Run("returns status of non-2xx responses", func(t *testing.T) {
+		_, err := c.Fetch(srv.URL + "/forbidden")
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.Code != http.StatusForbidden {
+			t.Errorf("got %v, want status error with code %d", err, http.StatusForbidden)
+		}
+	})
+
+	t.Run("refuses pages larger than max size", func(t *testing.T) {
+		if _, err := c.Fetch(srv.URL + "/large"); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("got %v, want %v", err, ErrTooLarge)
+		}
+	})
+
+	t.Run("gives up after timeout", func(t *testing.T) {
+		if _, err := c.Fetch(srv.URL + "/slow"); err == nil {
+			t.Errorf("got no error, want timeout")
+		}
+	})
+}
@@ -1,38 +1,122 @@
 package html
 
 import (
-	"html"
-	"io"
+	"fmt"
 	"log"
-	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/giulianopz/go-readability"
+	xhtml "golang.org/x/net/html"
 )
 
-func ExtractText(url string) (string, error) {
+// Article is the readable content of a web page
+type Article struct {
+	Title string
+	// content of the article, where every hyperlink is followed by its number in square brackets
+	Blocks []*Block
+	// hyperlinks found in the article, the first one being numbered as 1
+	Links []string
+}
 
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
+// Link returns the hyperlink with the given footnote number
+func (a *Article) Link(num int) (string, error) {
+	if num < 1 || num > len(a.Links) {
+		return "", fmt.Errorf("no link numbered as %d", num)
 	}
+	return a.Links[num-1], nil
+}
 
-	bs, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+// String returns the text of the article as plain text, followed by the list of its hyperlinks
+func (a *Article) String() string {
+	var sb strings.Builder
+	for i, b := range a.Blocks {
+		if i != 0 {
+			// consecutive list items are not separated by an empty line
+			if b.Kind != LIST_ITEM || a.Blocks[i-1].Kind != LIST_ITEM {
+				sb.WriteString("\n")
+			}
+		}
+		switch b.Kind {
+		case LIST_ITEM:
+			indent := strings.Repeat("  ", b.Depth-1)
+			marker := b.Marker
+			if marker == "" {
+				marker = " "
+			}
+			sb.WriteString(indent + marker + " " + strings.ReplaceAll(b.Text, "\n", "\n"+indent+"  "))
+		case QUOTE:
+			prefix := strings.Repeat("> ", b.Depth)
+			sb.WriteString(prefix + strings.ReplaceAll(b.Text, "\n", "\n"+prefix))
+		default:
+			sb.WriteString(b.Text)
+		}
+		sb.WriteString("\n")
+	}
+	if len(a.Links) != 0 {
+		sb.WriteString("\nLinks:\n")
+		for i, l := range a.Links {
+			fmt.Fprintf(&sb, "[%d] %s\n", i+1, l)
+		}
 	}
+	return sb.String()
+}
+
+// FromPage extracts the readable content of a web page
+func FromPage(page, url string) (*Article, error) {
 
 	reader, err := readability.New(
-		string(bs), url, readability.LogLevel(-1),
+		page, url, readability.LogLevel(-1),
 	)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	article, err := reader.Parse()
+	result, err := reader.Parse()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	unescapedText := html.UnescapeString(article.TextContent)
-	log.Default().Printf("unescaped article text: %s", unescapedText)
-	return unescapedText, nil
+	article, err := FromHTML(result.Content, url)
+	if err != nil {
+		return nil, err
+	}
+	article.Title = result.Title
+
+	log.Default().Printf("article text: %s", article)
+	return article, nil
+}
+
+// FromHTML converts an HTML fragment to an article, resolving its relative hyperlinks against the url of the page it belongs to, if any
+func FromHTML(content, pageUrl string) (*Article, error) {
+
+	root, err := xhtml.Parse(strings.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	w := newTextWriter()
+	if pageUrl != "" {
+		if w.base, err = url.Parse(pageUrl); err != nil {
+			log.Default().Printf("cannot resolve links against %q: %v\n", pageUrl, err)
+		}
+	}
+	w.walk(root)
+	w.flush()
+
+	return &Article{
+		Blocks: w.blocks,
+		Links:  w.links,
+	}, nil
+}
+
+// TextToHTML converts plain text to an HTML fragment, keeping its paragraphs and line breaks
+func TextToHTML(text string) string {
+	var sb strings.Builder
+	for _, p := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if p = strings.Trim(p, "\n"); strings.TrimSpace(p) == "" {
+			continue
+		}
+		sb.WriteString("<p>" + strings.ReplaceAll(xhtml.EscapeString(p), "\n", "<br>") + "</p>")
+	}
+	return sb.String()
 }
@@ -0,0 +1,139 @@
+package html
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestFromHTMLResolvesLinks(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		content string
+		pageUrl string
+		want    []string
+	}{
+		{"absolute", `<a href="https://example.org/a">a</a>`, "https://example.com/post/1", []string{"https://example.org/a"}},
+		{"root relative", `<a href="/about">a</a>`, "https://example.com/post/1", []string{"https://example.com/about"}},
+		{"path relative", `<a href="2">a</a> <a href="../tags">b</a>`, "https://example.com/post/1", []string{"https://example.com/post/2", "https://example.com/tags"}},
+		{"same link once", `<a href="/about">a</a> <a href="https://example.com/about">b</a>`, "https://example.com/post/1", []string{"https://example.com/about"}},
+		{"no page url", `<a href="/about">a</a>`, "", []string{"/about"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			article, err := FromHTML(tt.content, tt.pageUrl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(article.Links, tt.want) {
+				t.Errorf("got links %q, want %q", article.Links, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextToHTML(t *testing.T) {
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"line breaks", "First line\nSecond line", "First line\nSecond line\n"},
+		{"paragraphs", "First paragraph\r\n\r\nSecond paragraph\n\n\n", "First paragraph\n\nSecond paragraph\n"},
+		{"markup taken literally", "a <b>bold</b> & <a href=\"/x\">link</a>", "a <b>bold</b> & <a href=\"/x\">link</a>\n"},
+		{"blank", " \n\n ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			article, err := FromHTML(TextToHTML(tt.text), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := article.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFootnotes(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		content   string
+		wantText  string
+		wantLinks []string
+	}{
+		{
+			name:      "numbered in order of appearance",
+			content:   `<p><a href="https://example.com/a">a</a> and <a href="https://example.com/b">b</a></p>`,
+			wantText:  "a[1] and b[2]",
+			wantLinks: []string{"https://example.com/a", "https://example.com/b"},
+		},
+		{
+			name:      "same number for the same link",
+			content:   `<p><a href="https://example.com/a">a</a>, <a href="https://example.com/b">b</a>, <a href="https://example.com/a">again</a></p>`,
+			wantText:  "a[1], b[2], again[1]",
+			wantLinks: []string{"https://example.com/a", "https://example.com/b"},
+		},
+		{
+			name:      "no number for links within the page or scripts",
+			content:   `<p><a href="#top">top</a> <a href="javascript:void(0)">js</a> <a href=" ">blank</a> <a>none</a></p>`,
+			wantText:  "top js blank none",
+			wantLinks: []string{},
+		},
+		{
+			name:      "across blocks",
+			content:   `<h1><a href="https://example.com/a">Title</a></h1><ul><li><a href="https://example.com/b">item</a></li></ul>`,
+			wantText:  "Title[1]\n\n• item[2]",
+			wantLinks: []string{"https://example.com/a", "https://example.com/b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			article, err := FromHTML(tt.content, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := article.String()
+			if text, _, _ := strings.Cut(got, "\n\nLinks:\n"); strings.TrimSuffix(text, "\n") != tt.wantText {
+				t.Errorf("got text %q, want %q", text, tt.wantText)
+			}
+			if !slices.Equal(article.Links, tt.wantLinks) {
+				t.Errorf("got links %q, want %q", article.Links, tt.wantLinks)
+			}
+		})
+	}
+}
+
+func TestArticleLink(t *testing.T) {
+
+	article := &Article{Links: []string{"https://example.com/a", "https://example.com/b"}}
+
+	tests := []struct {
+		name    string
+		num     int
+		want    string
+		wantErr bool
+	}{
+		{name: "first", num: 1, want: "https://example.com/a"},
+		{name: "last", num: 2, want: "https://example.com/b"},
+		{name: "zero", num: 0, wantErr: true},
+		{name: "negative", num: -1, wantErr: true},
+		{name: "past the last", num: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := article.Link(tt.num)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package html
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// kinds of blocks an article is made of
+const (
+	PARAGRAPH = iota
+	HEADING
+	LIST_ITEM
+	QUOTE
+	CODE
+)
+
+// Block is a piece of text to be rendered on its own lines
+type Block struct {
+	Kind int
+	// text of the block, where line breaks are kept only within code blocks and after <br> elements
+	Text string
+	// nesting level of lists and quotes
+	Depth int
+	// bullet or number preceding the first line of a list item
+	Marker string
+}
+
+// elements whose content starts on a new line
+var blocks = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Figcaption: true, atom.Figure: true, atom.Footer: true, atom.Form: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true, atom.Nav: true,
+	atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true, atom.Table: true,
+	atom.Tr: true, atom.Ul: true,
+}
+
+var headings = map[atom.Atom]bool{
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+}
+
+// elements whose content is not meant to be read
+var skipped = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+}
+
+// list is an ordered or unordered list being walked through
+type list struct {
+	ordered bool
+	count   int
+}
+
+/*
+textWriter converts an HTML tree to a sequence of blocks,
+marking every hyperlink with a footnote number in square brackets, e.g. "linked lists[3]"
+*/
+type textWriter struct {
+	// inline text of the current block
+	sb     strings.Builder
+	blocks []*Block
+	// hyperlinks in order of appearance
+	links []string
+	// footnote number of every hyperlink
+	numbers map[string]int
+	// url of the page, against which relative hyperlinks are resolved
+	base *url.URL
+
+	// depth of nested elements of each kind
+	pre, heading, quotes, items int
+	lists                       []*list
+	// marker of the list item whose text is not yet flushed
+	marker string
+}
+
+func newTextWriter() *textWriter {
+	return &textWriter{
+		blocks:  make([]*Block, 0),
+		links:   make([]string, 0),
+		numbers: make(map[string]int),
+		lists:   make([]*list, 0),
+	}
+}
+
+func (w *textWriter) walk(n *xhtml.Node) {
+	switch n.Type {
+	case xhtml.TextNode:
+		if w.pre > 0 {
+			w.sb.WriteString(n.Data)
+		} else {
+			w.writeCollapsed(n.Data)
+		}
+		return
+	case xhtml.ElementNode:
+		if skipped[n.DataAtom] {
+			return
+		}
+	}
+
+	if n.DataAtom == atom.Br {
+		w.sb.WriteString("\n")
+		return
+	}
+
+	isBlock := blocks[n.DataAtom] && w.pre == 0
+	if isBlock {
+		w.flush()
+		w.enter(n.DataAtom)
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+
+	if n.DataAtom == atom.A {
+		if num := w.footnote(attr(n, "href")); num != 0 {
+			fmt.Fprintf(&w.sb, "[%d]", num)
+		}
+	}
+
+	if isBlock {
+		w.flush()
+		w.leave(n.DataAtom)
+	}
+}
+
+func (w *textWriter) enter(a atom.Atom) {
+	switch {
+	case headings[a]:
+		w.heading++
+	case a == atom.Blockquote:
+		w.quotes++
+	case a == atom.Pre:
+		w.pre++
+	case a == atom.Ul:
+		w.lists = append(w.lists, &list{})
+	case a == atom.Ol:
+		w.lists = append(w.lists, &list{ordered: true})
+	case a == atom.Li:
+		w.items++
+		w.marker = "•"
+		if len(w.lists) != 0 {
+			l := w.lists[len(w.lists)-1]
+			l.count++
+			if l.ordered {
+				w.marker = fmt.Sprintf("%d.", l.count)
+			}
+		}
+	}
+}
+
+func (w *textWriter) leave(a atom.Atom) {
+	switch {
+	case headings[a]:
+		w.heading--
+	case a == atom.Blockquote:
+		w.quotes--
+	case a == atom.Pre:
+		w.pre--
+	case a == atom.Ul, a == atom.Ol:
+		if len(w.lists) != 0 {
+			w.lists = w.lists[:len(w.lists)-1]
+		}
+	case a == atom.Li:
+		w.items--
+		w.marker = ""
+	}
+}
+
+// flush turns the inline text walked so far into a block, according to the elements enclosing it
+func (w *textWriter) flush() {
+
+	text := w.sb.String()
+	w.sb.Reset()
+
+	b := &Block{}
+	switch {
+	case w.pre > 0:
+		b.Kind = CODE
+		text = strings.Trim(text, "\r\n")
+	case w.heading > 0:
+		b.Kind = HEADING
+	case w.items > 0:
+		b.Kind = LIST_ITEM
+		b.Depth = len(w.lists)
+		b.Marker = w.marker
+	case w.quotes > 0:
+		b.Kind = QUOTE
+		b.Depth = w.quotes
+	default:
+		b.Kind = PARAGRAPH
+	}
+
+	if b.Kind != CODE {
+		text = strings.TrimSpace(text)
+	}
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	b.Text = text
+
+	if b.Kind == LIST_ITEM {
+		// following paragraphs of the same item are just indented
+		w.marker = ""
+	}
+
+	w.blocks = append(w.blocks, b)
+}
+
+// footnote returns the number assigned to a hyperlink, zero if it does not point to another page
+func (w *textWriter) footnote(href string) int {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+		return 0
+	}
+	if w.base != nil {
+		if ref, err := url.Parse(href); err == nil {
+			href = w.base.ResolveReference(ref).String()
+		}
+	}
+	if num, found := w.numbers[href]; found {
+		return num
+	}
+	w.links = append(w.links, href)
+	w.numbers[href] = len(w.links)
+	return len(w.links)
+}
+
+// writeCollapsed writes a text replacing every sequence of white spaces with a single space
+func (w *textWriter) writeCollapsed(text string) {
+	var space bool
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space && !w.atLineStart() {
+			w.sb.WriteRune(' ')
+		}
+		space = false
+		w.sb.WriteRune(r)
+	}
+	if space && !w.atLineStart() {
+		w.sb.WriteRune(' ')
+	}
+}
+
+func (w *textWriter) atLineStart() bool {
+	s := w.sb.String()
+	return s == "" || strings.HasSuffix(s, "\n")
+}
+
+func attr(n *xhtml.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
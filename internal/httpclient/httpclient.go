@@ -0,0 +1,138 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// NO_PROXY disables any proxy, including the ones set by the environment
+const NO_PROXY = "none"
+
+/*
+Settings configures the HTTP clients used to fetch both feeds and articles.
+By default, the proxy is set by the environment variables HTTP_PROXY, HTTPS_PROXY and NO_PROXY:
+a proxy url can either use the http(s) or the socks5 scheme (e.g. socks5://localhost:1080).
+see: https://pkg.go.dev/net/http#ProxyFromEnvironment
+*/
+type Settings struct {
+	Proxy     string
+	UserAgent string
+	// PEM bundle of additional certificate authorities
+	CAFile string
+	// PEM files of the client certificate and of its private key
+	CertFile string
+	KeyFile  string
+	// skip verification of server certificates
+	Insecure bool
+}
+
+// Pool hands out a client for every distinct set of settings, so that clients are reused across requests
+type Pool struct {
+	mu      sync.Mutex
+	clients map[Settings]*http.Client
+}
+
+func NewPool() *Pool {
+	return &Pool{
+		clients: make(map[Settings]*http.Client),
+	}
+}
+
+func (p *Pool) Get(s Settings) (*http.Client, error) {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if c, found := p.clients[s]; found {
+		return c, nil
+	}
+
+	c, err := New(s)
+	if err != nil {
+		return nil, err
+	}
+	p.clients[s] = c
+	return c, nil
+}
+
+func New(s Settings) (*http.Client, error) {
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	switch s.Proxy {
+	case "":
+		t.Proxy = http.ProxyFromEnvironment
+	case NO_PROXY:
+		t.Proxy = nil
+	default:
+		proxyUrl, err := url.Parse(s.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url %q: %w", s.Proxy, err)
+		}
+		t.Proxy = http.ProxyURL(proxyUrl)
+	}
+
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	t.TLSClientConfig = tlsConfig
+
+	return &http.Client{
+		Transport: &userAgentTransport{
+			userAgent: s.UserAgent,
+			next:      t,
+		},
+	}, nil
+}
+
+func (s Settings) tlsConfig() (*tls.Config, error) {
+
+	c := &tls.Config{
+		InsecureSkipVerify: s.Insecure,
+	}
+
+	if s.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		bs, err := os.ReadFile(s.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(bs) {
+			return nil, fmt.Errorf("no certificate found in: %s", s.CAFile)
+		}
+		c.RootCAs = pool
+	}
+
+	if s.CertFile != "" || s.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+
+	return c, nil
+}
+
+// userAgentTransport sets the User-Agent header of every request lacking it
+type userAgentTransport struct {
+	userAgent string
+	next      http.RoundTripper
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.userAgent != "" && req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.next.RoundTrip(req)
+}
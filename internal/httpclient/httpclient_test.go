@@ -0,0 +1,67 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("origin:" + r.UserAgent()))
+	}))
+	t.Cleanup(origin.Close)
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("proxy:" + r.URL.String()))
+	}))
+	t.Cleanup(proxy.Close)
+
+	get := func(t *testing.T, s Settings) string {
+		t.Helper()
+		c, err := New(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := c.Get(origin.URL + "/feed")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		bs, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(bs)
+	}
+
+	t.Run("sends user agent", func(t *testing.T) {
+		if got, want := get(t, Settings{UserAgent: "newscanoe/test", Proxy: NO_PROXY}), "origin:newscanoe/test"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("sends requests through configured proxy", func(t *testing.T) {
+		if got, want := get(t, Settings{Proxy: proxy.URL}), "proxy:"+origin.URL+"/feed"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("rejects invalid proxy url", func(t *testing.T) {
+		if _, err := New(Settings{Proxy: "://nowhere"}); err == nil {
+			t.Errorf("got no error, want invalid proxy url")
+		}
+	})
+
+	t.Run("reuses clients with same settings", func(t *testing.T) {
+		p := NewPool()
+		a, _ := p.Get(Settings{UserAgent: "a"})
+		b, _ := p.Get(Settings{UserAgent: "a"})
+		c, _ := p.Get(Settings{UserAgent: "c"})
+		if a != b || a == c {
+			t.Errorf("clients must be shared by the same settings only")
+		}
+	})
+}
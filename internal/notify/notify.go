@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+)
+
+// placeholders replaced in the templates of notifications
+const (
+	countPlaceholder = "%n"
+	feedPlaceholder  = "%f"
+	titlePlaceholder = "%t"
+)
+
+// News are the items found in a feed by a refresh which were not there before
+type News struct {
+	FeedName, FeedUrl string
+	Items             []*feed.Item
+}
+
+/*
+Notifier collects the news found by refreshes, sending them all at once after a short delay,
+so that reloading many feeds at the same time results in a single notification
+*/
+type Notifier struct {
+	mu      sync.Mutex
+	pending []*News
+	timer   *time.Timer
+
+	delay time.Duration
+	send  func(news []*News)
+}
+
+func New(delay time.Duration, send func(news []*News)) *Notifier {
+	return &Notifier{
+		pending: make([]*News, 0),
+		delay:   delay,
+		send:    send,
+	}
+}
+
+// Add collects the new items of a feed, to be sent along with the ones collected within the delay
+func (n *Notifier) Add(f *feed.Feed, items []*feed.Item) {
+
+	if len(items) == 0 {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var found bool
+	for _, news := range n.pending {
+		if news.FeedUrl == f.Url {
+			news.Items = append(news.Items, items...)
+			found = true
+		}
+	}
+	if !found {
+		n.pending = append(n.pending, &News{
+			FeedName: f.Name,
+			FeedUrl:  f.Url,
+			Items:    append(make([]*feed.Item, 0, len(items)), items...),
+		})
+	}
+
+	if n.timer == nil {
+		n.timer = time.AfterFunc(n.delay, n.flush)
+	}
+}
+
+func (n *Notifier) flush() {
+
+	n.mu.Lock()
+	news := n.pending
+	n.pending = make([]*News, 0)
+	n.timer = nil
+	n.mu.Unlock()
+
+	n.send(news)
+}
+
+// Summary returns a line for every feed with new items, formatted by replacing %n with their count and %f with the feed name
+func Summary(format string, news []*News) string {
+	lines := make([]string, 0, len(news))
+	for _, n := range news {
+		r := strings.NewReplacer(countPlaceholder, strconv.Itoa(len(n.Items)), feedPlaceholder, n.FeedName)
+		lines = append(lines, r.Replace(format))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ItemMessage formats the notification of a single item by replacing %f with the feed name and %t with the item title
+func ItemMessage(format, feedName string, i *feed.Item) string {
+	return strings.NewReplacer(feedPlaceholder, feedName, titlePlaceholder, i.Title).Replace(format)
+}
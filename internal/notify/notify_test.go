@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+)
+
+func TestNotifier(t *testing.T) {
+
+	sent := make(chan []*News, 1)
+	n := New(50*time.Millisecond, func(news []*News) { sent <- news })
+
+	golang := feed.NewFeed("Go Blog").WithUrl("https://go.dev/blog/feed.atom")
+	lwn := feed.NewFeed("LWN").WithUrl("https://lwn.net/headlines/rss")
+
+	n.Add(golang, []*feed.Item{feed.NewItem("Go 1.23", "https://go.dev/blog/go1.23", time.Now())})
+	n.Add(lwn, nil)
+	n.Add(lwn, []*feed.Item{
+		feed.NewItem("Kernel release status", "https://lwn.net/1", time.Now()),
+		feed.NewItem("Security updates", "https://lwn.net/2", time.Now()),
+	})
+	n.Add(golang, []*feed.Item{feed.NewItem("Range functions", "https://go.dev/blog/range-functions", time.Now())})
+
+	var news []*News
+	select {
+	case news = <-sent:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notification sent")
+	}
+
+	want := "2 new item(s) in Go Blog\n2 new item(s) in LWN"
+	if got := Summary("%n new item(s) in %f", news); got != want {
+		t.Errorf("want summary:\n%s\ngot:\n%s", want, got)
+	}
+
+	if got := ItemMessage("%f: %t", news[1].FeedName, news[1].Items[0]); got != "LWN: Kernel release status" {
+		t.Errorf("unexpected item message: %q", got)
+	}
+
+	select {
+	case <-sent:
+		t.Error("news sent twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
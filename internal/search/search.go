@@ -0,0 +1,243 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/html"
+)
+
+const (
+	// how much more a word in the title of an item counts than one in its text
+	titleWeight = 3
+	// how much more a query term matching a whole word counts than one matching its beginning
+	exactWeight = 2
+	// shorter words are not indexed
+	minWordLen = 2
+)
+
+// Key identifies an indexed item, as the cache does: by the url of its feed and its title
+type Key struct {
+	FeedUrl string
+	Title   string
+}
+
+// Match is an item matching a query, scored by how often and where the terms of the query are found in it
+type Match struct {
+	Key
+	Score int
+}
+
+/*
+Index is an inverted index of the words found in the title of items, in the content provided by their feed
+and in the text extracted from their web page, if kept: it is updated item by item
+*/
+type Index struct {
+	mu sync.Mutex
+	// weight of every item in which a word is found
+	postings map[string]map[Key]int
+	// words found in every item, to remove it
+	words map[Key][]string
+}
+
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[Key]int),
+		words:    make(map[Key][]string),
+	}
+}
+
+// Add indexes an item of the feed with the given url, replacing what was indexed for it before
+func (x *Index) Add(feedUrl string, i *feed.Item) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	key := Key{FeedUrl: feedUrl, Title: i.Title}
+	x.remove(key)
+
+	weights := make(map[string]int)
+	for _, w := range Words(i.Title) {
+		weights[w] += titleWeight
+	}
+	for _, w := range Words(Text(i)) {
+		weights[w]++
+	}
+
+	words := make([]string, 0, len(weights))
+	for w, weight := range weights {
+		docs, found := x.postings[w]
+		if !found {
+			docs = make(map[Key]int)
+			x.postings[w] = docs
+		}
+		docs[key] = weight
+		words = append(words, w)
+	}
+	x.words[key] = words
+}
+
+// Remove drops an item of the feed with the given url from the index
+func (x *Index) Remove(feedUrl, title string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(Key{FeedUrl: feedUrl, Title: title})
+}
+
+func (x *Index) remove(key Key) {
+	for _, w := range x.words[key] {
+		delete(x.postings[w], key)
+		if len(x.postings[w]) == 0 {
+			delete(x.postings, w)
+		}
+	}
+	delete(x.words, key)
+}
+
+// Len returns the number of indexed items
+func (x *Index) Len() int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return len(x.words)
+}
+
+/*
+Search returns the items containing every term of a query, either as a whole word or as the beginning of one,
+from the best match
+*/
+func (x *Index) Search(query string) []*Match {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	terms := Words(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var scores map[Key]int
+	for _, t := range terms {
+
+		termScores := make(map[Key]int)
+		for w, docs := range x.postings {
+			if !strings.HasPrefix(w, t) {
+				continue
+			}
+			factor := 1
+			if w == t {
+				factor = exactWeight
+			}
+			for key, weight := range docs {
+				termScores[key] += weight * factor
+			}
+		}
+
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for key := range scores {
+			if s, found := termScores[key]; found {
+				scores[key] += s
+			} else {
+				delete(scores, key)
+			}
+		}
+	}
+
+	matches := make([]*Match, 0, len(scores))
+	for key, score := range scores {
+		matches = append(matches, &Match{Key: key, Score: score})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if matches[i].FeedUrl != matches[j].FeedUrl {
+			return matches[i].FeedUrl < matches[j].FeedUrl
+		}
+		return matches[i].Title < matches[j].Title
+	})
+	return matches
+}
+
+// Words returns the lowercase words of a text, as indexed
+func Words(text string) []string {
+	words := make([]string, 0)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), isSeparator) {
+		if len([]rune(w)) >= minWordLen {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// Text returns the text of an item, extracted from its web page if kept, otherwise from the content provided by its feed
+func Text(i *feed.Item) string {
+	if i.Article != nil {
+		return i.Article.String()
+	}
+	if i.Content == "" {
+		return ""
+	}
+	article, err := html.FromHTML(i.Content, i.Url)
+	if err != nil {
+		return ""
+	}
+	return article.String()
+}
+
+/*
+Snippet returns a passage of about the given number of runes from a text, around the first word starting with a term of the query,
+or its beginning if none is found
+*/
+func Snippet(text, query string, width int) string {
+
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) <= width {
+		return string(runes)
+	}
+
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	// -1 until a term is found
+	start := -1
+	for _, t := range Words(query) {
+		if at := wordStart(lower, []rune(t)); at >= 0 && (start < 0 || at < start) {
+			start = at
+		}
+	}
+
+	// some context before the match, starting at a word
+	start = max(start-width/4, 0)
+	for start > 0 && !isSeparator(runes[start-1]) {
+		start--
+	}
+
+	end := min(start+width, len(runes))
+	snippet := strings.TrimSpace(string(runes[start:end]))
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// wordStart returns where the first word starting with the given term begins in a text, -1 if none does
+func wordStart(text, term []rune) int {
+	for i := 0; i+len(term) <= len(text); i++ {
+		if (i == 0 || isSeparator(text[i-1])) && string(text[i:i+len(term)]) == string(term) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,94 @@
+package search
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/feed"
+	"github.com/giulianopz/newscanoe/internal/html"
+)
+
+func TestSearch(t *testing.T) {
+
+	lists := feed.NewItem("Linked lists considered harmful", "https://example.com/lists", time.Now())
+	lists.Content = "<p>Why arrays beat <b>linked lists</b> on modern CPUs.</p>"
+
+	trees := feed.NewItem("Balanced trees", "https://example.com/trees", time.Now())
+	trees.Content = "<p>Red-black trees are made of nodes linked to their children.</p>"
+
+	saved := feed.NewItem("Cache-friendly code", "https://other.com/cache", time.Now())
+	saved.Article = &html.Article{Blocks: []*html.Block{{Kind: html.PARAGRAPH, Text: "Prefer arrays to lists of pointers."}}}
+
+	x := NewIndex()
+	x.Add("https://example.com/rss", lists)
+	x.Add("https://example.com/rss", trees)
+	x.Add("https://other.com/rss", saved)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// the title counts more than the text
+		{"linked", []string{"Linked lists considered harmful", "Balanced trees"}},
+		// every term must match
+		{"linked lists", []string{"Linked lists considered harmful"}},
+		// a term matches the beginning of words, and the text saved from the web page is indexed
+		{"array", []string{"Linked lists considered harmful", "Cache-friendly code"}},
+		{"LIST", []string{"Linked lists considered harmful", "Cache-friendly code"}},
+		{"hash tables", []string{}},
+		{"", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, m := range x.Search(tt.query) {
+				got = append(got, m.Title)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	x.Remove("https://example.com/rss", "Balanced trees")
+	if m := x.Search("trees"); len(m) != 0 {
+		t.Errorf("removed item still found: %+v", m)
+	}
+
+	// an item added again replaces what was indexed before
+	lists.Content = ""
+	x.Add("https://example.com/rss", lists)
+	if m := x.Search("arrays"); len(m) != 1 || m[0].Title != "Cache-friendly code" {
+		t.Errorf("stale text still indexed: %+v", m)
+	}
+	if x.Len() != 2 {
+		t.Errorf("want 2 items indexed, got %d", x.Len())
+	}
+}
+
+func TestSnippet(t *testing.T) {
+
+	text := "Arrays are laid out contiguously in memory, so iterating over them is friendly to the cache, while linked lists scatter their nodes all over the heap."
+
+	tests := []struct {
+		name  string
+		query string
+		width int
+		want  string
+	}{
+		{"short text", "lists", 500, text},
+		{"match in the middle", "linked", 40, "…cache, while linked lists scatter their…"},
+		{"match at the beginning", "arrays lists", 20, "Arrays are laid out…"},
+		{"no match", "trees", 20, "Arrays are laid out…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Snippet(text, tt.query, tt.width); got != tt.want {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"golang.org/x/exp/slices"
+)
+
+// keywords starting the constraints of a table, as opposed to the definitions of its columns
+var tableConstraints = []string{"CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN"}
+
+/*
+parseColumns returns the names of the columns defined by a CREATE TABLE statement, as stored in the schema table,
+together with the index of the one aliasing the rowid (INTEGER PRIMARY KEY), -1 if none
+*/
+func parseColumns(sql string) ([]string, int, error) {
+
+	start := strings.Index(sql, "(")
+	end := strings.LastIndex(sql, ")")
+	if start == -1 || end < start {
+		return nil, -1, errors.New("malformed CREATE TABLE statement")
+	}
+
+	columns := make([]string, 0)
+	rowidColumn := -1
+	for _, def := range splitDefinitions(sql[start+1 : end]) {
+
+		name, rest := splitIdentifier(strings.TrimSpace(def))
+		if name == "" || isTableConstraint(name) {
+			continue
+		}
+
+		fields := strings.Fields(strings.ToUpper(rest))
+		if len(fields) > 0 && fields[0] == "INTEGER" && strings.Contains(strings.Join(fields, " "), "PRIMARY KEY") && !slices.Contains(fields, "DESC") {
+			rowidColumn = len(columns)
+		}
+		columns = append(columns, name)
+	}
+
+	if len(columns) == 0 {
+		return nil, -1, errors.New("no column defined")
+	}
+	return columns, rowidColumn, nil
+}
+
+// splitDefinitions splits the definitions of columns and constraints, ignoring the commas between parentheses or quotes
+func splitDefinitions(s string) []string {
+
+	defs := make([]string, 0)
+	depth, from := 0, 0
+	var quote rune
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'' || r == '`':
+			quote = r
+		case r == '[':
+			quote = ']'
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			defs = append(defs, s[from:i])
+			from = i + 1
+		}
+	}
+	return append(defs, s[from:])
+}
+
+func isTableConstraint(word string) bool {
+	for _, k := range tableConstraints {
+		if strings.EqualFold(word, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitIdentifier splits the name, unquoted, at the beginning of a definition from what follows it
+func splitIdentifier(def string) (string, string) {
+	if def == "" {
+		return "", ""
+	}
+	closing := map[byte]byte{'"': '"', '`': '`', '[': ']'}
+	if c, quoted := closing[def[0]]; quoted {
+		if end := strings.IndexByte(def[1:], c); end != -1 {
+			return def[1 : end+1], def[end+2:]
+		}
+	}
+	if end := strings.IndexFunc(def, unicode.IsSpace); end != -1 {
+		return def[:end], def[end:]
+	}
+	return def, ""
+}
@@ -0,0 +1,420 @@
+package sqlite
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+/*
+DB reads the tables of a SQLite database file, walking its b-trees without any SQL engine: it is meant to import
+the databases of other programs, not to query them.
+see: https://www.sqlite.org/fileformat2.html
+*/
+type DB struct {
+	f        *os.File
+	pageSize int
+	// page size minus the space reserved at the end of every page
+	usable int
+	// size of the file, which no payload can exceed
+	fileSize int64
+	tables   map[string]*table
+}
+
+type table struct {
+	rootPage int
+	columns  []string
+	// index of the column aliasing the rowid (INTEGER PRIMARY KEY), -1 if none
+	rowidColumn int
+}
+
+// Row is a row of a table, by column name: values are either nil, int64, float64, string or []byte
+type Row map[string]any
+
+const (
+	headerSize  = 100
+	magicHeader = "SQLite format 3\x00"
+	utf8        = 1
+
+	interiorTable = 0x05
+	leafTable     = 0x0d
+
+	// the smallest usable size of a page allowed by the format
+	minUsable = 480
+)
+
+var ErrNoTable = errors.New("no such table")
+
+// Open reads the schema of a database file, which must be encoded in UTF-8
+func Open(path string) (*DB, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &DB{f: f, tables: make(map[string]*table)}
+	if err := db.readHeader(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cannot read database %s: %w", path, err)
+	}
+	if err := db.readSchema(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cannot read schema of database %s: %w", path, err)
+	}
+	return db, nil
+}
+
+func (db *DB) Close() error {
+	return db.f.Close()
+}
+
+func (db *DB) readHeader() error {
+
+	info, err := db.f.Stat()
+	if err != nil {
+		return err
+	}
+	db.fileSize = info.Size()
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(db.f, header); err != nil {
+		return err
+	}
+	if string(header[:16]) != magicHeader {
+		return errors.New("not a SQLite database")
+	}
+
+	db.pageSize = int(binary.BigEndian.Uint16(header[16:18]))
+	if db.pageSize == 1 {
+		db.pageSize = 65536
+	}
+	if db.pageSize < 512 || db.pageSize&(db.pageSize-1) != 0 {
+		return fmt.Errorf("invalid page size: %d", db.pageSize)
+	}
+	db.usable = db.pageSize - int(header[20])
+	if db.usable < minUsable {
+		return fmt.Errorf("invalid reserved space: %d", header[20])
+	}
+
+	if encoding := binary.BigEndian.Uint32(header[56:60]); encoding != utf8 && encoding != 0 {
+		return fmt.Errorf("unsupported text encoding: %d", encoding)
+	}
+	return nil
+}
+
+// readSchema reads the tables from the schema table, stored in the first page
+func (db *DB) readSchema() error {
+
+	schema := &table{rootPage: 1, columns: []string{"type", "name", "tbl_name", "rootpage", "sql"}, rowidColumn: -1}
+
+	return db.scan(schema, func(r Row) error {
+
+		if r["type"] != "table" {
+			return nil
+		}
+		name, _ := r["name"].(string)
+		rootPage, _ := r["rootpage"].(int64)
+		sql, _ := r["sql"].(string)
+
+		// e.g. WITHOUT ROWID tables, stored as indexes
+		if strings.Contains(strings.ToUpper(sql), "WITHOUT ROWID") {
+			return nil
+		}
+
+		columns, rowidColumn, err := parseColumns(sql)
+		if err != nil {
+			return fmt.Errorf("table %s: %w", name, err)
+		}
+		db.tables[name] = &table{rootPage: int(rootPage), columns: columns, rowidColumn: rowidColumn}
+		return nil
+	})
+}
+
+// Columns returns the names of the columns of a table, in order
+func (db *DB) Columns(tableName string) ([]string, error) {
+	t, found := db.tables[tableName]
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrNoTable, tableName)
+	}
+	return t.columns, nil
+}
+
+// Scan calls fn for every row of a table, by rowid, stopping at the first error returned
+func (db *DB) Scan(tableName string, fn func(Row) error) error {
+	t, found := db.tables[tableName]
+	if !found {
+		return fmt.Errorf("%w: %s", ErrNoTable, tableName)
+	}
+	return db.scan(t, fn)
+}
+
+func (db *DB) scan(t *table, fn func(Row) error) error {
+	return db.walk(t.rootPage, make(map[int]bool), func(rowid int64, payload []byte) error {
+
+		values, err := decodeRecord(payload)
+		if err != nil {
+			return err
+		}
+
+		row := make(Row, len(t.columns))
+		for i, c := range t.columns {
+			// columns added after the row was written are missing from its record
+			if i < len(values) {
+				row[c] = values[i]
+			} else {
+				row[c] = nil
+			}
+		}
+		if t.rowidColumn >= 0 {
+			row[t.columns[t.rowidColumn]] = rowid
+		}
+		return fn(row)
+	})
+}
+
+// walk visits the cells of a table b-tree in order, keeping track of the pages visited to detect loops in corrupted files
+func (db *DB) walk(pageNumber int, visited map[int]bool, fn func(rowid int64, payload []byte) error) error {
+
+	if visited[pageNumber] {
+		return fmt.Errorf("page %d visited twice", pageNumber)
+	}
+	visited[pageNumber] = true
+
+	page, err := db.page(pageNumber)
+	if err != nil {
+		return err
+	}
+
+	// the first page starts with the header of the file
+	offset := 0
+	if pageNumber == 1 {
+		offset = headerSize
+	}
+
+	kind := page[offset]
+	cellCount := int(binary.BigEndian.Uint16(page[offset+3 : offset+5]))
+
+	// the array of cell pointers follows the header of the page
+	pointers := offset + 8
+	if kind == interiorTable {
+		pointers = offset + 12
+	}
+	if pointers+2*cellCount > len(page) {
+		return fmt.Errorf("%d cells out of page %d", cellCount, pageNumber)
+	}
+
+	switch kind {
+	case leafTable:
+		for i := 0; i < cellCount; i++ {
+			cell := int(binary.BigEndian.Uint16(page[pointers+2*i:]))
+			rowid, payload, err := db.leafCell(page, cell)
+			if err != nil {
+				return fmt.Errorf("page %d: %w", pageNumber, err)
+			}
+			if err := fn(rowid, payload); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case interiorTable:
+		for i := 0; i < cellCount; i++ {
+			cell := int(binary.BigEndian.Uint16(page[pointers+2*i:]))
+			if cell+4 > len(page) {
+				return fmt.Errorf("cell out of page %d", pageNumber)
+			}
+			if err := db.walk(int(binary.BigEndian.Uint32(page[cell:])), visited, fn); err != nil {
+				return err
+			}
+		}
+		return db.walk(int(binary.BigEndian.Uint32(page[offset+8:])), visited, fn)
+
+	default:
+		return fmt.Errorf("unexpected b-tree page type %#x at page %d", kind, pageNumber)
+	}
+}
+
+func (db *DB) page(number int) ([]byte, error) {
+	if number < 1 {
+		return nil, fmt.Errorf("invalid page number: %d", number)
+	}
+	page := make([]byte, db.pageSize)
+	if _, err := db.f.ReadAt(page, int64(number-1)*int64(db.pageSize)); err != nil {
+		return nil, fmt.Errorf("cannot read page %d: %w", number, err)
+	}
+	return page, nil
+}
+
+// leafCell returns the rowid and the payload of a cell of a table leaf, reading the overflow pages which its payload spills into
+func (db *DB) leafCell(page []byte, cell int) (int64, []byte, error) {
+
+	if cell >= len(page) {
+		return 0, nil, errors.New("cell out of page")
+	}
+
+	size, n := varint(page[cell:])
+	if n == 0 {
+		return 0, nil, errors.New("truncated payload size")
+	}
+	cell += n
+	rowid, n := varint(page[cell:])
+	if n == 0 {
+		return 0, nil, errors.New("truncated rowid")
+	}
+	cell += n
+
+	// a payload spilling into overflow pages cannot be larger than the file
+	if size > uint64(db.fileSize) {
+		return 0, nil, fmt.Errorf("payload larger than the file: %d bytes", size)
+	}
+
+	local := db.localPayload(int(size))
+	if cell+local > len(page) {
+		return 0, nil, errors.New("payload out of page")
+	}
+
+	payload := make([]byte, 0, size)
+	payload = append(payload, page[cell:cell+local]...)
+	if local == int(size) {
+		return int64(rowid), payload, nil
+	}
+
+	if cell+local+4 > len(page) {
+		return 0, nil, errors.New("overflow pointer out of page")
+	}
+	next := int(binary.BigEndian.Uint32(page[cell+local:]))
+	visited := make(map[int]bool)
+	for len(payload) < int(size) {
+		if next == 0 || visited[next] {
+			return 0, nil, errors.New("truncated overflow chain")
+		}
+		visited[next] = true
+
+		overflow, err := db.page(next)
+		if err != nil {
+			return 0, nil, err
+		}
+		next = int(binary.BigEndian.Uint32(overflow))
+		chunk := min(int(size)-len(payload), db.usable-4)
+		payload = append(payload, overflow[4:4+chunk]...)
+	}
+	return int64(rowid), payload, nil
+}
+
+// localPayload returns how many bytes of a payload of the given size are stored in a table leaf, the rest spilling into overflow pages
+func (db *DB) localPayload(size int) int {
+	maxLocal := db.usable - 35
+	if size <= maxLocal {
+		return size
+	}
+	minLocal := (db.usable-12)*32/255 - 23
+	local := minLocal + (size-minLocal)%(db.usable-4)
+	if local > maxLocal {
+		return minLocal
+	}
+	return local
+}
+
+// decodeRecord returns the values of a record: a header of serial types followed by the values
+func decodeRecord(record []byte) ([]any, error) {
+
+	headerLen, n := varint(record)
+	if n == 0 || headerLen > uint64(len(record)) {
+		return nil, errors.New("malformed record header")
+	}
+
+	types := make([]uint64, 0)
+	for pos := n; pos < int(headerLen); {
+		t, n := varint(record[pos:headerLen])
+		if n == 0 {
+			return nil, errors.New("malformed record header")
+		}
+		types = append(types, t)
+		pos += n
+	}
+
+	values := make([]any, 0, len(types))
+	body := record[headerLen:]
+	for _, t := range types {
+
+		size := serialSize(t)
+		if size > uint64(len(body)) {
+			return nil, errors.New("record shorter than its header")
+		}
+		data := body[:size]
+		body = body[size:]
+
+		switch {
+		case t == 0:
+			values = append(values, nil)
+		case t <= 6:
+			values = append(values, bigEndianInt(data))
+		case t == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(data)))
+		case t == 8:
+			values = append(values, int64(0))
+		case t == 9:
+			values = append(values, int64(1))
+		case t >= 12 && t%2 == 0:
+			values = append(values, append([]byte(nil), data...))
+		case t >= 13:
+			values = append(values, string(data))
+		default:
+			return nil, fmt.Errorf("reserved serial type: %d", t)
+		}
+	}
+	return values, nil
+}
+
+// serialSize returns the size in bytes of a value of the given serial type, which can exceed the ones of any record if corrupted
+func serialSize(t uint64) uint64 {
+	switch {
+	case t <= 4:
+		return t
+	case t == 5:
+		return 6
+	case t == 6, t == 7:
+		return 8
+	case t < 12:
+		return 0
+	case t%2 == 0:
+		return (t - 12) / 2
+	default:
+		return (t - 13) / 2
+	}
+}
+
+// bigEndianInt decodes a big-endian two's complement integer of up to 8 bytes
+func bigEndianInt(data []byte) int64 {
+	var v int64
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		v = -1
+	}
+	for _, b := range data {
+		v = v<<8 | int64(b)
+	}
+	return v
+}
+
+// varint decodes a SQLite variable-length integer, returning it together with the number of bytes read, 0 if truncated
+func varint(data []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9; i++ {
+		if i >= len(data) {
+			return 0, 0
+		}
+		if i == 8 {
+			return v<<8 | uint64(data[i]), 9
+		}
+		v = v<<7 | uint64(data[i]&0x7f)
+		if data[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, 9
+}
@@ -0,0 +1,152 @@
+package sqlite
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+// testdata/test.db has small pages, so that its table spans several levels of pages and long values overflow
+func TestScan(t *testing.T) {
+
+	db, err := Open("testdata/test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	columns, err := db.Columns("notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"id", "title", "body", "score", "data", "tags"}; !slices.Equal(columns, want) {
+		t.Fatalf("got columns %v, want %v", columns, want)
+	}
+
+	rows := make([]Row, 0)
+	if err := db.Scan("notes", func(r Row) error {
+		rows = append(rows, r)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 201 {
+		t.Fatalf("got %d rows, want 201", len(rows))
+	}
+
+	first := rows[0]
+	if first["id"] != int64(-5) || first["title"] != "negative" || first["body"] != nil || first["score"] != -1.5 {
+		t.Errorf("unexpected first row: %v", first)
+	}
+
+	for i, r := range rows[1:] {
+		if r["id"] != int64(i+1) {
+			t.Fatalf("got rowid %v at row %d, want %d", r["id"], i+1, i+1)
+		}
+	}
+
+	big := rows[150]
+	if body := big["body"].(string); len(body) != 300*len("line 150, ") || !strings.HasPrefix(body, "line 150, ") {
+		t.Errorf("overflowing value not read back: %d bytes", len(body))
+	}
+	if big["tags"] != "big" {
+		t.Errorf("got tags %v, want big", big["tags"])
+	}
+	if data := big["data"].([]byte); !slices.Equal(data, []byte{150, 0}) {
+		t.Errorf("got blob %v", data)
+	}
+	// written before the column was added
+	if rows[1]["tags"] != nil {
+		t.Errorf("got tags %v, want nil", rows[1]["tags"])
+	}
+
+	if err := db.Scan("missing", func(Row) error { return nil }); !errors.Is(err, ErrNoTable) {
+		t.Errorf("got error %v, want %v", err, ErrNoTable)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+
+	columns, rowid, err := parseColumns(`CREATE TABLE t ( "a b" TEXT, id INTEGER PRIMARY KEY, [n] NUMERIC(10, 2) DEFAULT "x,y", PRIMARY KEY (id))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a b", "id", "n"}; !slices.Equal(columns, want) {
+		t.Errorf("got columns %v, want %v", columns, want)
+	}
+	if rowid != 1 {
+		t.Errorf("got rowid column %d, want 1", rowid)
+	}
+}
+
+// every byte of testdata/test.db is corrupted in turn, which must be reported as an error rather than panic
+func TestCorruptFile(t *testing.T) {
+
+	data, err := os.ReadFile("testdata/test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "corrupt.db")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for i := range data {
+
+		// maxing out sizes, counts and page numbers
+		if _, err := f.WriteAt([]byte{0xff}, int64(i)); err != nil {
+			t.Fatal(err)
+		}
+
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("panic with byte %d corrupted: %v", i, r)
+				}
+			}()
+
+			db, err := Open(path)
+			if err != nil {
+				return
+			}
+			defer db.Close()
+			db.Scan("notes", func(Row) error { return nil })
+		}()
+
+		if _, err := f.WriteAt(data[i:i+1], int64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDecodeCorruptRecord(t *testing.T) {
+	tests := []struct {
+		name   string
+		record []byte
+	}{
+		{"empty", nil},
+		{"header longer than the record", []byte{0x05, 0x01}},
+		{"huge header length", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"truncated serial type", []byte{0x02, 0x81}},
+		{"huge serial type", []byte{0x0a, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 'x'}},
+		{"value longer than the record", []byte{0x02, 0x06, 0x01}},
+		{"reserved serial type", []byte{0x02, 0x0a}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if values, err := decodeRecord(tt.record); err == nil {
+				t.Errorf("got values %v, want an error", values)
+			}
+		})
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"bytes"
 	"errors"
+	"fmt"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/giulianopz/newscanoe/internal/app"
 )
@@ -15,6 +17,14 @@ import (
 const (
 	configFileName = "config"
 	cacheFileName  = "feeds.gob"
+	// cache stored in an embedded database
+	databaseFileName = "feeds.db"
+	// download queue
+	downloadsFileName = "downloads.gob"
+	// deliveries to hooks failed for good
+	hooksLogFileName = "hooks.log"
+	// state of the items as last synced with a server
+	syncFileName = "sync.gob"
 )
 
 func GetConfigFilePath() (string, error) {
@@ -43,6 +53,30 @@ func GetConfigFilePath() (string, error) {
 }
 
 func GetCacheFilePath() (string, error) {
+	return getCacheDirFile(cacheFileName)
+}
+
+// GetDatabaseFilePath returns the path of the database storing the cache, if it is the chosen backend
+func GetDatabaseFilePath() (string, error) {
+	return getCacheDirFile(databaseFileName)
+}
+
+// GetDownloadsFilePath returns the path of the file storing the download queue
+func GetDownloadsFilePath() (string, error) {
+	return getCacheDirFile(downloadsFileName)
+}
+
+// GetHooksLogFilePath returns the path of the file logging the deliveries to hooks which failed
+func GetHooksLogFilePath() (string, error) {
+	return getCacheDirFile(hooksLogFileName)
+}
+
+// GetSyncFilePath returns the path of the file storing the state of the items as last synced with a server
+func GetSyncFilePath() (string, error) {
+	return getCacheDirFile(syncFileName)
+}
+
+func getCacheDirFile(name string) (string, error) {
 	cacheDirName, err := os.UserCacheDir()
 	if err != nil {
 		return "", err
@@ -54,7 +88,7 @@ func GetCacheFilePath() (string, error) {
 			return "", err
 		}
 	}
-	return filepath.Join(appCacheDirName, cacheFileName), nil
+	return filepath.Join(appCacheDirName, name), nil
 }
 
 func Exists(path string) bool {
@@ -68,6 +102,86 @@ func Exists(path string) bool {
 	}
 }
 
+/*
+WriteFileAtomic replaces the content of a file writing it to a temporary file in the same directory,
+which is synced and then renamed over the original one, so that a crash never leaves it half-written
+*/
+func WriteFileAtomic(path string, data []byte, perm fs.FileMode) error {
+
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// a no-op once renamed
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir makes a rename in the given directory durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil && !errors.Is(err, fs.ErrInvalid) {
+		return err
+	}
+	return nil
+}
+
+// BackupPath returns the path of the backup of the given file
+func BackupPath(path string) string {
+	return path + ".bak"
+}
+
+// BackupFile keeps the current content of a file, if any, as its backup, replacing the previous one
+func BackupFile(path string) error {
+
+	if !Exists(path) {
+		return nil
+	}
+
+	tmp := BackupPath(path) + ".tmp"
+	os.Remove(tmp)
+	if err := os.Link(path, tmp); err == nil {
+		return os.Rename(tmp, BackupPath(path))
+	}
+
+	// hard links are not supported by every file system
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot back up %s: %w", path, err)
+	}
+	return WriteFileAtomic(BackupPath(path), data, 0644)
+}
+
 func RemoveLines(bs []byte, discard func(string) bool) []byte {
 	buf := bytes.Buffer{}
 	s := bufio.NewScanner(bytes.NewReader(bs))
@@ -79,3 +193,13 @@ func RemoveLines(bs []byte, discard func(string) bool) []byte {
 	}
 	return buf.Bytes()
 }
+
+// ExpandHome replaces a leading tilde with the home directory of the current user
+func ExpandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
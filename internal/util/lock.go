@@ -0,0 +1,89 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/giulianopz/newscanoe/internal/app"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	lockFileName = ".lock"
+	// how long to wait for another instance to release the lock
+	lockTimeout = 5 * time.Second
+	// how often to try again to take the lock
+	lockRetryInterval = 50 * time.Millisecond
+)
+
+// LockedError is returned when the lock of a directory is held by another instance for too long
+type LockedError struct {
+	Dir string
+	// process holding the lock, zero if unknown
+	Pid int
+}
+
+func (e *LockedError) Error() string {
+	if e.Pid == 0 {
+		return fmt.Sprintf("another instance of %s is using %s", app.Name, e.Dir)
+	}
+	return fmt.Sprintf("another instance of %s (pid %d) is using %s", app.Name, e.Pid, e.Dir)
+}
+
+/*
+LockDir takes the advisory lock shared by every instance of the app on the files of a directory,
+waiting for it to be released for a while: the returned function releases it
+*/
+func LockDir(dir string) (func(), error) {
+
+	path := filepath.Join(dir, lockFileName)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, unix.EWOULDBLOCK) && !errors.Is(err, unix.EINTR) {
+			f.Close()
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, &LockedError{Dir: dir, Pid: lockHolder(path)}
+		}
+		time.Sleep(lockRetryInterval)
+	}
+
+	// tell other instances who is holding the lock
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+
+	return func() {
+		unix.Flock(int(f.Fd()), unix.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// lockHolder returns the pid written in a lock file by the instance holding it, zero if unknown
+func lockHolder(path string) int {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(bs)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
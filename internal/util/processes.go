@@ -5,62 +5,116 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// modes of execution of an external program
+const (
+	// the program takes over the terminal until it exits
+	Foreground = "fg"
+	// the program is detached from the terminal
+	Background = "bg"
+)
+
+// placeholders replaced in command templates
+const (
+	urlPlaceholder   = "%u"
+	titlePlaceholder = "%t"
 )
 
 /*
-openWithBrowser opens the selected article with the default browser for desktop environment.
-The environment variable DISPLAY is used to detect if the app is running on a headless machine.
+Command is a shell command line where the placeholders %u and %t are replaced by the url and the title
+of the current element: the url is appended to the command line if it contains no placeholder at all.
+Commands like the default browser (xdg-open) can be run in background,
+while the ones like terminal browsers (lynx) or pagers (less) need to be run in foreground.
 see: https://wiki.debian.org/DefaultWebBrowser
 */
-func OpenWithBrowser(url string) error {
+type Command struct {
+	Template string
+	Mode     string
+}
 
-	if url != "" {
-		err := exec.Command("xdg-open", url).Run()
-		if err != nil {
-			switch e := err.(type) {
-			case *exec.Error:
-				return fmt.Errorf("failed executing: %v", err)
-			case *exec.ExitError:
-				return fmt.Errorf("command exit with code: %v", e.ExitCode())
-			default:
-				panic(err)
-			}
-		}
-	}
-	return nil
+func (c Command) IsForeground() bool {
+	return c.Mode != Background
 }
 
-// headless detects if this is a headless machine by looking up the DISPLAY environment variable
-func IsHeadless() bool {
-	displayVar, set := os.LookupEnv("DISPLAY")
-	log.Default().Printf("DISPLAY=%s", displayVar)
-	return !set || displayVar == ""
+// Build returns the command to be run by the shell, quoting the values replacing the placeholders
+func (c Command) Build(url, title string) *exec.Cmd {
+
+	line := c.Template
+	if url != "" && !strings.Contains(line, urlPlaceholder) && !strings.Contains(line, titlePlaceholder) {
+		line += " " + urlPlaceholder
+	}
+
+	line = strings.NewReplacer(
+		urlPlaceholder, ShellQuote(url),
+		titlePlaceholder, ShellQuote(title),
+	).Replace(line)
+
+	return exec.Command("sh", "-c", line)
 }
 
-func IsLynxPresent() bool {
-	path, err := exec.LookPath("lynx")
-	log.Default().Printf("lynx=%s", path)
+// IsPresent reports whether the program invoked by the command can be found in PATH
+func (c Command) IsPresent() bool {
+	fields := strings.Fields(c.Template)
+	if len(fields) == 0 {
+		return false
+	}
+	path, err := exec.LookPath(fields[0])
+	log.Default().Printf("%s=%s", fields[0], path)
 	return err == nil && path != ""
 }
 
-/*
-openWithLynx opens the selected article with lynx.
-see: https://lynx.invisible-island.net/
-*/
-func OpenWithLynx(url string) error {
-
-	cmd := exec.Command("lynx", url)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// ShellQuote wraps a string in single quotes so that the shell takes it literally
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
 
+// StartDetached starts a command in a new session without waiting for it, discarding its output
+func StartDetached(cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
 	if err := cmd.Start(); err != nil {
 		return err
 	}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			log.Default().Printf("%q failed: %v\n", cmd.String(), CmdError(err))
+		}
+	}()
+	return nil
+}
 
-	if err := cmd.Wait(); err != nil {
+// CmdError describes why an external program failed
+func CmdError(err error) error {
+	switch e := err.(type) {
+	case *exec.Error:
+		return fmt.Errorf("failed executing: %v", err)
+	case *exec.ExitError:
+		return fmt.Errorf("command exit with code: %v", e.ExitCode())
+	default:
 		return err
 	}
+}
 
-	return nil
+// headless detects if this is a headless machine by looking up the DISPLAY (X11) and WAYLAND_DISPLAY environment variables
+func IsHeadless() bool {
+	for _, name := range []string{"DISPLAY", "WAYLAND_DISPLAY"} {
+		displayVar, set := os.LookupEnv(name)
+		log.Default().Printf("%s=%s", name, displayVar)
+		if set && displayVar != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// IsRemote detects if the app is running over SSH by looking up the variables set by sshd
+func IsRemote() bool {
+	for _, name := range []string{"SSH_CONNECTION", "SSH_CLIENT", "SSH_TTY"} {
+		if v, set := os.LookupEnv(name); set && v != "" {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,58 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"empty", "", `''`},
+		{"plain", "https://example.com/a?b=c&d=e", `'https://example.com/a?b=c&d=e'`},
+		{"single quote", "it's", `'it'\''s'`},
+		{"only quotes", "''", `''\'''\'''`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ShellQuote(tt.in); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandBuild(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		template string
+		url      string
+		title    string
+		want     string
+	}{
+		{"url appended if no placeholder", "printf %s", "https://example.com", "", "https://example.com"},
+		{"url placeholder", "printf '<%s>' %u", "https://example.com", "", "<https://example.com>"},
+		{"title placeholder", "printf '%s|%s' %t %u", "https://example.com", "Hello, World", "Hello, World|https://example.com"},
+		{"no url", "printf done", "", "", "done"},
+		// the values come from feeds, so they must never be run
+		{"command substitution", "printf %s %u", "https://example.com/$(echo pwned)", "", "https://example.com/$(echo pwned)"},
+		{"backticks", "printf %s %t", "", "`echo pwned`", "`echo pwned`"},
+		{"quote escaping", "printf %s %u", "https://example.com/'; echo pwned; '", "", "https://example.com/'; echo pwned; '"},
+		{"separators", "printf %s %t", "", "a; echo pwned && echo pwned | cat > /dev/null", "a; echo pwned && echo pwned | cat > /dev/null"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Command{Template: tt.template, Mode: Foreground}.Build(tt.url, tt.title).Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(out); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package util
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -68,3 +69,36 @@ func LineOf(width int, symbol string) string {
 	}
 	return sb.String()
 }
+
+// HumanSize formats a number of bytes with the largest fitting unit, e.g. 27.1 MB
+func HumanSize(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+// units of durations longer than an hour, not supported by time.ParseDuration
+var longUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// ParseDuration parses a duration as time.ParseDuration does, also accepting a number of days or weeks (e.g. 7d, 2w)
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for suffix, unit := range longUnits {
+		if n, found := strings.CutSuffix(s, suffix); found {
+			if v, err := strconv.ParseFloat(n, 64); err == nil && v >= 0 {
+				return time.Duration(v * float64(unit)), nil
+			}
+		}
+	}
+	return time.ParseDuration(s)
+}
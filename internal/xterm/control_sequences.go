@@ -1,5 +1,10 @@
 package xterm
 
+import (
+	"encoding/base64"
+	"fmt"
+)
+
 const (
 	// https://www.xfree86.org/current/ctlseqs.html#Mouse%20Tracking
 	DISABLE_MOUSE_TRACKING  = "\x1b[?1000l\x1b[?1002l\x1b[?1003l\x1b[?1006l"
@@ -7,4 +12,11 @@ const (
 	// https://www.xfree86.org/current/ctlseqs.html#Bracketed%20Paste%20Mode
 	ENABLE_BRACKETED_PASTE  = "\x1b[?2004h"
 	DISABLE_BRACKETED_PASTE = "\x1b[?2004l"
+	// https://invisible-island.net/xterm/ctlseqs/ctlseqs.html#h3-Operating-System-Commands
+	SET_CLIPBOARD_FMT = "\x1b]52;c;%s\x07"
 )
+
+// SetClipboard returns the OSC 52 sequence asking the terminal to put the given text into the system clipboard
+func SetClipboard(text string) string {
+	return fmt.Sprintf(SET_CLIPBOARD_FMT, base64.StdEncoding.EncodeToString([]byte(text)))
+}
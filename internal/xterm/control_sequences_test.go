@@ -0,0 +1,27 @@
+package xterm
+
+import (
+	"testing"
+)
+
+func TestSetClipboard(t *testing.T) {
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"empty", "", "\x1b]52;c;\x07"},
+		{"url", "https://example.com/?a=1&b=2", "\x1b]52;c;aHR0cHM6Ly9leGFtcGxlLmNvbS8/YT0xJmI9Mg==\x07"},
+		// escape characters must not end the sequence
+		{"control characters", "a\x1b]0;title\x07b\n", "\x1b]52;c;YRtdMDt0aXRsZQdiCg==\x07"},
+		{"non-ASCII", "perché", "\x1b]52;c;cGVyY2jDqQ==\x07"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SetClipboard(tt.text); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/giulianopz/newscanoe/cmd/newscanoe"
 )
@@ -14,15 +15,29 @@ var (
 	debugFlag       bool
 	editFlag        bool
 	removeCacheFlag bool
+	compactFlag     bool
+	migrateFlag     bool
 )
 
 const usage = `Usage:
     newscanoe [OPTION]...
+    newscanoe COMMAND [ARG]...
+
+Commands:
+	search QUERY		Print the cached articles of all feeds containing the words of the query.
+	import-newsboat [URLS [CACHE.DB]]
+				Add the feeds of newsboat to the config file and its articles to the cache.
+	sync			Sync feeds, articles and their state with the server set in the config file.
+	serve [ADDRESS]		Serve the cached articles over the Google Reader API, for mobile clients.
+	export-feed [--format rss|atom|json] [--tag TAG] [--since 7d] [-o FILE] [--html FILE]
+				Write the cached articles of all feeds as a single feed, e.g. for a blogroll.
 
 Options:
 	-d, --debug		Enable debug mode.
 	-e, --edit		Edit config file with default text editor (according to $EDITOR).
 	-c, --clean		Remove cache file.
+	--compact		Drop the cached items exceeding the retention limits and rewrite the cache file.
+	--migrate-cache		Copy the cache file into the database of the bolt cache backend.
 `
 
 func main() {
@@ -33,6 +48,8 @@ func main() {
 	flag.BoolVar(&editFlag, "edit", false, "edit config file with default text editor (according to $EDITOR)")
 	flag.BoolVar(&removeCacheFlag, "c", false, "remove cache file")
 	flag.BoolVar(&removeCacheFlag, "clean", false, "remove cache file")
+	flag.BoolVar(&compactFlag, "compact", false, "drop the cached items exceeding the retention limits and rewrite the cache file")
+	flag.BoolVar(&migrateFlag, "migrate-cache", false, "copy the cache file into the database of the bolt cache backend")
 	flag.Usage = func() { fmt.Print(usage) }
 	flag.Parse()
 
@@ -42,16 +59,42 @@ func main() {
 
 	var err error
 
-	if editFlag {
+	if flag.NArg() != 0 {
+		err = runCommand(flag.Arg(0), flag.Args()[1:])
+	} else if editFlag {
 		err = newscanoe.EditConfigFile()
 	} else if removeCacheFlag {
 		err = newscanoe.RemoveCacheFile()
+	} else if compactFlag {
+		err = newscanoe.CompactCache()
+	} else if migrateFlag {
+		err = newscanoe.MigrateCache()
 	} else {
 		newscanoe.Run(debugFlag)
 	}
 
 	if err != nil {
 		log.Default().Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
+
+// runCommand runs a command given after the options
+func runCommand(name string, args []string) error {
+	switch name {
+	case "search":
+		return newscanoe.Search(strings.Join(args, " "))
+	case "import-newsboat":
+		return newscanoe.ImportNewsboat(args)
+	case "sync":
+		return newscanoe.Sync()
+	case "serve":
+		return newscanoe.Serve(args)
+	case "export-feed":
+		return newscanoe.ExportFeed(args)
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command: %q", name)
+	}
+}